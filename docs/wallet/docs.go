@@ -25,16 +25,16 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/callContract": {
+        "/approveToken": {
             "post": {
-                "description": "call contract method and retrieves values.",
+                "description": "allows a spender to transfer ERC-20 tokens of the wallet. The amount is in whole tokens, or \"max\" for an unlimited allowance.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Call contract",
+                "summary": "Approve tokens",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -42,7 +42,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.CallContractRequest"
+                            "$ref": "#/definitions/utils.TokenApproveRequest"
                         }
                     }
                 ],
@@ -56,16 +56,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/createWallet": {
+        "/batchCallContract": {
             "post": {
-                "description": "Creates a wallet reference and generates keys for the wallet.",
+                "description": "runs many read calls in a single eth_call through Multicall3 aggregate3 and decodes each result. Calls run with Multicall3 as msg.sender.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Creates Wallet",
+                "summary": "Batch call contracts",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -73,7 +73,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.WalletRequest"
+                            "$ref": "#/definitions/utils.BatchCallRequest"
                         }
                     }
                 ],
@@ -87,16 +87,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/deployContract": {
+        "/batchExecute": {
             "post": {
-                "description": "deploys smart contract onto the network.",
+                "description": "runs many calls atomically from a smart account owned by the wallet through its executeBatch method. The whole batch reverts if any call fails.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Deploys contract",
+                "summary": "Batch execute",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -104,7 +104,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.DeployContractRequest"
+                            "$ref": "#/definitions/utils.BatchExecuteRequest"
                         }
                     }
                 ],
@@ -118,16 +118,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/estimateGas": {
+        "/batchTransferNft": {
             "post": {
-                "description": "estimates gas for the transaction.",
+                "description": "transfers several tokens with a single safeBatchTransferFrom for ERC-1155, or one safeTransferFrom txn per token for ERC-721.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Estimate gas",
+                "summary": "Batch transfer NFTs",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -135,7 +135,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.EstimateGasRequest"
+                            "$ref": "#/definitions/utils.NftBatchTransferRequest"
                         }
                     }
                 ],
@@ -149,16 +149,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/getBalance": {
+        "/callContract": {
             "post": {
-                "description": "retrieves wallet balance for a network.",
+                "description": "call contract method and retrieves values.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get balance",
+                "summary": "Call contract",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -166,7 +166,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.WalletBalanceRequest"
+                            "$ref": "#/definitions/utils.CallContractRequest"
                         }
                     }
                 ],
@@ -180,16 +180,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/signAndSubmitGaslessTxn": {
+        "/cancelTransaction": {
             "post": {
-                "description": "signs and submits gasless transaction onto network.",
+                "description": "replaces a pending transaction with a zero value transfer to the wallet itself.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Submit gasless transaction",
+                "summary": "Cancel transaction",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -197,7 +197,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.SignAndSubmitGSNTxnRequest"
+                            "$ref": "#/definitions/utils.ReplaceTransactionRequest"
                         }
                     }
                 ],
@@ -211,16 +211,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/signEIP712Tx": {
+        "/chains": {
+            "get": {
+                "description": "lists the chains the KMS can send transactions to.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List chains",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/createSubscription": {
             "post": {
-                "description": "sign EIP712 typed data.",
+                "description": "subscribes a webhook to events of a contract. Events are decoded with the ABI and POSTed with an HMAC-SHA256 signature once their block has enough confirmations.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Sign EIP712 data",
+                "summary": "Create event subscription",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -228,7 +245,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.EIP712SignRequest"
+                            "$ref": "#/definitions/utils.CreateSubscriptionRequest"
                         }
                     }
                 ],
@@ -242,16 +259,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/signMessage": {
+        "/createWallet": {
             "post": {
-                "description": "sign message and return signature.",
+                "description": "Creates a wallet reference and generates keys for the wallet.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Sign message",
+                "summary": "Creates Wallet",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -259,7 +276,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.SignMsgRequest"
+                            "$ref": "#/definitions/utils.WalletRequest"
                         }
                     }
                 ],
@@ -273,16 +290,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/submitTransaction": {
+        "/decodeCalldata": {
             "post": {
-                "description": "Signs and submits txn onto the network.",
+                "description": "decodes hex calldata into the method and its arguments with the given ABI, the registered ABI of the contract, or else the bundled database of well known method selectors.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Submits transaction",
+                "summary": "Decode calldata",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -290,7 +307,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.SignAndSubmitTxn"
+                            "$ref": "#/definitions/utils.DecodeCalldataRequest"
                         }
                     }
                 ],
@@ -304,16 +321,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/verifySignatureOffChain": {
+        "/deleteSubscription": {
             "post": {
-                "description": "verifies signature offline.",
+                "description": "stops delivering the events of a subscription.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Verify signature",
+                "summary": "Delete event subscription",
                 "parameters": [
                     {
                         "description": "Request Body",
@@ -321,7 +338,7 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.VerifyMsgRequest"
+                            "$ref": "#/definitions/utils.DeleteSubscriptionRequest"
                         }
                     }
                 ],
@@ -334,129 +351,2332 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "utils.CallContractRequest": {
-            "type": "object",
-            "properties": {
-                "contractABI": {
-                    "type": "string",
-                    "example": "hello"
-                },
-                "gas": {
-                    "type": "integer"
-                },
-                "method": {
-                    "type": "string",
-                    "example": "store"
-                },
-                "params": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/utils.Param"
-                    }
-                },
-                "to": {
-                    "type": "string",
-                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
-                },
-                "value": {
-                    "type": "integer"
-                },
-                "walletId": {
-                    "type": "string"
-                }
-            }
         },
-        "utils.DeployContractRequest": {
-            "type": "object",
-            "properties": {
-                "abi": {
-                    "type": "string"
-                },
-                "byteCode": {
-                    "type": "string"
-                },
-                "params": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/utils.Param"
+        "/deployContract": {
+            "post": {
+                "description": "deploys smart contract onto the network.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Deploys contract",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.DeployContractRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
                     }
-                },
-                "walletId": {
-                    "type": "string"
                 }
             }
         },
-        "utils.EIP712SignRequest": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "string"
-                },
-                "walletId": {
-                    "type": "string"
+        "/deployProxy": {
+            "post": {
+                "description": "deploys an implementation unless an existing one is given, waits for it to be mined, then deploys an ERC-1967 proxy to it with the encoded initializer call. The proxy is registered with the implementation ABI.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Deploy upgradeable contract",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.DeployProxyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
                 }
             }
         },
-        "utils.EstimateGasRequest": {
-            "type": "object",
-            "properties": {
-                "byteCode": {
-                    "type": "string"
-                },
-                "contractABI": {
-                    "type": "string"
-                },
-                "data": {
-                    "type": "string"
-                },
-                "gas": {
-                    "type": "integer"
-                },
-                "isContractTxn": {
-                    "type": "boolean"
-                },
-                "method": {
-                    "type": "string"
-                },
-                "params": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/utils.Param"
+        "/deploySafe": {
+            "post": {
+                "description": "deploys a Safe v1.3.0 proxy through the Safe proxy factory, owned by the given owners or by the wallet alone. The address is predicted before signing, and when the Safe already exists no transaction is sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Deploy Safe",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.DeploySafeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
                     }
-                },
-                "to": {
-                    "type": "string"
-                },
-                "value": {
-                    "type": "integer"
-                },
-                "walletId": {
-                    "type": "string"
                 }
             }
         },
-        "utils.Param": {
-            "type": "object",
-            "properties": {
-                "type": {
-                    "type": "string"
-                },
-                "value": {
-                    "type": "string"
+        "/encodeCalldata": {
+            "post": {
+                "description": "encodes a method call as hex calldata, e.g. for a multisig transaction. The ABI is taken from the contract registry when a contract is given without contractABI.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Encode calldata",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.EncodeCalldataRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/estimateGas": {
+            "post": {
+                "description": "estimates gas for the transaction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Estimate gas",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.EstimateGasRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/executeSafeTransaction": {
+            "post": {
+                "description": "signs a Safe transaction with the KMS owners in ownerWalletIds, adds the signatures collected elsewhere, and once the threshold is met calls execTransaction on the Safe with the signatures ordered by owner. The transaction is sent by walletId.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Execute Safe transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.ExecuteSafeTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getBalance": {
+            "post": {
+                "description": "retrieves the native balance of a wallet, and optionally its ERC-20 token balances, from the chain at the latest or a given block.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get balance",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.WalletBalanceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getContract": {
+            "post": {
+                "description": "returns a registered contract with all versions of its ABI.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get contract",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.GetContractRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getNftBalance": {
+            "post": {
+                "description": "retrieves the number of tokens of an ERC-721 contract, or of an ERC-1155 token id, held by a wallet or address.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get NFT balance",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftBalanceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getNftBalanceBatch": {
+            "post": {
+                "description": "retrieves the balances of pairs of owners and token ids of an ERC-1155 contract.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get NFT balances",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftBalanceBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getNftOwner": {
+            "post": {
+                "description": "retrieves the owner of an ERC-721 token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get NFT owner",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getNftUri": {
+            "post": {
+                "description": "retrieves the metadata uri of a token, from tokenURI for ERC-721 and uri for ERC-1155.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get NFT metadata uri",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getProxy": {
+            "post": {
+                "description": "reads the implementation, admin and beacon of a proxy from its ERC-1967 storage slots, and the owner of the admin when it is a ProxyAdmin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get proxy",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.GetProxyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getSafe": {
+            "post": {
+                "description": "returns the version, owners, threshold and nonce of a Safe.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get Safe",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.GetSafeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getSmartAccount": {
+            "post": {
+                "description": "returns the ERC-4337 smart account of a wallet, owned by the wallet and deployed by a SimpleAccountFactory on its first user operation, with whether it is deployed and its nonce.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get smart account",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SmartAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getTokenAllowance": {
+            "post": {
+                "description": "retrieves how many ERC-20 tokens a spender may transfer on behalf of the owner.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get token allowance",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenAllowanceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getTokenBalance": {
+            "post": {
+                "description": "retrieves the ERC-20 token balance of a wallet or address.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get token balance",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenBalanceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getTokenMetadata": {
+            "post": {
+                "description": "retrieves name, symbol and decimals of an ERC-20 token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get token metadata",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenMetadataRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getTransaction": {
+            "post": {
+                "description": "retrieves the status, receipt details and confirmations of a transaction sent by the KMS.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.GetTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getTransactionEvents": {
+            "post": {
+                "description": "fetches the receipt of a transaction and decodes its logs with the supplied ABI, the registered ABIs of the emitting contracts and the ERC-20, ERC-721 and ERC-1155 ABIs. Logs no ABI matches are returned raw.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get transaction events",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TransactionEventsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/getUserOperationReceipt": {
+            "post": {
+                "description": "returns the receipt of a user operation from the chain's bundler, empty while the operation is pending.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get user operation receipt",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.UserOperationReceiptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/listContracts": {
+            "post": {
+                "description": "lists the registered contracts of a chain.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List contracts",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.ListContractsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/listSubscriptions": {
+            "post": {
+                "description": "lists event subscriptions and their progress, optionally of a chain or contract. Secrets are left out.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List event subscriptions",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.ListSubscriptionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/listTransactions": {
+            "post": {
+                "description": "lists the transactions sent by a wallet, newest first.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List transactions",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.ListTransactionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/registerContract": {
+            "post": {
+                "description": "registers the ABI of a contract, as a new version if the contract is registered already, and aliases to call it by.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Register contract",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.RegisterContractRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/rpcStats": {
+            "get": {
+                "description": "returns health, circuit breaker state, latency and request counts of the RPC endpoints of every chain.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "RPC endpoint stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/sendUserOperation": {
+            "post": {
+                "description": "runs calls from the ERC-4337 smart account of a wallet. The user operation deploys the account if needed, gets its gas limits estimated by the chain's bundler unless given, is signed by the wallet and sent to the bundler. With skipSubmit the signed operation is returned without sending it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Send user operation",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SendUserOperationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/setNftApprovalForAll": {
+            "post": {
+                "description": "allows or disallows an operator to transfer all tokens of the wallet in an ERC-721 or ERC-1155 contract.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Set NFT approval for all",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftApprovalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signAndSubmitGaslessTxn": {
+            "post": {
+                "description": "signs and submits gasless transaction onto network.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submit gasless transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SignAndSubmitGSNTxnRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signEIP712Tx": {
+            "post": {
+                "description": "sign EIP712 typed data.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign EIP712 data",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.EIP712SignRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signMessage": {
+            "post": {
+                "description": "signs a message and returns the signature with v as 27 or 28. The mode is personal_sign (default) for text with the EIP-191 \"\\x19Ethereum Signed Message:\\n\" prefix, bytes for 0x hex data with the same prefix, hash for a raw 32-byte hash, validator for EIP-191 version 0x00 data of a validator contract, or keccak for the unprefixed keccak256 hash of the text.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign message",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SignMsgRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signPermit": {
+            "post": {
+                "description": "signs an EIP-2612 permit that lets a spender use tokens of the wallet without an approve transaction. Name, version, nonce and domain separator are read from the token, and the signature is returned with v, r, s and the deadline for calling permit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign EIP-2612 permit",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.PermitRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signPermit2": {
+            "post": {
+                "description": "signs a Uniswap Permit2 PermitSingle or PermitBatch allowance, or a PermitTransferFrom or PermitBatchTransferFrom one-time transfer, for tokens the wallet approved to Permit2. Nonces are read from Permit2, and the signed message is returned with v, r, s and the deadline.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign Permit2 permit",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.Permit2Request"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/signSafeTransaction": {
+            "post": {
+                "description": "signs the EIP-712 SafeTx hash of a Safe transaction with a wallet that owns the Safe, for executing it once enough owners signed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign Safe transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SignSafeTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/speedUpTransaction": {
+            "post": {
+                "description": "re-signs a pending transaction with the same nonce and higher fees.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Speed up transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.ReplaceTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/submitTransaction": {
+            "post": {
+                "description": "Signs and submits txn onto the network.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Submits transaction",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.SignAndSubmitTxn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/transferNft": {
+            "post": {
+                "description": "transfers an ERC-721 token, or an amount of an ERC-1155 token, with safeTransferFrom.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Transfer NFT",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.NftTransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/transferToken": {
+            "post": {
+                "description": "transfers ERC-20 tokens from the wallet. The amount is in whole tokens, e.g. \"1.5\".",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Transfer tokens",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenTransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/transferTokenFrom": {
+            "post": {
+                "description": "transfers ERC-20 tokens the wallet is allowed to spend from another address. The amount is in whole tokens, e.g. \"1.5\".",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Transfer tokens from",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenTransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/upgradeProxy": {
+            "post": {
+                "description": "points an ERC-1967 proxy to a new implementation, deploying it first unless an existing one is given. UUPS proxies are upgraded with upgradeToAndCall, or upgradeTo for contracts before OpenZeppelin 5, transparent proxies through the ProxyAdmin in their admin slot. The proxy registers the new implementation ABI as a new version.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Upgrade proxy",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.UpgradeProxyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        },
+        "/verifySignatureOffChain": {
+            "post": {
+                "description": "verifies offline that a message was signed by the wallet, in the mode it was signed with. v may be 0, 1, 27 or 28.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Verify signature",
+                "parameters": [
+                    {
+                        "description": "Request Body",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/utils.VerifyMsgRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ResponseBody"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "utils.BatchCallRequest": {
+            "type": "object",
+            "properties": {
+                "blockNumber": {
+                    "description": "BlockNumber is a decimal or 0x prefixed block number, latest if empty",
+                    "type": "string",
+                    "example": "latest"
+                },
+                "calls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.ContractCall"
+                    }
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                }
+            }
+        },
+        "utils.BatchExecuteRequest": {
+            "type": "object",
+            "properties": {
+                "account": {
+                    "description": "Account is the smart account owned by the wallet that runs the calls",
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "accountType": {
+                    "description": "AccountType selects the executeBatch signature, simpleAccount for\nexecuteBatch(address[],uint256[],bytes[]) or simpleAccountV06 for\nexecuteBatch(address[],bytes[])",
+                    "type": "string",
+                    "example": "simpleAccount"
+                },
+                "calls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.ContractCall"
+                    }
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.CallContractRequest": {
+            "type": "object",
+            "properties": {
+                "blockNumber": {
+                    "description": "BlockNumber, decimal or 0x hex, or BlockTag (latest, pending, safe,\nfinalized or earliest) selects the block state the call runs on.\nLatest by default",
+                    "type": "string",
+                    "example": "17000000"
+                },
+                "blockTag": {
+                    "type": "string",
+                    "example": "latest"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "type": "string",
+                    "example": "hello"
+                },
+                "contractName": {
+                    "description": "ContractName picks a registered contract for To and a missing ContractABI",
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
+                "from": {
+                    "description": "From overrides the caller, by default the wallet. walletId is not\nneeded with it",
+                    "type": "string"
+                },
+                "gas": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "store"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "to": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ContractCall": {
+            "type": "object",
+            "properties": {
+                "allowFailure": {
+                    "description": "AllowFailure is only used by batched reads. A failing call without it\nfails the whole batch.",
+                    "type": "boolean"
+                },
+                "contractABI": {
+                    "type": "string"
+                },
+                "contractName": {
+                    "description": "ContractName resolves the address, and without contractABI the ABI,\nfrom the contract registry",
+                    "type": "string"
+                },
+                "data": {
+                    "type": "string",
+                    "example": "0x2e64cec1"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "retrieve"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "to": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "value": {
+                    "description": "Value is only used by batched writes",
+                    "type": "string",
+                    "example": "0"
+                }
+            }
+        },
+        "utils.CreateSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "confirmations": {
+                    "description": "Confirmations a block needs before its events are delivered, the\nconfirmations of the chain if not set",
+                    "type": "integer"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "contractABI": {
+                    "type": "string"
+                },
+                "contractName": {
+                    "description": "ContractName resolves the contract, and without contractABI its ABI,\nfrom the contract registry",
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Events are the event names to deliver, all events of the ABI if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Transfer"
+                    ]
+                },
+                "fromBlock": {
+                    "description": "FromBlock is a decimal or 0x prefixed block to deliver events from,\nthe next block if empty",
+                    "type": "string",
+                    "example": "latest"
+                },
+                "secret": {
+                    "description": "Secret signs the webhook payloads, a random one is generated if empty",
+                    "type": "string"
+                },
+                "webhookUrl": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/kms"
+                }
+            }
+        },
+        "utils.DecodeCalldataRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "description": "ContractABI, or the registered ABI of the contract at To or named\nContractName. Without either the bundled selector database is used",
+                    "type": "string"
+                },
+                "contractName": {
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "string",
+                    "example": "0x6057361d0000000000000000000000000000000000000000000000000000000000000023"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                }
+            }
+        },
+        "utils.DeleteSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.DeployContractRequest": {
+            "type": "object",
+            "properties": {
+                "abi": {
+                    "type": "string"
+                },
+                "artifact": {
+                    "description": "Artifact is a Hardhat or Foundry artifact JSON, used instead of\nbyteCode and abi. Its link references are resolved from Libraries,\nthen from contracts registered under the library name, then by\ndeploying LibraryArtifacts",
+                    "type": "object"
+                },
+                "byteCode": {
+                    "type": "string"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractName": {
+                    "description": "ContractName registers the deployed contract under this alias",
+                    "type": "string"
+                },
+                "deployMode": {
+                    "description": "DeployMode is create for a nonce based address, or create2 for an\naddress derived from salt and init code through the CREATE2 factory of\nthe chain",
+                    "type": "string",
+                    "example": "create2"
+                },
+                "libraries": {
+                    "description": "Libraries maps library names, or source:name, to addresses",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "libraryArtifacts": {
+                    "description": "LibraryArtifacts maps library names, or source:name, to the artifacts\nof libraries to deploy when they are not deployed yet",
+                    "type": "object"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "salt": {
+                    "description": "Salt is 32 bytes as 0x prefixed hex, shorter values are left padded.\nAny other text is hashed with keccak256. Zero if empty.",
+                    "type": "string",
+                    "example": "0x01"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.DeployProxyRequest": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "description": "Admin of a transparent proxy, the wallet if empty. OpenZeppelin 5\nproxies deploy their own ProxyAdmin owned by it",
+                    "type": "string"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractName": {
+                    "description": "ContractName registers the proxy under this alias",
+                    "type": "string"
+                },
+                "implementation": {
+                    "$ref": "#/definitions/utils.ProxyImplementation"
+                },
+                "proxyByteCode": {
+                    "description": "ProxyByteCode is the creation bytecode of the proxy contract, taking\n(implementation, data) for uups and (implementation, admin, data) for\ntransparent proxies",
+                    "type": "string"
+                },
+                "proxyKind": {
+                    "description": "ProxyKind is uups for an ERC1967Proxy, transparent for a\nTransparentUpgradeableProxy",
+                    "type": "string",
+                    "example": "uups"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.DeploySafeRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "factory": {
+                    "type": "string"
+                },
+                "fallbackHandler": {
+                    "type": "string"
+                },
+                "owners": {
+                    "description": "Owners of the Safe, the wallet alone if empty",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "saltNonce": {
+                    "description": "SaltNonce picks one of the Safes with the same owners and threshold",
+                    "type": "integer"
+                },
+                "singleton": {
+                    "description": "Singleton, Factory and FallbackHandler override the Safe v1.3.0\ncontracts",
+                    "type": "string"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "threshold": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.EIP712SignRequest": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.EncodeCalldataRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "description": "ContractABI, or the registered ABI of the contract at To or named\nContractName",
+                    "type": "string"
+                },
+                "contractName": {
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "store"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "to": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                }
+            }
+        },
+        "utils.EstimateGasRequest": {
+            "type": "object",
+            "properties": {
+                "byteCode": {
+                    "type": "string"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "type": "string"
+                },
+                "contractName": {
+                    "description": "ContractName picks a registered contract for To and a missing ContractABI",
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "string"
+                },
+                "gas": {
+                    "type": "integer"
+                },
+                "isContractTxn": {
+                    "type": "boolean"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "1.5 ether"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ExecuteSafeTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "ownerWalletIds": {
+                    "description": "OwnerWalletIds sign the transaction with KMS owners, Signatures adds\nsignatures collected elsewhere",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "safe": {
+                    "type": "string"
+                },
+                "signatures": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.SafeSignature"
+                    }
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "transaction": {
+                    "$ref": "#/definitions/utils.SafeTransaction"
+                },
+                "walletId": {
+                    "description": "WalletId sends the transaction and pays its gas, it need not be an\nowner",
+                    "type": "string"
+                }
+            }
+        },
+        "utils.GetContractRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address or ContractName identifies the contract",
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractName": {
+                    "type": "string",
+                    "example": "storage"
+                }
+            }
+        },
+        "utils.GetProxyRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractName": {
+                    "type": "string"
+                },
+                "proxy": {
+                    "description": "Proxy or ContractName identifies the proxy",
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                }
+            }
+        },
+        "utils.GetSafeRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "safe": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.GetTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "txHash": {
+                    "type": "string",
+                    "example": "0x2f1c5c2b44f771e942a8506148e256f94f1a464babc938ae0690c6e34cd79190"
+                }
+            }
+        },
+        "utils.ListContractsRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                }
+            }
+        },
+        "utils.ListSubscriptionsRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ListTransactionsRequest": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "example": "pending"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.NftApprovalRequest": {
+            "type": "object",
+            "properties": {
+                "approved": {
+                    "type": "boolean"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.NftBalanceBatchRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "owners": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tokenIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "utils.NftBalanceRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address defaults to the address of the wallet",
+                    "type": "string"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "tokenId": {
+                    "description": "TokenId is mandatory for ERC-1155 and ignored for ERC-721, whose balance\nis the number of tokens owned",
+                    "type": "string",
+                    "example": "1"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.NftBatchTransferRequest": {
+            "type": "object",
+            "properties": {
+                "amounts": {
+                    "description": "Amounts are only used by ERC-1155 and default to 1 each",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "data": {
+                    "type": "string",
+                    "example": "0x"
+                },
+                "from": {
+                    "description": "From defaults to the address of the wallet",
+                    "type": "string"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "tokenIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.NftTokenRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "tokenId": {
+                    "type": "string",
+                    "example": "1"
+                }
+            }
+        },
+        "utils.NftTransferRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is only used by ERC-1155 and defaults to 1",
+                    "type": "string",
+                    "example": "1"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contract": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "data": {
+                    "description": "Data is passed to the receiver hook, hex encoded",
+                    "type": "string",
+                    "example": "0x"
+                },
+                "from": {
+                    "description": "From defaults to the address of the wallet",
+                    "type": "string"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "tokenId": {
+                    "type": "string",
+                    "example": "1"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.Param": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "type": "string",
+                    "example": "uint256"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "42"
+                }
+            }
+        },
+        "utils.Permit2Request": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "deadline": {
+                    "description": "Deadline is the unix timestamp the signature is valid until, an hour\nfrom now if empty",
+                    "type": "integer"
+                },
+                "expiration": {
+                    "description": "Expiration is the unix timestamp allowances end at, in 30 days if\nempty",
+                    "type": "integer"
+                },
+                "nonce": {
+                    "description": "Nonce is the unordered nonce of a transfer, the first unused one if\nempty",
+                    "type": "string"
+                },
+                "permit2": {
+                    "description": "Permit2 overrides the canonical Permit2 address",
+                    "type": "string"
+                },
+                "primaryType": {
+                    "description": "PrimaryType is PermitSingle or PermitBatch for allowances, and\nPermitTransferFrom or PermitBatchTransferFrom for one-time transfers",
+                    "type": "string",
+                    "example": "PermitSingle"
+                },
+                "spender": {
+                    "type": "string"
+                },
+                "tokens": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Permit2Token"
+                    }
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.Permit2Token": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is in whole tokens, or \"max\"",
+                    "type": "string",
+                    "example": "1.5"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                }
+            }
+        },
+        "utils.PermitRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is in whole tokens, or \"max\" for an unlimited allowance",
+                    "type": "string",
+                    "example": "1.5"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "deadline": {
+                    "description": "Deadline is a unix timestamp, an hour from now if empty",
+                    "type": "integer"
+                },
+                "spender": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                },
+                "version": {
+                    "description": "Version overrides the EIP-712 domain version read from the token",
+                    "type": "string"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ProxyImplementation": {
+            "type": "object",
+            "properties": {
+                "abi": {
+                    "description": "ABI is base64 encoded like on deployContract. It is taken from the\ncontract registry for an existing implementation when empty",
+                    "type": "string"
+                },
+                "address": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "byteCode": {
+                    "type": "string"
+                },
+                "initializer": {
+                    "description": "Initializer is called through the proxy with InitializerParams when\nset, e.g. initialize",
+                    "type": "string",
+                    "example": "initialize"
+                },
+                "initializerParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Param"
+                    }
+                }
+            }
+        },
+        "utils.RegisterContractRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "storage"
+                    ]
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ReplaceTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "gasPrice": {
+                    "description": "optional fees, at least 10% above the ones of the replaced txn",
+                    "type": "string",
+                    "example": "30 gwei"
+                },
+                "maxFeePerGas": {
+                    "type": "string"
+                },
+                "maxPriorityFeePerGas": {
+                    "type": "string"
+                },
+                "txHash": {
+                    "type": "string"
+                },
+                "walletId": {
+                    "type": "string"
                 }
             }
         },
         "utils.ResponseBody": {
             "type": "object",
             "properties": {
-                "data": {},
-                "message": {
+                "data": {},
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.SafeSignature": {
+            "type": "object",
+            "properties": {
+                "signature": {
+                    "type": "string"
+                },
+                "signer": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.SafeTransaction": {
+            "type": "object",
+            "properties": {
+                "baseGas": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "string",
+                    "example": "0x"
+                },
+                "gasPrice": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "gasToken": {
+                    "type": "string"
+                },
+                "nonce": {
+                    "type": "string",
+                    "example": "0"
+                },
+                "operation": {
+                    "description": "Operation is 0 for a call and 1 for a delegatecall",
+                    "type": "integer"
+                },
+                "refundReceiver": {
+                    "type": "string"
+                },
+                "safeTxGas": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "0"
+                }
+            }
+        },
+        "utils.SendUserOperationRequest": {
+            "type": "object",
+            "properties": {
+                "callGasLimit": {
+                    "description": "gas limits and fees are estimated unless set",
+                    "type": "integer"
+                },
+                "calls": {
+                    "description": "Calls run from the account, through execute for one call and\nexecuteBatch for more",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.ContractCall"
+                    }
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "entryPoint": {
                     "type": "string"
                 },
-                "status": {
+                "entryPointVersion": {
+                    "type": "string",
+                    "example": "v0.7"
+                },
+                "factory": {
+                    "type": "string"
+                },
+                "factoryData": {
+                    "type": "string"
+                },
+                "maxFeePerGas": {
+                    "type": "string",
+                    "example": "30 gwei"
+                },
+                "maxPriorityFeePerGas": {
+                    "type": "string",
+                    "example": "2 gwei"
+                },
+                "nonceKey": {
+                    "type": "integer"
+                },
+                "paymaster": {
+                    "description": "Paymaster sponsors the operation with PaymasterData. For v0.6 both\nare joined into paymasterAndData",
+                    "type": "string"
+                },
+                "paymasterData": {
+                    "type": "string"
+                },
+                "paymasterPostOpGasLimit": {
+                    "type": "integer"
+                },
+                "paymasterVerificationGasLimit": {
+                    "type": "integer"
+                },
+                "preVerificationGas": {
+                    "type": "integer"
+                },
+                "salt": {
+                    "type": "integer"
+                },
+                "skipSubmit": {
+                    "description": "SkipSubmit returns the signed operation without sending it",
+                    "type": "boolean"
+                },
+                "verificationGasLimit": {
+                    "type": "integer"
+                },
+                "walletId": {
                     "type": "string"
                 }
             }
@@ -465,13 +2685,20 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "chainId": {
-                    "type": "integer",
-                    "example": 80001
+                    "type": "string",
+                    "example": "80001"
                 },
                 "contractABI": {
                     "type": "string",
                     "example": ""
                 },
+                "contractName": {
+                    "description": "ContractName picks a registered contract for To and a missing ContractABI",
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
                 "dAppId": {
                     "type": "string"
                 },
@@ -491,7 +2718,7 @@ const docTemplate = `{
                     "example": "store"
                 },
                 "value": {
-                    "type": "integer"
+                    "type": "string"
                 },
                 "walletId": {
                     "type": "string"
@@ -501,9 +2728,20 @@ const docTemplate = `{
         "utils.SignAndSubmitTxn": {
             "type": "object",
             "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
                 "contractABI": {
                     "type": "string"
                 },
+                "contractName": {
+                    "description": "ContractName picks a registered contract for To and a missing ContractABI",
+                    "type": "string"
+                },
+                "contractVersion": {
+                    "type": "integer"
+                },
                 "data": {
                     "type": "string"
                 },
@@ -513,6 +2751,10 @@ const docTemplate = `{
                 "gasLimit": {
                     "type": "integer"
                 },
+                "gasPrice": {
+                    "type": "string",
+                    "example": "30 gwei"
+                },
                 "isContractTxn": {
                     "description": "Params        []interface{} ` + "`" + `json:\"params\"` + "`" + `",
                     "type": "boolean"
@@ -526,11 +2768,19 @@ const docTemplate = `{
                         "$ref": "#/definitions/utils.Param"
                     }
                 },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
                 "to": {
                     "type": "string"
                 },
                 "value": {
-                    "type": "integer"
+                    "type": "string",
+                    "example": "1.5 ether"
+                },
+                "waitForReceipt": {
+                    "description": "WaitForReceipt waits until the txn is mined and returns its decoded events",
+                    "type": "boolean"
                 },
                 "walletId": {
                     "type": "string"
@@ -543,20 +2793,262 @@ const docTemplate = `{
                 "message": {
                     "type": "string"
                 },
+                "mode": {
+                    "description": "Mode is personal_sign (default), bytes, hash, validator or keccak",
+                    "type": "string",
+                    "example": "personal_sign"
+                },
+                "validator": {
+                    "description": "Validator is the address of the validator contract of validator mode",
+                    "type": "string"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.SignSafeTransactionRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "safe": {
+                    "type": "string"
+                },
+                "transaction": {
+                    "$ref": "#/definitions/utils.SafeTransaction"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.SmartAccountRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "entryPoint": {
+                    "description": "EntryPoint and Factory override the canonical EntryPoint and\nSimpleAccountFactory of the version",
+                    "type": "string"
+                },
+                "entryPointVersion": {
+                    "description": "EntryPointVersion is v0.6 or v0.7, the default",
+                    "type": "string",
+                    "example": "v0.7"
+                },
+                "factory": {
+                    "type": "string"
+                },
+                "factoryData": {
+                    "description": "FactoryData is the 0x hex call to the factory that deploys the\naccount, createAccount(wallet, salt) by default",
+                    "type": "string"
+                },
+                "salt": {
+                    "type": "integer"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.TokenAllowanceRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "owner": {
+                    "description": "Owner defaults to the address of the wallet",
+                    "type": "string"
+                },
+                "spender": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.TokenApproveRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount is in whole tokens, or \"max\" for an unlimited allowance",
+                    "type": "string",
+                    "example": "1.5"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "spender": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.TokenBalanceRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address defaults to the address of the wallet",
+                    "type": "string"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.TokenMetadataRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                }
+            }
+        },
+        "utils.TokenTransferRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "string",
+                    "example": "1.5"
+                },
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "from": {
+                    "description": "From is only used by transferFrom, which moves tokens the wallet is\nallowed to spend",
+                    "type": "string"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"
+                },
+                "walletId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.TransactionEventsRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "description": "ChainId defaults to the chain of a tracked transaction, or the default chain",
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractABI": {
+                    "type": "string"
+                },
+                "txHash": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.UpgradeProxyRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "contractName": {
+                    "type": "string"
+                },
+                "implementation": {
+                    "$ref": "#/definitions/utils.ProxyImplementation"
+                },
+                "proxy": {
+                    "description": "Proxy or ContractName identifies the proxy",
+                    "type": "string",
+                    "example": "0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"
+                },
+                "proxyKind": {
+                    "description": "ProxyKind is uups to upgrade through the implementation, transparent to\nupgrade through the admin of the proxy",
+                    "type": "string",
+                    "example": "uups"
+                },
+                "skipSimulation": {
+                    "type": "boolean"
+                },
+                "upgradeAndCall": {
+                    "description": "UpgradeAndCall always uses upgradeToAndCall or upgradeAndCall instead of\nfalling back to upgradeTo or upgrade when they revert without initializer",
+                    "type": "boolean"
+                },
                 "walletId": {
                     "type": "string"
                 }
             }
         },
+        "utils.UserOperationReceiptRequest": {
+            "type": "object",
+            "properties": {
+                "chainId": {
+                    "type": "string",
+                    "example": "80001"
+                },
+                "userOpHash": {
+                    "type": "string"
+                }
+            }
+        },
         "utils.VerifyMsgRequest": {
             "type": "object",
             "properties": {
                 "message": {
                     "type": "string"
                 },
+                "mode": {
+                    "description": "Mode and Validator are the ones the message was signed with",
+                    "type": "string",
+                    "example": "personal_sign"
+                },
                 "signature": {
                     "type": "string"
                 },
+                "validator": {
+                    "type": "string"
+                },
                 "walletId": {
                     "type": "string"
                 }
@@ -565,8 +3057,21 @@ const docTemplate = `{
         "utils.WalletBalanceRequest": {
             "type": "object",
             "properties": {
+                "blockNumber": {
+                    "description": "BlockNumber is a decimal or 0x prefixed block number, latest if empty",
+                    "type": "string",
+                    "example": "latest"
+                },
                 "chainId": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "80001"
+                },
+                "tokens": {
+                    "description": "Tokens are ERC-20 contracts whose balances are returned as well",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "walletId": {
                     "type": "string"
@@ -5,19 +5,22 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 	"wallet-kms/store"
 	"wallet-kms/utils"
 	"wallet-kms/vault"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -29,11 +32,18 @@ import (
 )
 
 type Service struct {
-	e        *echo.Echo
-	db       store.DB
-	vault    vault.Vault
-	config   *utils.Config
-	executor *executor.Executor
+	e             *echo.Echo
+	db            store.DB
+	nonces        *NonceManager
+	tracker       *Tracker
+	gasBump       *GasBumpPolicy
+	subscriptions *Subscriptions
+	contracts     *ContractRegistry
+	vault         vault.Vault
+	config        *utils.Config
+	executor      *executor.Executor
+	// bumping is set while BumpStuckTransactions runs
+	bumping atomic.Bool
 }
 
 func initService() *Service {
@@ -60,20 +70,54 @@ func initService() *Service {
 		log.Panic("error initializing vault : ", err.Error())
 	}
 	serve.db = db
+	serve.nonces = NewNonceManager(db)
+	confirmations, _ := strconv.ParseUint(os.Getenv("TXN_CONFIRMATIONS"), 10, 64)
+	serve.tracker = NewTracker(db, confirmations)
+	if err := serve.tracker.Reindex(); err != nil {
+		log.Panic("error indexing open transactions : ", err.Error())
+	}
+	serve.gasBump = gasBumpPolicyFromEnv()
+	serve.subscriptions = NewSubscriptions(db, webhookPolicyFromEnv())
+	serve.contracts = NewContractRegistry(db)
 	serve.vault = vault
-	if os.Getenv("AUTH_TOKEN") == "" || os.Getenv("PROXY_URL") == "" || os.Getenv("ENDPOINT") == "" || os.Getenv("WALLET_INSTANCE_ID") == "" ||
+	if os.Getenv("AUTH_TOKEN") == "" || os.Getenv("PROXY_URL") == "" || (os.Getenv("ENDPOINT") == "" && os.Getenv("CHAINS_CONFIG") == "") || os.Getenv("WALLET_INSTANCE_ID") == "" ||
 		os.Getenv("SUBSCRIPTION_ID") == "" || os.Getenv("SCHEDULER_DURATION") == "" {
 		log.Panic("environment variables not set.")
 	}
 	serve.config = &utils.Config{
-		AuthToken:      os.Getenv("AUTH_TOKEN"),
-		ProxyUrl:       os.Getenv("PROXY_URL"),
-		Endpoint:       os.Getenv("ENDPOINT"),
-		InstanceId:     os.Getenv("WALLET_INSTANCE_ID"),
-		SubscriptionId: os.Getenv("SUBSCRIPTION_ID"),
+		AuthToken:            os.Getenv("AUTH_TOKEN"),
+		ProxyUrl:             os.Getenv("PROXY_URL"),
+		Endpoint:             os.Getenv("ENDPOINT"),
+		InstanceId:           os.Getenv("WALLET_INSTANCE_ID"),
+		SubscriptionId:       os.Getenv("SUBSCRIPTION_ID"),
+		NonceSync:            os.Getenv("NONCE_PLATFORM_SYNC") == "true",
+		BalanceProxyFallback: os.Getenv("BALANCE_PROXY_FALLBACK") == "true",
+	}
+	if os.Getenv("CHAINS_CONFIG") != "" {
+		serve.config.Chains, err = utils.LoadChainRegistry(os.Getenv("CHAINS_CONFIG"))
+	} else {
+		// the chain served by ENDPOINT is only known once it answers
+		for {
+			serve.config.Chains, err = utils.NewSingleChainRegistry(context.Background(), serve.config, serve.config.Endpoint)
+			if err == nil {
+				break
+			}
+			log.Printf("waiting for ENDPOINT, retrying in 5s : %s", err.Error())
+			time.Sleep(5 * time.Second)
+		}
+	}
+	if err != nil {
+		log.Panic("error initializing chain registry : ", err.Error())
+	}
+	if err := serve.config.Chains.StartPools(serve.config, utils.RpcPoolOptionsFromEnv()); err != nil {
+		log.Panic("error initializing rpc pools : ", err.Error())
 	}
 	serve.e = e
 	go serve.ScheduleService()
+	go serve.NonceReconciler()
+	go serve.RpcHealthChecker()
+	go serve.TransactionTracker()
+	go serve.SubscriptionWatcher()
 	return &serve
 }
 
@@ -83,6 +127,8 @@ func Run() {
 
 	//healthcheck
 	g.GET("/health", healthCheck)
+	g.GET("/chains", service.listChains)
+	g.GET("/rpcStats", service.rpcStats)
 
 	//wallet API
 	g.POST("/createWallet", service.createWallet)
@@ -95,6 +141,46 @@ func Run() {
 	g.POST("/signEIP712Tx", service.signEIP712Txn)
 	g.POST("/signMessage", service.signMessage)
 	g.POST("/verifySignatureOffChain", service.verifySignatureOffChain)
+	g.POST("/getTransaction", service.getTransaction)
+	g.POST("/listTransactions", service.listTransactions)
+	g.POST("/speedUpTransaction", service.speedUpTransaction)
+	g.POST("/cancelTransaction", service.cancelTransaction)
+	g.POST("/getTokenMetadata", service.getTokenMetadata)
+	g.POST("/getTokenBalance", service.getTokenBalance)
+	g.POST("/getTokenAllowance", service.getTokenAllowance)
+	g.POST("/transferToken", service.transferToken)
+	g.POST("/transferTokenFrom", service.transferTokenFrom)
+	g.POST("/approveToken", service.approveToken)
+	g.POST("/getNftOwner", service.getNftOwner)
+	g.POST("/getNftBalance", service.getNftBalance)
+	g.POST("/getNftBalanceBatch", service.getNftBalanceBatch)
+	g.POST("/getNftUri", service.getNftUri)
+	g.POST("/transferNft", service.transferNft)
+	g.POST("/batchTransferNft", service.batchTransferNft)
+	g.POST("/setNftApprovalForAll", service.setNftApprovalForAll)
+	g.POST("/batchCallContract", service.batchCallContract)
+	g.POST("/batchExecute", service.batchExecute)
+	g.POST("/getTransactionEvents", service.getTransactionEvents)
+	g.POST("/createSubscription", service.createSubscription)
+	g.POST("/listSubscriptions", service.listSubscriptions)
+	g.POST("/deleteSubscription", service.deleteSubscription)
+	g.POST("/registerContract", service.registerContract)
+	g.POST("/getContract", service.getContract)
+	g.POST("/listContracts", service.listContracts)
+	g.POST("/deployProxy", service.deployProxy)
+	g.POST("/upgradeProxy", service.upgradeProxy)
+	g.POST("/getProxy", service.getProxy)
+	g.POST("/encodeCalldata", service.encodeCalldata)
+	g.POST("/decodeCalldata", service.decodeCalldata)
+	g.POST("/getSmartAccount", service.getSmartAccount)
+	g.POST("/sendUserOperation", service.sendUserOperation)
+	g.POST("/getUserOperationReceipt", service.getUserOperationReceipt)
+	g.POST("/deploySafe", service.deploySafe)
+	g.POST("/getSafe", service.getSafe)
+	g.POST("/signSafeTransaction", service.signSafeTransaction)
+	g.POST("/executeSafeTransaction", service.executeSafeTransaction)
+	g.POST("/signPermit", service.signPermit)
+	g.POST("/signPermit2", service.signPermit2)
 
 	service.e.Logger.Fatal(service.e.Start(":8889"))
 }
@@ -105,6 +191,23 @@ func healthCheck(c echo.Context) error {
 	})
 }
 
+// getWallet reads a wallet from the wallet db.
+func (s *Service) getWallet(id string) (*Wallet, error) {
+	walletId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	walletBytes, err := s.db.Get([]byte(utils.NAMESPACE), walletId.NodeID())
+	if err != nil {
+		return nil, err
+	}
+	wallet := &Wallet{}
+	if err := json.Unmarshal(walletBytes, wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
 // createWallet godoc
 // @Summary Creates Wallet
 // @Description Creates a wallet reference and generates keys for the wallet.
@@ -178,24 +281,68 @@ func (s *Service) submitTransaction(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
+	if u.IsContractTxn {
+		if u.To, u.ContractABI, err = s.resolveContract(u.ChainId.String(), u.To, u.ContractName, u.ContractABI, u.ContractVersion); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	}
 	var to common.Address
 	if u.To != "" {
 		to = common.HexToAddress(u.To)
 	}
+	value, err := u.Value.Wei()
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	gasPrice, err := u.GasPrice.Wei()
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
 
-	client, err := utils.GetEthereumClient(ctx, s.config)
+	chain, err := s.config.Chains.Get(u.ChainId.String())
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	chainId, err := client.ChainID(ctx)
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
 	if err != nil {
-		s.e.Logger.Errorf(err.Error())
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if !u.SkipSimulation {
+		msg := ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: value}
+		var contractABI *abi.ABI
+		if u.IsContractTxn {
+			parsed, err := abi.JSON(strings.NewReader(u.ContractABI))
+			if err != nil {
+				return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+			}
+			params, err := utils.ConvertParams(parsed, u.Method, u.Params)
+			if err != nil {
+				return utils.BadRequestResponse(c, "error converting params "+err.Error(), nil)
+			}
+			if msg.Data, err = parsed.Pack(u.Method, params...); err != nil {
+				return utils.BadRequestResponse(c, "error packing ABI : "+err.Error(), nil)
+			}
+			contractABI = &parsed
+		} else if u.Data != "" {
+			if msg.Data, err = base64.RawStdEncoding.DecodeString(u.Data); err != nil {
+				return utils.BadRequestResponse(c, "error decoding data : "+err.Error(), nil)
+			}
+		}
+		if err := simulateTransaction(ctx, client, msg, contractABI); err != nil {
+			return transactFailureResponse(c, err)
+		}
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
 	}
-	nonce, err := utils.GetNonceFromPlatform(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
+	nonce, err := s.nonces.Reserve(ctx, client, wallet, chainId)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
 	var txnHash string
+	var sent *types.Transaction
+	defer s.releaseUnusedNonce(wallet, chainId, nonce, &txnHash)
 	if u.IsContractTxn {
 		var contractABI string
 		if u.ContractABI != "" {
@@ -211,17 +358,23 @@ func (s *Service) submitTransaction(c echo.Context) error {
 		if err != nil {
 			return utils.UnexpectedFailureResponse(c, "error initializing transactor opts : "+err.Error(), nil)
 		}
-		if u.Value > 0 {
-			txnOpts.Value = big.NewInt(u.Value)
+		if value.Sign() > 0 {
+			txnOpts.Value = value
 		}
 		txnOpts.Nonce = big.NewInt(int64(nonce))
-		if u.Gas != 0 {
-			txnOpts.GasPrice = big.NewInt(int64(u.Gas))
+		if u.GasPrice.IsSet() {
+			txnOpts.GasPrice = gasPrice
+		} else if u.Gas != 0 {
+			txnOpts.GasPrice = new(big.Int).SetUint64(u.Gas)
 		}
 		if u.GasLimit != 0 {
 			txnOpts.GasLimit = u.GasLimit
 		}
-		params, err := utils.ConvertParamsAsPerTypes(u.Params)
+		parsed, err := abi.JSON(strings.NewReader(contractABI))
+		if err != nil {
+			return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+		}
+		params, err := utils.ConvertParams(parsed, u.Method, u.Params)
 		if err != nil {
 			return utils.BadRequestResponse(c, "error converting params "+err.Error(), nil)
 		}
@@ -230,10 +383,14 @@ func (s *Service) submitTransaction(c echo.Context) error {
 			return utils.UnexpectedFailureResponse(c, "error sending txn to contract : "+err.Error(), nil)
 		}
 		txnHash = txn.Hash().String()
+		s.trackTransaction(wallet, chainId, txn, "txn", "")
+		sent = txn
 	} else {
-		gasPrice, err := client.SuggestGasPrice(ctx)
-		if err != nil {
-			return utils.UnexpectedFailureResponse(c, "error calculating gas price : "+err.Error(), nil)
+		if !u.GasPrice.IsSet() {
+			gasPrice, err = client.SuggestGasPrice(ctx)
+			if err != nil {
+				return utils.UnexpectedFailureResponse(c, "error calculating gas price : "+err.Error(), nil)
+			}
 		}
 		var dataBytes []byte
 		if u.Data != "" {
@@ -246,7 +403,7 @@ func (s *Service) submitTransaction(c echo.Context) error {
 		if u.Gas > 0 {
 			estimatedGas = u.Gas
 		} else {
-			estimatedGas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: big.NewInt(u.Value), Data: dataBytes, GasPrice: gasPrice})
+			estimatedGas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: value, Data: dataBytes, GasPrice: gasPrice})
 			if err != nil {
 				return utils.UnexpectedFailureResponse(c, "error estimating gas : "+err.Error(), nil)
 			}
@@ -255,12 +412,15 @@ func (s *Service) submitTransaction(c echo.Context) error {
 			Nonce:    nonce,
 			GasPrice: gasPrice,
 			Gas:      estimatedGas,
-			Value:    big.NewInt(u.Value),
+			Value:    value,
 			Data:     dataBytes,
 			To:       &to,
 		})
 		signer := types.LatestSignerForChainID(chainId)
-		signature, err := SignTransactionHash(ctx, wallet, s.vault, txn.Hash().Bytes())
+		signature, err := SignTransactionHash(ctx, wallet, s.vault, signer.Hash(txn).Bytes())
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error signing txn : "+err.Error(), nil)
+		}
 		txn, err = txn.WithSignature(signer, signature)
 		if err != nil {
 			return utils.UnexpectedFailureResponse(c, err.Error(), nil)
@@ -269,12 +429,21 @@ func (s *Service) submitTransaction(c echo.Context) error {
 			return utils.UnexpectedFailureResponse(c, "error executing txn : "+err.Error(), nil)
 		}
 		txnHash = txn.Hash().String()
+		s.trackTransaction(wallet, chainId, txn, "txn", "")
+		sent = txn
+	}
+	s.syncPlatformNonce(wallet, chainId, txnHash, "txn")
+	res := &utils.DeployContractResponse{TxnHash: txnHash}
+	if u.WaitForReceipt {
+		var contractABI *abi.ABI
+		if parsed, err := abi.JSON(strings.NewReader(u.ContractABI)); err == nil && u.IsContractTxn {
+			contractABI = &parsed
+		}
+		if res.Receipt, err = s.waitForReceipt(ctx, client, chainId.String(), sent, contractABI); err != nil {
+			return utils.SendSuccessResponse(c, "Signed and executed txn successfully, receipt not available : "+err.Error(), res)
+		}
 	}
-	err = utils.UpdatePlatformNonce(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
-	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
-	}
-	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployContractResponse{TxnHash: txnHash})
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", res)
 }
 
 // deployContract godoc
@@ -304,55 +473,113 @@ func (s *Service) deployContract(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	client, err := utils.GetEthereumClient(ctx, s.config)
+	chain, err := s.config.Chains.Get(u.ChainId.String())
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	chainId, err := client.ChainID(ctx)
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
 	if err != nil {
-		s.e.Logger.Errorf(err.Error())
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
 	}
 	var txnHash string
-	rawDecodedText, err := base64.StdEncoding.DecodeString(u.ABI)
-	if err != nil {
+	var artifact *contractArtifact
+	var rawDecodedText []byte
+	if len(u.Artifact) > 0 {
+		if artifact, err = parseArtifact(u.Artifact); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		rawDecodedText = []byte(artifact.ABI)
+	} else if rawDecodedText, err = base64.StdEncoding.DecodeString(u.ABI); err != nil {
 		s.e.Logger.Errorf(err.Error())
 		return utils.UnexpectedFailureResponse(c, "ABI string error "+err.Error(), nil)
+	} else if strings.Contains(u.ByteCode, "__") {
+		return utils.BadRequestResponse(c, "byteCode has unlinked libraries, send the artifact instead", nil)
 	}
-	nonce, err := utils.GetNonceFromPlatform(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
+	contractABI, err := abi.JSON(strings.NewReader(string(rawDecodedText)))
+	if err != nil {
+		return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+	}
+	params, err := utils.ConvertParams(contractABI, "", u.Params)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error converting params "+err.Error(), nil)
+	}
+	args, err := contractABI.Pack("", params...)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error packing constructor params : "+err.Error(), nil)
+	}
+	// everything that can fail is checked before libraries are deployed
+	var factory common.Address
+	var salt [32]byte
+	switch u.DeployMode {
+	case "", utils.DeployModeCreate:
+	case utils.DeployModeCreate2:
+		if salt, err = parseSalt(u.Salt); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		factory = create2Factory(chain)
+		factoryCode, err := client.CodeAt(ctx, factory, nil)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error reading factory code : "+err.Error(), nil)
+		}
+		if len(factoryCode) == 0 {
+			return utils.BadRequestResponse(c, "create2 factory is not deployed at "+factory.String()+" on chain "+chain.ChainId, nil)
+		}
+	default:
+		return utils.BadRequestResponse(c, "unsupported deploy mode "+u.DeployMode, nil)
+	}
+	var libraries map[string]string
+	if artifact != nil {
+		linker := &libraryLinker{s: s, client: client, chain: chain, wallet: wallet, libraries: u.Libraries,
+			artifacts: u.LibraryArtifacts, skipSimulation: u.SkipSimulation, linked: make(map[string]string)}
+		if u.ByteCode, err = linker.link(ctx, artifact, 0); err != nil {
+			return transactFailureResponse(c, err)
+		}
+		libraries = linker.linked
+	}
+	initCode := append(common.FromHex(u.ByteCode), args...)
+	if u.DeployMode == utils.DeployModeCreate2 {
+		return s.deployContractCreate2(c, wallet, chain, client, chainId, factory, salt, &contractABI, string(rawDecodedText), initCode, libraries, u)
+	}
+	if !u.SkipSimulation {
+		msg := ethereum.CallMsg{From: common.HexToAddress(wallet.Address), Data: initCode}
+		if err := simulateTransaction(ctx, client, msg, &contractABI); err != nil {
+			return transactFailureResponse(c, err)
+		}
+	}
+	nonce, err := s.nonces.Reserve(ctx, client, wallet, chainId)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
+	defer s.releaseUnusedNonce(wallet, chainId, nonce, &txnHash)
 	transactOpts, err := TransactionOptionsWithKMSSigning(ctx, wallet, s.vault, chainId)
 	if err != nil {
 		s.e.Logger.Errorf(err.Error())
 		return utils.UnexpectedFailureResponse(c, "Error while fetching txn opts "+err.Error(), nil)
 	}
 	transactOpts.Nonce = big.NewInt(int64(nonce))
-	tipCap, _ := client.SuggestGasTipCap(ctx)
-	feeCap, _ := client.SuggestGasPrice(ctx)
-	transactOpts.GasFeeCap = feeCap
-	transactOpts.GasTipCap = tipCap
+	if err := setTransactionFees(ctx, transactOpts, client, chain); err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
 	contractMeta := ContractMetadata{
 		ABI:     string(rawDecodedText),
 		Bin:     u.ByteCode,
 		ChainId: chainId.String(),
+		Params:  params,
 	}
-	params, err := utils.ConvertParamsAsPerTypes(u.Params)
-	if err != nil {
-		return utils.BadRequestResponse(c, "error converting params "+err.Error(), nil)
-	}
-	contractMeta.Params = params
 	address, txn, _, err := DeploySmartContract(transactOpts, client, contractMeta)
 	if err != nil {
 		s.e.Logger.Errorf(err.Error())
 		return utils.UnexpectedFailureResponse(c, "error deploying contract "+err.Error(), nil)
 	}
-	err = utils.UpdatePlatformNonce(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
-	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
-	}
 	txnHash = txn.Hash().String()
-	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployContractResponse{TxnHash: txnHash, ContractAddress: address.String()})
+	s.trackTransaction(wallet, chainId, txn, "deploy", "")
+	s.registerContractOnSuccess(txnHash, chain.ChainId, address, contractMeta.ABI, u.ContractName, contractSourceDeploy)
+	s.syncPlatformNonce(wallet, chainId, txnHash, "deploy")
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployContractResponse{TxnHash: txnHash, ContractAddress: address.String(), Libraries: libraries})
 }
 
 // estimateGas godoc
@@ -382,7 +609,11 @@ func (s *Service) estimateGas(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	client, err := utils.GetEthereumClient(ctx, s.config)
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
@@ -390,23 +621,30 @@ func (s *Service) estimateGas(c echo.Context) error {
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, "error calculating gas price : "+err.Error(), nil)
 	}
+	if u.IsContractTxn && u.ByteCode == "" {
+		if u.To, u.ContractABI, err = s.resolveContract(u.ChainId.String(), u.To, u.ContractName, u.ContractABI, u.ContractVersion); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	}
 	var to common.Address
 	if u.To != "" {
 		to = common.HexToAddress(u.To)
 	}
 	var data []byte
+	var contractABI *abi.ABI
 	if u.IsContractTxn {
-		params, err := utils.ConvertParamsAsPerTypes(u.Params)
+		parsed, err := abi.JSON(strings.NewReader(u.ContractABI))
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
-			return utils.BadRequestResponse(c, "error converting params : "+err.Error(), nil)
+			return utils.UnexpectedFailureResponse(c, "error reading ABI : "+err.Error(), nil)
 		}
-		abi, err := abi.JSON(strings.NewReader(u.ContractABI))
+		params, err := utils.ConvertParams(parsed, u.Method, u.Params)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
-			return utils.UnexpectedFailureResponse(c, "error reading ABI : "+err.Error(), nil)
+			return utils.BadRequestResponse(c, "error converting params : "+err.Error(), nil)
 		}
-		data, err = abi.Pack(u.Method, params...)
+		contractABI = &parsed
+		data, err = parsed.Pack(u.Method, params...)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			return utils.UnexpectedFailureResponse(c, "error packing ABI : "+err.Error(), nil)
@@ -423,9 +661,17 @@ func (s *Service) estimateGas(c echo.Context) error {
 			}
 		}
 	}
-	estimatedGas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: big.NewInt(u.Value), Data: data, GasPrice: gasPrice})
+	value, err := u.Value.Wei()
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	estimatedGas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: value, Data: data, GasPrice: gasPrice})
 	if err != nil {
 		s.e.Logger.Errorf(err.Error())
+		var reverted *revertError
+		if errors.As(callFailure(err, contractABI), &reverted) {
+			return utils.BadRequestResponse(c, "error estimating gas : "+reverted.Error(), reverted.failure)
+		}
 		return utils.UnexpectedFailureResponse(c, "error estimating gas : "+err.Error(), nil)
 	}
 	return utils.SendSuccessResponse(c, "", &utils.EstimatedGasResponse{Address: wallet.Address, EstimatedGas: estimatedGas})
@@ -433,13 +679,14 @@ func (s *Service) estimateGas(c echo.Context) error {
 
 // getBalance godoc
 // @Summary Get balance
-// @Description retrieves wallet balance for a network.
+// @Description retrieves the native balance of a wallet, and optionally its ERC-20 token balances, from the chain at the latest or a given block.
 // @Param	request  body	utils.WalletBalanceRequest	true	"Request Body"
 // @Accept json
 // @Produce json
 // @Success 200 	{object} 	utils.ResponseBody
 // @Router /getBalance [post]
 func (s *Service) getBalance(c echo.Context) error {
+	ctx := c.Request().Context()
 	u := new(utils.WalletBalanceRequest)
 	if err := c.Bind(u); err != nil {
 		return utils.BadRequestResponse(c, "bad request", nil)
@@ -456,10 +703,42 @@ func (s *Service) getBalance(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	res, err := utils.GetBalanceByChainId(s.config, wallet.Address, u.ChainId)
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	block, err := parseBlockNumber(u.BlockNumber)
 	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	var tokens []common.Address
+	for _, token := range u.Tokens {
+		if !common.IsHexAddress(token) {
+			return utils.BadRequestResponse(c, "invalid token address "+token, nil)
+		}
+		tokens = append(tokens, common.HexToAddress(token))
+	}
+	res, err := s.chainBalance(ctx, chain, common.HexToAddress(wallet.Address), block, tokens)
+	if err == nil {
+		return utils.SendSuccessResponse(c, "", res)
+	}
+	// the proxy only knows the latest native balance
+	if !s.config.BalanceProxyFallback || block != nil || len(tokens) != 0 {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
+	s.e.Logger.Warnf("error getting balance on chain %s, falling back to the proxy : %s", chain.ChainId, err.Error())
+	res, err = utils.GetBalanceByChainId(s.config, wallet.Address, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	balance, err := res.Balance.Wei()
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error reading balance : "+err.Error(), nil)
+	}
+	res.ChainId = chain.ChainId
+	res.Formatted = utils.FormatAmount(balance, chain.NativeCurrency.Decimals)
+	res.Symbol = chain.NativeCurrency.Symbol
+	res.Source = "proxy"
 	return utils.SendSuccessResponse(c, "", res)
 }
 
@@ -478,53 +757,68 @@ func (s *Service) callContract(c echo.Context) error {
 	if err := c.Bind(u); err != nil {
 		return utils.BadRequestResponse(c, "bad request", nil)
 	}
-	if u.To == "" {
+	if u.To == "" && u.ContractName == "" {
 		return utils.BadRequestResponse(c, "mandatory params missing", nil)
 	}
-	walletId, err := uuid.Parse(u.WalletId)
-	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	var err error
+	if u.To, u.ContractABI, err = s.resolveContract(u.ChainId.String(), u.To, u.ContractName, u.ContractABI, u.ContractVersion); err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	walletBytes, err := s.db.Get([]byte(utils.NAMESPACE), walletId.NodeID())
+	var from common.Address
+	if u.From != "" {
+		if !common.IsHexAddress(u.From) {
+			return utils.BadRequestResponse(c, "invalid from address", nil)
+		}
+		from = common.HexToAddress(u.From)
+	} else {
+		wallet, err := s.getWallet(u.WalletId)
+		if err != nil {
+			return utils.UnauthorizedResponse(c, err.Error(), nil)
+		}
+		from = common.HexToAddress(wallet.Address)
+	}
+	block, err := callBlock(u.BlockNumber, u.BlockTag)
 	if err != nil {
-		return utils.UnauthorizedResponse(c, err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	wallet := &Wallet{}
-	if err := json.Unmarshal(walletBytes, wallet); err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	client, err := utils.GetEthereumClient(ctx, s.config)
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	chainId, err := client.ChainID(ctx)
+	value, err := u.Value.Wei()
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, "error getting chain Id : "+err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	to := common.HexToAddress(u.To)
-	contract, err := NewSmartContract(to, u.ContractABI, client)
+	parsed, err := abi.JSON(strings.NewReader(u.ContractABI))
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, "error initializing contract : "+err.Error(), nil)
+		return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
 	}
-	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, wallet, s.vault, chainId)
+	params, err := utils.ConvertParams(parsed, u.Method, u.Params)
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, "error initializing transactor opts : "+err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	if u.Value > 0 {
-		txnOpts.Value = big.NewInt(u.Value)
+	data, err := parsed.Pack(u.Method, params...)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error packing ABI : "+err.Error(), nil)
 	}
-	callOpts := &bind.CallOpts{
-		From: txnOpts.From,
+	to := common.HexToAddress(u.To)
+	msg := ethereum.CallMsg{From: from, To: &to, Gas: u.Gas, Data: data}
+	if value.Sign() > 0 {
+		msg.Value = value
 	}
-	params, err := utils.ConvertParamsAsPerTypes(u.Params)
+	out, err := client.CallContract(ctx, msg, block)
 	if err != nil {
-		return utils.BadRequestResponse(c, err.Error(), nil)
+		return transactFailureResponse(c, callFailure(err, &parsed))
 	}
-	var response []interface{}
-	if err := contract.SmartContractCaller.Contract.Call(callOpts, &response, u.Method, params...); err != nil {
-		return utils.UnexpectedFailureResponse(c, "error calling contract : "+err.Error(), nil)
+	res, err := decodeOutputs(parsed.Methods[u.Method].Outputs, out)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error decoding response : "+err.Error(), nil)
 	}
-	return utils.SendSuccessResponse(c, "", &utils.CallContractResponse{Response: &response})
+	return utils.SendSuccessResponse(c, "", res)
 }
 
 // signEIP712Txn godoc
@@ -566,7 +860,7 @@ func (s *Service) signEIP712Txn(c echo.Context) error {
 
 // signMessage godoc
 // @Summary Sign message
-// @Description sign message and return signature.
+// @Description signs a message and returns the signature with v as 27 or 28. The mode is personal_sign (default) for text with the EIP-191 "\x19Ethereum Signed Message:\n" prefix, bytes for 0x hex data with the same prefix, hash for a raw 32-byte hash, validator for EIP-191 version 0x00 data of a validator contract, or keccak for the unprefixed keccak256 hash of the text.
 // @Param	request  body	utils.SignMsgRequest	true	"Request Body"
 // @Accept json
 // @Produce json
@@ -593,17 +887,24 @@ func (s *Service) signMessage(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	hash := crypto.Keccak256Hash([]byte(u.Message))
-	signature, err := SignTransactionHash(ctx, wallet, s.vault, hash.Bytes())
+	hash, err := messageHash(u.Mode, u.Message, u.Validator)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	signature, err := SignTransactionHash(ctx, wallet, s.vault, hash)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, "error signing txn : "+err.Error(), nil)
 	}
+	if len(signature) == crypto.SignatureLength {
+		// 27 or 28, as wallets return it
+		signature[crypto.RecoveryIDOffset] += 27
+	}
 	return utils.SendSuccessResponse(c, "Signed message successfully", "0x"+hex.EncodeToString(signature))
 }
 
 // verifySignatureOffChain godoc
 // @Summary Verify signature
-// @Description verifies signature offline.
+// @Description verifies offline that a message was signed by the wallet, in the mode it was signed with. v may be 0, 1, 27 or 28.
 // @Param	request  body	utils.VerifyMsgRequest	true	"Request Body"
 // @Accept json
 // @Produce json
@@ -630,12 +931,18 @@ func (s *Service) verifySignatureOffChain(c echo.Context) error {
 	if err := json.Unmarshal(walletBytes, wallet); err != nil {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
-	hash := crypto.Keccak256Hash([]byte(u.Message))
+	hash, err := messageHash(u.Mode, u.Message, u.Validator)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
 	signature, err := hex.DecodeString(strings.Replace(u.Signature, "0x", "", 1))
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, "error decoding signature : "+err.Error(), nil)
 	}
-	pubKey, err := crypto.SigToPub(hash.Bytes(), signature)
+	if len(signature) == crypto.SignatureLength && signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	pubKey, err := crypto.SigToPub(hash, signature)
 	if err != nil {
 		return utils.UnexpectedFailureResponse(c, "error getting public key from signature : "+err.Error(), nil)
 	}
@@ -680,18 +987,22 @@ func (s *Service) signAndSubmitGaslessTransaction(c echo.Context) error {
 		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
 	}
 
-	client, err := utils.GetEthereumClient(ctx, s.config)
+	chain, err := s.config.Chains.Get(u.ChainID.String())
 	if err != nil {
-		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
-	chainId, err := client.ChainID(ctx)
+	chainId, err := strconv.ParseUint(chain.ChainId, 10, 64)
 	if err != nil {
-		s.e.Logger.Errorf(err.Error())
+		return utils.UnexpectedFailureResponse(c, "invalid chain id : "+err.Error(), nil)
+	}
+
+	if u.To, u.ContractABI, err = s.resolveContract(chain.ChainId, u.To, u.ContractName, u.ContractABI, u.ContractVersion); err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
 	}
 
 	//Step 1. Get Transaction payload from gasless service
 
-	req := utils.GSNTxnPayloadRequest{ChainId: chainId.Uint64(), DAppId: u.DAppId, UserAddress: wallet.Address, ContractAddress: u.To, ContractAbi: u.ContractABI,
+	req := utils.GSNTxnPayloadRequest{ChainId: chainId, DAppId: u.DAppId, UserAddress: wallet.Address, ContractAddress: u.To, ContractAbi: u.ContractABI,
 		Method: u.Method, Args: u.Params}
 
 	var txnPayload map[string]interface{}
@@ -721,7 +1032,7 @@ func (s *Service) signAndSubmitGaslessTransaction(c echo.Context) error {
 	//Step 3. Send transaction
 
 	sendTxRequest := &utils.GSNSendTxnRequest{
-		ChainId:         chainId.Uint64(),
+		ChainId:         chainId,
 		UserAddress:     wallet.Address,
 		DAppId:          u.DAppId,
 		ContractAddress: u.To,
@@ -741,18 +1052,25 @@ func (s *Service) signAndSubmitGaslessTransaction(c echo.Context) error {
 	return utils.SendSuccessResponse(c, "Transaction Complete", sendTxResponse)
 }
 
-func getEIP712Signature(ctx context.Context, w *Wallet, vault vault.Vault, data apitypes.TypedData) (string, error) {
-	typedDataHash, err := data.HashStruct(data.PrimaryType, data.Message)
+// typedDataHash returns the EIP-712 hash of typed data that is signed.
+func typedDataHash(data apitypes.TypedData) (common.Hash, error) {
+	structHash, err := data.HashStruct(data.PrimaryType, data.Message)
 	if err != nil {
-		return "", err
+		return common.Hash{}, err
 	}
 	domainSeparator, err := data.HashStruct("EIP712Domain", data.Domain.Map())
 	if err != nil {
-		return "", err
+		return common.Hash{}, err
 	}
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(structHash)))
+	return crypto.Keccak256Hash(rawData), nil
+}
 
-	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
-	challengeHash := crypto.Keccak256Hash(rawData)
+func getEIP712Signature(ctx context.Context, w *Wallet, vault vault.Vault, data apitypes.TypedData) (string, error) {
+	challengeHash, err := typedDataHash(data)
+	if err != nil {
+		return "", err
+	}
 	signature, err := SignTransactionHash(ctx, w, vault, challengeHash.Bytes())
 	if err != nil {
 		return "", err
@@ -0,0 +1,171 @@
+package kms
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// maxLibraryDepth limits how deep libraries linking other libraries are
+// deployed.
+const maxLibraryDepth = 8
+
+// linkReference is the byte offset of a library address in bytecode.
+type linkReference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// contractArtifact is the part of a Hardhat or Foundry artifact needed to
+// deploy it. LinkReferences are keyed by source file, then library name.
+type contractArtifact struct {
+	ABI            string
+	Bytecode       string
+	LinkReferences map[string]map[string][]linkReference
+}
+
+// parseArtifact reads a Hardhat artifact, with bytecode and linkReferences
+// at the top, or a Foundry artifact, with both under bytecode.
+func parseArtifact(data json.RawMessage) (*contractArtifact, error) {
+	var raw struct {
+		ABI            json.RawMessage                       `json:"abi"`
+		Bytecode       json.RawMessage                       `json:"bytecode"`
+		LinkReferences map[string]map[string][]linkReference `json:"linkReferences"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error reading artifact : %s", err.Error())
+	}
+	if len(raw.ABI) == 0 || len(raw.Bytecode) == 0 {
+		return nil, fmt.Errorf("artifact has no abi or bytecode")
+	}
+	artifact := &contractArtifact{ABI: string(raw.ABI), LinkReferences: raw.LinkReferences}
+	if err := json.Unmarshal(raw.Bytecode, &artifact.Bytecode); err != nil {
+		var foundry struct {
+			Object         string                                `json:"object"`
+			LinkReferences map[string]map[string][]linkReference `json:"linkReferences"`
+		}
+		if err := json.Unmarshal(raw.Bytecode, &foundry); err != nil {
+			return nil, fmt.Errorf("error reading artifact bytecode : %s", err.Error())
+		}
+		artifact.Bytecode, artifact.LinkReferences = foundry.Object, foundry.LinkReferences
+	}
+	if strings.TrimPrefix(artifact.Bytecode, "0x") == "" {
+		return nil, fmt.Errorf("artifact has no bytecode, it may be an interface or abstract contract")
+	}
+	if _, err := abi.JSON(strings.NewReader(artifact.ABI)); err != nil {
+		return nil, fmt.Errorf("error reading artifact ABI : %s", err.Error())
+	}
+	return artifact, nil
+}
+
+// libraryLinker links the libraries of an artifact for a deploy, deploying
+// the ones that are neither given nor registered.
+type libraryLinker struct {
+	s              *Service
+	client         *ethclient.Client
+	chain          *utils.ChainConfig
+	wallet         *Wallet
+	libraries      map[string]string
+	artifacts      map[string]json.RawMessage
+	skipSimulation bool
+	// linked maps source:name of linked libraries to their addresses
+	linked map[string]string
+}
+
+// link returns the bytecode of artifact as hex with all link references
+// replaced by library addresses.
+func (l *libraryLinker) link(ctx context.Context, artifact *contractArtifact, depth int) (string, error) {
+	code := []byte(strings.TrimPrefix(artifact.Bytecode, "0x"))
+	// sorted so libraries are deployed in the same order every time
+	sources := make([]string, 0, len(artifact.LinkReferences))
+	for source := range artifact.LinkReferences {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		names := make([]string, 0, len(artifact.LinkReferences[source]))
+		for name := range artifact.LinkReferences[source] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			address, err := l.resolve(ctx, source, name, depth)
+			if err != nil {
+				return "", err
+			}
+			addressHex := hex.EncodeToString(address.Bytes())
+			for _, ref := range artifact.LinkReferences[source][name] {
+				if ref.Length != common.AddressLength || ref.Start < 0 || 2*(ref.Start+ref.Length) > len(code) {
+					return "", fmt.Errorf("invalid link reference for library %s:%s", source, name)
+				}
+				copy(code[2*ref.Start:], addressHex)
+			}
+		}
+	}
+	if _, err := hex.DecodeString(string(code)); err != nil {
+		return "", fmt.Errorf("bytecode has unlinked library placeholders or is not hex")
+	}
+	return "0x" + string(code), nil
+}
+
+// resolve returns the address of a library given in the request, registered
+// under its name or deployed from its artifact.
+func (l *libraryLinker) resolve(ctx context.Context, source, name string, depth int) (common.Address, error) {
+	qualified := source + ":" + name
+	if address, ok := l.linked[qualified]; ok {
+		return common.HexToAddress(address), nil
+	}
+	address, err := l.find(ctx, qualified, name, depth)
+	if err != nil {
+		return common.Address{}, err
+	}
+	l.linked[qualified] = address.String()
+	return address, nil
+}
+
+func (l *libraryLinker) find(ctx context.Context, qualified, name string, depth int) (common.Address, error) {
+	for _, key := range []string{qualified, name} {
+		if address, ok := l.libraries[key]; ok {
+			if !common.IsHexAddress(address) {
+				return common.Address{}, fmt.Errorf("invalid address for library %s", key)
+			}
+			return common.HexToAddress(address), nil
+		}
+	}
+	if address, err := l.s.contracts.Lookup(l.chain.ChainId, name); err == nil {
+		return address, nil
+	}
+	for _, key := range []string{qualified, name} {
+		data, ok := l.artifacts[key]
+		if !ok {
+			continue
+		}
+		if depth >= maxLibraryDepth {
+			return common.Address{}, fmt.Errorf("libraries nested too deep at %s", qualified)
+		}
+		artifact, err := parseArtifact(data)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("library %s : %s", key, err.Error())
+		}
+		code, err := l.link(ctx, artifact, depth+1)
+		if err != nil {
+			return common.Address{}, err
+		}
+		libraryABI, _ := abi.JSON(strings.NewReader(artifact.ABI))
+		// registered under its name, so the next deploy links to it
+		address, _, err := l.s.deployCodeAndWait(ctx, l.client, l.chain, l.wallet, &libraryABI, artifact.ABI, name, common.FromHex(code), l.skipSimulation)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("error deploying library %s : %w", qualified, err)
+		}
+		return address, nil
+	}
+	return common.Address{}, fmt.Errorf("library %s is not linked, send its address in libraries or its artifact in libraryArtifacts", qualified)
+}
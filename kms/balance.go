@@ -0,0 +1,118 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// parseBlockNumber parses a decimal or 0x prefixed block number. An empty
+// block number and "latest" stand for the latest block and are returned as nil.
+func parseBlockNumber(block string) (*big.Int, error) {
+	block = strings.TrimSpace(block)
+	if block == "" || block == "latest" {
+		return nil, nil
+	}
+	number, err := parseTokenId(block)
+	if err != nil || !number.IsUint64() {
+		return nil, fmt.Errorf("invalid block number %s", block)
+	}
+	return number, nil
+}
+
+// chainBalance reads the native balance of address and its balances of the
+// given ERC-20 tokens from the chain. All balances are read at the same block,
+// the latest one if block is nil.
+func (s *Service) chainBalance(ctx context.Context, chain *utils.ChainConfig, address common.Address, block *big.Int, tokens []common.Address) (*utils.BalanceResponse, error) {
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting latest block : %s", err.Error())
+		}
+		block = new(big.Int).SetUint64(head)
+	}
+	balance, err := client.BalanceAt(ctx, address, block)
+	if err != nil {
+		return nil, fmt.Errorf("error getting balance : %s", err.Error())
+	}
+	res := &utils.BalanceResponse{
+		Address:     address.String(),
+		ChainId:     chain.ChainId,
+		BlockNumber: block.Uint64(),
+		Balance:     utils.AmountFromInt(balance),
+		Formatted:   utils.FormatAmount(balance, chain.NativeCurrency.Decimals),
+		Symbol:      chain.NativeCurrency.Symbol,
+		Source:      "chain",
+	}
+	if len(tokens) != 0 {
+		res.Tokens = s.tokenBalances(ctx, client, chain, address, tokens, block)
+	}
+	return res, nil
+}
+
+// tokenBalances reads the balances of owner for several ERC-20 tokens in a
+// single Multicall3 call, or with one call per token if the chain has no
+// Multicall3. A token whose balance can not be read reports the error instead.
+func (s *Service) tokenBalances(ctx context.Context, client *ethclient.Client, chain *utils.ChainConfig, owner common.Address, tokens []common.Address,
+	block *big.Int) []utils.TokenAmountResponse {
+	balances := make([]*big.Int, len(tokens))
+	errs := make([]error, len(tokens))
+	data, err := erc20ABI.Pack("balanceOf", owner)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	calls := make([]multicallCall, len(tokens))
+	for i, token := range tokens {
+		calls[i] = multicallCall{Target: token, AllowFailure: true, CallData: data}
+	}
+	results, err := aggregate3(ctx, client, chain, calls, block)
+	if err != nil {
+		s.e.Logger.Warnf("error batching token balances, reading them one by one : %s", err.Error())
+		for i, token := range tokens {
+			values, err := callContractMethodAt(ctx, client, erc20ABI, token, block, "balanceOf", owner)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			balances[i] = values[0].(*big.Int)
+		}
+	} else {
+		for i, result := range results {
+			if !result.Success {
+				errs[i] = fmt.Errorf("balanceOf reverted")
+				continue
+			}
+			values, err := erc20ABI.Unpack("balanceOf", result.ReturnData)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			balances[i] = values[0].(*big.Int)
+		}
+	}
+	res := make([]utils.TokenAmountResponse, len(tokens))
+	for i, token := range tokens {
+		if errs[i] != nil {
+			res[i] = utils.TokenAmountResponse{Token: token.String(), Error: "error getting token balance : " + errs[i].Error()}
+			continue
+		}
+		metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, token)
+		if err != nil {
+			res[i] = utils.TokenAmountResponse{Token: token.String(), Amount: balances[i].String(), Error: err.Error()}
+			continue
+		}
+		res[i] = *tokenAmountResponse(metadata, balances[i])
+	}
+	return res
+}
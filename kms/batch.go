@@ -0,0 +1,218 @@
+package kms
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	accountTypeSimpleAccount    = "simpleAccount"
+	accountTypeSimpleAccountV06 = "simpleAccountV06"
+)
+
+const simpleAccountABIJSON = `[
+{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]},
+{"type":"function","name":"executeBatch","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address[]"},{"name":"value","type":"uint256[]"},{"name":"func","type":"bytes[]"}],"outputs":[]}
+]`
+
+const simpleAccountV06ABIJSON = `[
+{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address"},{"name":"value","type":"uint256"},{"name":"func","type":"bytes"}],"outputs":[]},
+{"type":"function","name":"executeBatch","stateMutability":"nonpayable","inputs":[{"name":"dest","type":"address[]"},{"name":"func","type":"bytes[]"}],"outputs":[]}
+]`
+
+var (
+	simpleAccountABI    = mustParseABI(simpleAccountABIJSON)
+	simpleAccountV06ABI = mustParseABI(simpleAccountV06ABIJSON)
+)
+
+// maximum number of calls in a batch
+const maxBatchCalls = 500
+
+// packContractCall returns target and calldata of a batched call, and the
+// parsed ABI unless the call was given as raw data. The contract and its ABI
+// may come from the contract registry.
+func (s *Service) packContractCall(chainId string, call utils.ContractCall) (common.Address, []byte, *abi.ABI, error) {
+	if call.Data != "" || call.Method == "" {
+		to := common.HexToAddress(call.To)
+		if call.ContractName != "" {
+			var err error
+			if to, err = s.contracts.Lookup(chainId, call.ContractName); err != nil {
+				return common.Address{}, nil, nil, err
+			}
+		} else if !common.IsHexAddress(call.To) {
+			return common.Address{}, nil, nil, fmt.Errorf("invalid address %s", call.To)
+		}
+		var data []byte
+		if call.Data != "" {
+			var err error
+			if data, err = hexutil.Decode(call.Data); err != nil {
+				return common.Address{}, nil, nil, fmt.Errorf("invalid data : %s", err.Error())
+			}
+		}
+		return to, data, nil, nil
+	}
+	address, abiJSON, err := s.resolveContract(chainId, call.To, call.ContractName, call.ContractABI, 0)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	to := common.HexToAddress(address)
+	contractABI, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("error reading ABI : %s", err.Error())
+	}
+	params, err := utils.ConvertParams(contractABI, call.Method, call.Params)
+	if err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("error converting params : %s", err.Error())
+	}
+	data, err := contractABI.Pack(call.Method, params...)
+	if err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("error packing ABI : %s", err.Error())
+	}
+	return to, data, &contractABI, nil
+}
+
+// batchCallContract godoc
+// @Summary Batch call contracts
+// @Description runs many read calls in a single eth_call through Multicall3 aggregate3 and decodes each result. Calls run with Multicall3 as msg.sender.
+// @Param	request  body	utils.BatchCallRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /batchCallContract [post]
+func (s *Service) batchCallContract(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.BatchCallRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if len(u.Calls) == 0 || len(u.Calls) > maxBatchCalls {
+		return utils.BadRequestResponse(c, fmt.Sprintf("between 1 and %d calls are required", maxBatchCalls), nil)
+	}
+	block, err := parseBlockNumber(u.BlockNumber)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	calls := make([]multicallCall, len(u.Calls))
+	abis := make([]*abi.ABI, len(u.Calls))
+	for i, call := range u.Calls {
+		to, data, contractABI, err := s.packContractCall(chain.ChainId, call)
+		if err != nil {
+			return utils.BadRequestResponse(c, fmt.Sprintf("call %d : %s", i, err.Error()), nil)
+		}
+		calls[i] = multicallCall{Target: to, AllowFailure: call.AllowFailure, CallData: data}
+		abis[i] = contractABI
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if block == nil {
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error getting latest block : "+err.Error(), nil)
+		}
+		block = new(big.Int).SetUint64(head)
+	}
+	results, err := aggregate3(ctx, client, chain, calls, block)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error calling contracts : "+err.Error(), nil)
+	}
+	res := &utils.BatchCallResponse{BlockNumber: block.Uint64(), Results: make([]utils.BatchCallResult, len(results))}
+	for i, result := range results {
+		if !result.Success {
+			res.Results[i] = utils.BatchCallResult{Error: decodeRevert(result.ReturnData, abis[i]).Reason}
+			continue
+		}
+		if abis[i] == nil {
+			res.Results[i] = utils.BatchCallResult{Success: true, ReturnData: hexutil.Encode(result.ReturnData)}
+			continue
+		}
+		decoded, err := decodeOutputs(abis[i].Methods[u.Calls[i].Method].Outputs, result.ReturnData)
+		if err != nil {
+			res.Results[i] = utils.BatchCallResult{Success: true, ReturnData: hexutil.Encode(result.ReturnData), Error: "error decoding output : " + err.Error()}
+			continue
+		}
+		res.Results[i] = utils.BatchCallResult{Success: true, Response: decoded.Response, Outputs: decoded.Outputs}
+	}
+	return utils.SendSuccessResponse(c, "", res)
+}
+
+// batchExecute godoc
+// @Summary Batch execute
+// @Description runs many calls atomically from a smart account owned by the wallet through its executeBatch method. The whole batch reverts if any call fails.
+// @Param	request  body	utils.BatchExecuteRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /batchExecute [post]
+func (s *Service) batchExecute(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.BatchExecuteRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Account) {
+		return utils.BadRequestResponse(c, "invalid account address", nil)
+	}
+	if len(u.Calls) == 0 || len(u.Calls) > maxBatchCalls {
+		return utils.BadRequestResponse(c, fmt.Sprintf("between 1 and %d calls are required", maxBatchCalls), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	dests := make([]common.Address, len(u.Calls))
+	values := make([]*big.Int, len(u.Calls))
+	datas := make([][]byte, len(u.Calls))
+	hasValue := false
+	for i, call := range u.Calls {
+		to, data, _, err := s.packContractCall(chain.ChainId, call)
+		if err != nil {
+			return utils.BadRequestResponse(c, fmt.Sprintf("call %d : %s", i, err.Error()), nil)
+		}
+		value, err := call.Value.Wei()
+		if err != nil {
+			return utils.BadRequestResponse(c, fmt.Sprintf("call %d : %s", i, err.Error()), nil)
+		}
+		dests[i], values[i], datas[i] = to, value, data
+		hasValue = hasValue || value.Sign() > 0
+	}
+	var args []interface{}
+	accountABI := simpleAccountABI
+	switch u.AccountType {
+	case "", accountTypeSimpleAccount:
+		args = []interface{}{dests, values, datas}
+	case accountTypeSimpleAccountV06:
+		if hasValue {
+			return utils.BadRequestResponse(c, "simpleAccountV06 executeBatch does not support values", nil)
+		}
+		accountABI = simpleAccountV06ABI
+		args = []interface{}{dests, datas}
+	default:
+		return utils.BadRequestResponse(c, "unsupported account type "+u.AccountType, nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	txn, err := s.transactContract(ctx, client, wallet, accountABI, common.HexToAddress(u.Account), u.SkipSimulation, "batchExecute", "executeBatch", args...)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployContractResponse{TxnHash: txn.Hash().String()})
+}
@@ -0,0 +1,316 @@
+package kms
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	calldataSourceABI      = "abi"
+	calldataSourceSelector = "selector"
+)
+
+// knownSignatures is the bundled selector database used to decode calldata
+// without an ABI. It covers the token standards, proxies, multisigs, smart
+// accounts, batching and the common DEX routers.
+var knownSignatures = []string{
+	// ERC-20, WETH and EIP-2612
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"approve(address,uint256)",
+	"increaseAllowance(address,uint256)",
+	"decreaseAllowance(address,uint256)",
+	"permit(address,address,uint256,uint256,uint8,bytes32,bytes32)",
+	"mint(address,uint256)",
+	"burn(uint256)",
+	"burnFrom(address,uint256)",
+	"deposit()",
+	"withdraw(uint256)",
+	// ERC-721 and ERC-1155
+	"safeTransferFrom(address,address,uint256)",
+	"safeTransferFrom(address,address,uint256,bytes)",
+	"setApprovalForAll(address,bool)",
+	"safeMint(address,uint256)",
+	"safeTransferFrom(address,address,uint256,uint256,bytes)",
+	"safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)",
+	// ownership and access control
+	"transferOwnership(address)",
+	"renounceOwnership()",
+	"acceptOwnership()",
+	"grantRole(bytes32,address)",
+	"revokeRole(bytes32,address)",
+	"renounceRole(bytes32,address)",
+	"pause()",
+	"unpause()",
+	// proxies
+	"upgradeTo(address)",
+	"upgradeToAndCall(address,bytes)",
+	"upgrade(address,address)",
+	"upgradeAndCall(address,address,bytes)",
+	"changeAdmin(address)",
+	"changeProxyAdmin(address,address)",
+	"initialize()",
+	// batching
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+	"aggregate((address,bytes)[])",
+	"tryAggregate(bool,(address,bytes)[])",
+	"aggregate3((address,bool,bytes)[])",
+	"aggregate3Value((address,bool,uint256,bytes)[])",
+	// Safe
+	"execTransaction(address,uint256,bytes,uint8,uint256,uint256,uint256,address,address,bytes)",
+	"approveHash(bytes32)",
+	"addOwnerWithThreshold(address,uint256)",
+	"removeOwner(address,address,uint256)",
+	"swapOwner(address,address,address)",
+	"changeThreshold(uint256)",
+	"enableModule(address)",
+	"disableModule(address,address)",
+	"multiSend(bytes)",
+	"setup(address[],uint256,address,bytes,address,address,uint256,address)",
+	"createProxyWithNonce(address,bytes,uint256)",
+	// smart accounts and ERC-4337
+	"execute(address,uint256,bytes)",
+	"executeBatch(address[],bytes[])",
+	"executeBatch(address[],uint256[],bytes[])",
+	"handleOps((address,uint256,bytes,bytes,uint256,uint256,uint256,uint256,uint256,bytes,bytes)[],address)",
+	"handleOps((address,uint256,bytes,bytes,bytes32,uint256,bytes32,bytes,bytes)[],address)",
+	"depositTo(address)",
+	"createAccount(address,uint256)",
+	// Permit2
+	"permit(address,((address,uint160,uint48,uint48),address,uint256),bytes)",
+	"transferFrom(address,address,uint160,address)",
+	// Uniswap routers
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"addLiquidity(address,address,uint256,uint256,uint256,uint256,address,uint256)",
+	"removeLiquidity(address,address,uint256,uint256,uint256,address,uint256)",
+	"exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+	"exactInput((bytes,address,uint256,uint256,uint256))",
+	// the storage example of this README
+	"store(uint256)",
+	"retrieve()",
+}
+
+// knownMethods maps selectors to the methods of knownSignatures.
+var knownMethods = func() map[[4]byte][]abi.Method {
+	methods := make(map[[4]byte][]abi.Method)
+	for _, signature := range knownSignatures {
+		method, err := parseSignature(signature)
+		if err != nil {
+			panic(err)
+		}
+		var selector [4]byte
+		copy(selector[:], method.ID)
+		methods[selector] = append(methods[selector], method)
+	}
+	return methods
+}()
+
+// parseSignature builds a method from a signature like
+// transfer(address,uint256). Arguments are unnamed and tuple components are
+// named field0, field1 and so on.
+func parseSignature(signature string) (abi.Method, error) {
+	open := strings.Index(signature, "(")
+	if open < 1 || !strings.HasSuffix(signature, ")") {
+		return abi.Method{}, fmt.Errorf("invalid signature %s", signature)
+	}
+	types, err := splitTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return abi.Method{}, fmt.Errorf("invalid signature %s : %s", signature, err.Error())
+	}
+	inputs := make(abi.Arguments, len(types))
+	for i, typ := range types {
+		marshaling, err := typeMarshaling(typ, "")
+		if err != nil {
+			return abi.Method{}, fmt.Errorf("invalid signature %s : %s", signature, err.Error())
+		}
+		if inputs[i].Type, err = abi.NewType(marshaling.Type, "", marshaling.Components); err != nil {
+			return abi.Method{}, fmt.Errorf("invalid signature %s : %s", signature, err.Error())
+		}
+	}
+	return abi.NewMethod(signature[:open], signature[:open], abi.Function, "", false, false, inputs, nil), nil
+}
+
+// typeMarshaling turns a signature type, possibly a tuple, into the form
+// abi.NewType takes.
+func typeMarshaling(typ, name string) (abi.ArgumentMarshaling, error) {
+	if !strings.HasPrefix(typ, "(") {
+		return abi.ArgumentMarshaling{Name: name, Type: typ}, nil
+	}
+	end := strings.LastIndex(typ, ")")
+	types, err := splitTypes(typ[1:end])
+	if err != nil {
+		return abi.ArgumentMarshaling{}, err
+	}
+	marshaling := abi.ArgumentMarshaling{Name: name, Type: "tuple" + typ[end+1:]}
+	for i, component := range types {
+		c, err := typeMarshaling(component, fmt.Sprintf("field%d", i))
+		if err != nil {
+			return abi.ArgumentMarshaling{}, err
+		}
+		marshaling.Components = append(marshaling.Components, c)
+	}
+	return marshaling, nil
+}
+
+// splitTypes splits a type list at the commas outside of tuples.
+func splitTypes(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var types []string
+	depth, start := 0, 0
+	for i, ch := range list {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				types = append(types, list[start:i])
+				start = i + 1
+			}
+		}
+		if depth < 0 {
+			return nil, fmt.Errorf("unbalanced parentheses")
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+	return append(types, list[start:]), nil
+}
+
+// decodeCalldataWith decodes the arguments of calldata for method. When
+// strict, calldata whose arguments do not pack back to the same bytes is
+// rejected, which tells apart methods sharing a selector.
+func decodeCalldataWith(method abi.Method, data []byte, strict bool) (*utils.DecodedCalldata, error) {
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		packed, err := method.Inputs.Pack(values...)
+		if err != nil || !bytes.Equal(packed, data[4:]) {
+			return nil, fmt.Errorf("calldata does not match %s", method.Sig)
+		}
+	}
+	decoded := &utils.DecodedCalldata{
+		Method:    method.RawName,
+		Signature: method.Sig,
+		Selector:  hexutil.Encode(data[:4]),
+		Params:    make([]interface{}, len(values)),
+	}
+	for i, value := range values {
+		decoded.Params[i] = abiValue(method.Inputs[i].Type, reflect.ValueOf(value))
+		if name := method.Inputs[i].Name; name != "" {
+			if decoded.Args == nil {
+				decoded.Args = make(map[string]interface{})
+			}
+			decoded.Args[name] = decoded.Params[i]
+		}
+	}
+	return decoded, nil
+}
+
+// encodeCalldata godoc
+// @Summary Encode calldata
+// @Description encodes a method call as hex calldata, e.g. for a multisig transaction. The ABI is taken from the contract registry when a contract is given without contractABI.
+// @Param	request  body	utils.EncodeCalldataRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /encodeCalldata [post]
+func (s *Service) encodeCalldata(c echo.Context) error {
+	u := new(utils.EncodeCalldataRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.Method == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	contractABI := u.ContractABI
+	if contractABI == "" {
+		var err error
+		if _, contractABI, err = s.resolveContract(u.ChainId.String(), u.To, u.ContractName, "", u.ContractVersion); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	}
+	parsed, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+	}
+	params, err := utils.ConvertParams(parsed, u.Method, u.Params)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error converting params "+err.Error(), nil)
+	}
+	data, err := parsed.Pack(u.Method, params...)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error packing ABI : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", &utils.EncodeCalldataResponse{
+		Data:      hexutil.Encode(data),
+		Selector:  hexutil.Encode(data[:4]),
+		Signature: parsed.Methods[u.Method].Sig,
+	})
+}
+
+// decodeCalldata godoc
+// @Summary Decode calldata
+// @Description decodes hex calldata into the method and its arguments with the given ABI, the registered ABI of the contract, or else the bundled database of well known method selectors.
+// @Param	request  body	utils.DecodeCalldataRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /decodeCalldata [post]
+func (s *Service) decodeCalldata(c echo.Context) error {
+	u := new(utils.DecodeCalldataRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	data, err := hexutil.Decode(u.Data)
+	if err != nil || len(data) < 4 {
+		return utils.BadRequestResponse(c, "data must be 0x hex of at least 4 bytes", nil)
+	}
+	contractABI := u.ContractABI
+	if contractABI == "" && (u.To != "" || u.ContractName != "") {
+		// an unregistered contract falls back to the selector database
+		_, contractABI, _ = s.resolveContract(u.ChainId.String(), u.To, u.ContractName, "", u.ContractVersion)
+	}
+	if contractABI != "" {
+		parsed, err := abi.JSON(strings.NewReader(contractABI))
+		if err != nil {
+			return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+		}
+		method, err := parsed.MethodById(data[:4])
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		decoded, err := decodeCalldataWith(*method, data, false)
+		if err != nil {
+			return utils.BadRequestResponse(c, "error decoding calldata : "+err.Error(), nil)
+		}
+		decoded.Source = calldataSourceABI
+		return utils.SendSuccessResponse(c, "", decoded)
+	}
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	for _, method := range knownMethods[selector] {
+		if decoded, err := decodeCalldataWith(method, data, true); err == nil {
+			decoded.Source = calldataSourceSelector
+			return utils.SendSuccessResponse(c, "", decoded)
+		}
+	}
+	return utils.BadRequestResponse(c, "unknown selector "+hexutil.Encode(data[:4])+", send the contract ABI", nil)
+}
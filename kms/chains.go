@@ -0,0 +1,87 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+// setTransactionFees prices a transaction the way the chain supports: tip and
+// fee cap on EIP-1559 chains, a plain gas price otherwise.
+func setTransactionFees(ctx context.Context, opts *bind.TransactOpts, client *ethclient.Client, chain *utils.ChainConfig) error {
+	if !chain.EIP1559 {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return fmt.Errorf("error calculating gas price : %s", err.Error())
+		}
+		opts.GasPrice = gasPrice
+		return nil
+	}
+	tipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return fmt.Errorf("error calculating gas tip cap : %s", err.Error())
+	}
+	feeCap, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("error calculating gas price : %s", err.Error())
+	}
+	opts.GasTipCap = tipCap
+	opts.GasFeeCap = feeCap
+	return nil
+}
+
+// confirmationsFor returns the confirmations required on a chain, falling back
+// to the tracker default for chains that do not configure them.
+func (s *Service) confirmationsFor(chainId string) uint64 {
+	if chain, err := s.config.Chains.Get(chainId); err == nil && chain.Confirmations > 0 {
+		return chain.Confirmations
+	}
+	return s.tracker.confirmations
+}
+
+// listChains godoc
+// @Summary List chains
+// @Description lists the chains the KMS can send transactions to.
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /chains [get]
+func (s *Service) listChains(c echo.Context) error {
+	return utils.SendSuccessResponse(c, "", s.config.Chains.Info())
+}
+
+// RpcHealthChecker checks the RPC endpoints of every chain on startup and then
+// every RPC_HEALTH_CHECK_INTERVAL seconds.
+func (s *Service) RpcHealthChecker() {
+	ctx := context.Background()
+	interval, err := strconv.Atoi(os.Getenv("RPC_HEALTH_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 30
+	}
+	for {
+		for _, pool := range s.config.Chains.Pools() {
+			pool.CheckHealth(ctx)
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+// rpcStats godoc
+// @Summary RPC endpoint stats
+// @Description returns health, circuit breaker state, latency and request counts of the RPC endpoints of every chain.
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /rpcStats [get]
+func (s *Service) rpcStats(c echo.Context) error {
+	var stats []utils.RpcPoolStats
+	for _, pool := range s.config.Chains.Pools() {
+		stats = append(stats, pool.Stats())
+	}
+	return utils.SendSuccessResponse(c, "", stats)
+}
@@ -0,0 +1,392 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-kms/store"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	contractSourceDeploy = "deploy"
+	contractSourceManual = "manual"
+)
+
+// ContractRegistry stores contract ABIs by chain and address, so calls can
+// name a contract by address or alias instead of sending its ABI.
+type ContractRegistry struct {
+	db store.DB
+}
+
+func NewContractRegistry(db store.DB) *ContractRegistry {
+	return &ContractRegistry{db: db}
+}
+
+func contractKey(chainId string, address common.Address) []byte {
+	return []byte(chainId + "/" + strings.ToLower(address.String()))
+}
+
+func contractAliasKey(chainId, alias string) []byte {
+	return []byte(chainId + "/" + strings.ToLower(alias))
+}
+
+// Register adds contractABI as a new version of the contract unless it is the
+// latest version already, and points the aliases to the contract. Aliases
+// used by another contract of the chain are rejected.
+func (r *ContractRegistry) Register(chainId string, address common.Address, contractABI string, aliases []string, source, txnHash string) (*utils.ContractRecord, error) {
+	if _, err := abi.JSON(strings.NewReader(contractABI)); err != nil {
+		return nil, fmt.Errorf("error reading ABI : %s", err.Error())
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(contractABI)); err != nil {
+		return nil, fmt.Errorf("error reading ABI : %s", err.Error())
+	}
+	for _, alias := range aliases {
+		if alias == "" || common.IsHexAddress(alias) {
+			return nil, fmt.Errorf("invalid alias %q", alias)
+		}
+	}
+	claimed, err := r.claimAliases(chainId, address, aliases)
+	if err != nil {
+		return nil, err
+	}
+	record := &utils.ContractRecord{}
+	err = r.db.Update([]byte(utils.CONTRACT_NAMESPACE), contractKey(chainId, address), func(value []byte) ([]byte, error) {
+		now := time.Now().Unix()
+		*record = utils.ContractRecord{ChainId: chainId, Address: address.String(), CreatedAt: now}
+		if value != nil {
+			if err := json.Unmarshal(value, record); err != nil {
+				return nil, err
+			}
+		}
+		if latest := len(record.Versions); latest == 0 || record.Versions[latest-1].ABI != compact.String() {
+			record.Versions = append(record.Versions, utils.ContractVersion{
+				Version:      latest + 1,
+				ABI:          compact.String(),
+				Source:       source,
+				TxnHash:      txnHash,
+				RegisteredAt: now,
+			})
+		}
+		for _, alias := range aliases {
+			if !containsFold(record.Aliases, alias) {
+				record.Aliases = append(record.Aliases, alias)
+			}
+		}
+		record.UpdatedAt = now
+		return json.Marshal(record)
+	})
+	if err != nil {
+		r.releaseAliases(chainId, claimed)
+		return nil, err
+	}
+	return record, nil
+}
+
+// claimAliases points the aliases to the contract, checking and setting each
+// one atomically. It returns the aliases that were free. If an alias is used
+// by another contract, the ones claimed so far are released again.
+func (r *ContractRegistry) claimAliases(chainId string, address common.Address, aliases []string) ([]string, error) {
+	var claimed []string
+	for _, alias := range aliases {
+		free := false
+		err := r.db.Update([]byte(utils.CONTRACT_ALIAS_NAMESPACE), contractAliasKey(chainId, alias), func(value []byte) ([]byte, error) {
+			if value != nil && common.HexToAddress(string(value)) != address {
+				return nil, fmt.Errorf("alias %s is used by contract %s", alias, string(value))
+			}
+			free = value == nil
+			return []byte(address.String()), nil
+		})
+		if err != nil {
+			r.releaseAliases(chainId, claimed)
+			return nil, err
+		}
+		if free {
+			claimed = append(claimed, alias)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *ContractRegistry) releaseAliases(chainId string, aliases []string) {
+	for _, alias := range aliases {
+		r.db.Delete([]byte(utils.CONTRACT_ALIAS_NAMESPACE), contractAliasKey(chainId, alias))
+	}
+}
+
+// RegisterPending stores the registration of a contract deployed or upgraded
+// by a txn, applied by ConfirmPending once the txn succeeded.
+func (r *ContractRegistry) RegisterPending(txnHash string, pending *utils.PendingContract) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return r.db.Set([]byte(utils.PENDING_CONTRACT_NAMESPACE), txnKey(txnHash), data)
+}
+
+// ConfirmPending registers the contract waiting for the txn, if any. The
+// pending registration is removed even if registering fails.
+func (r *ContractRegistry) ConfirmPending(txnHash string) (*utils.ContractRecord, error) {
+	data, err := r.db.Get([]byte(utils.PENDING_CONTRACT_NAMESPACE), txnKey(txnHash))
+	if err != nil {
+		return nil, nil
+	}
+	defer r.DiscardPending(txnHash)
+	pending := &utils.PendingContract{}
+	if err := json.Unmarshal(data, pending); err != nil {
+		return nil, err
+	}
+	return r.Register(pending.ChainId, common.HexToAddress(pending.Address), pending.ABI, pending.Aliases, pending.Source, txnHash)
+}
+
+// DiscardPending removes the registration waiting for a txn that failed.
+func (r *ContractRegistry) DiscardPending(txnHash string) error {
+	return r.db.Delete([]byte(utils.PENDING_CONTRACT_NAMESPACE), txnKey(txnHash))
+}
+
+// Get returns the registered contract at address.
+func (r *ContractRegistry) Get(chainId string, address common.Address) (*utils.ContractRecord, error) {
+	data, err := r.db.Get([]byte(utils.CONTRACT_NAMESPACE), contractKey(chainId, address))
+	if err != nil {
+		return nil, err
+	}
+	record := &utils.ContractRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Lookup returns the address an alias points to.
+func (r *ContractRegistry) Lookup(chainId, alias string) (common.Address, error) {
+	data, err := r.db.Get([]byte(utils.CONTRACT_ALIAS_NAMESPACE), contractAliasKey(chainId, alias))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("unknown contract %s on chain %s", alias, chainId)
+	}
+	return common.HexToAddress(string(data)), nil
+}
+
+// List returns the registered contracts of a chain.
+func (r *ContractRegistry) List(chainId string) ([]utils.ContractRecord, error) {
+	records := []utils.ContractRecord{}
+	err := r.db.Iterate([]byte(utils.CONTRACT_NAMESPACE), []byte(chainId+"/"), func(key, value []byte) error {
+		var record utils.ContractRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// ABI returns the registered ABI of a contract at version, the latest if 0.
+func (r *ContractRegistry) ABI(chainId string, address common.Address, version int) (string, error) {
+	record, err := r.Get(chainId, address)
+	if err != nil {
+		return "", fmt.Errorf("no ABI registered for contract %s on chain %s", address.String(), chainId)
+	}
+	if version == 0 {
+		version = len(record.Versions)
+	}
+	if version < 1 || version > len(record.Versions) {
+		return "", fmt.Errorf("contract %s has no version %d", address.String(), version)
+	}
+	return record.Versions[version-1].ABI, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveContract returns the address and ABI of a contract given by address
+// or by contract name. A given ABI is used as is, otherwise the registered one
+// at version, the latest if 0.
+func (s *Service) resolveContract(chainId, to, name, contractABI string, version int) (string, string, error) {
+	chain, err := s.config.Chains.Get(chainId)
+	if err != nil {
+		return "", "", err
+	}
+	var address common.Address
+	switch {
+	case name != "":
+		if address, err = s.contracts.Lookup(chain.ChainId, name); err != nil {
+			return "", "", err
+		}
+	case common.IsHexAddress(to):
+		address = common.HexToAddress(to)
+	default:
+		return "", "", fmt.Errorf("a contract address or name is required")
+	}
+	if contractABI == "" {
+		if contractABI, err = s.contracts.ABI(chain.ChainId, address, version); err != nil {
+			return "", "", err
+		}
+	}
+	return address.String(), contractABI, nil
+}
+
+// registerDeployedContract registers a contract deployed by the KMS that is
+// known to exist. Failures are only logged since the deploy went through.
+func (s *Service) registerDeployedContract(chainId string, address common.Address, contractABI, alias, txnHash string) {
+	if _, err := s.contracts.Register(chainId, address, contractABI, aliasList(alias), contractSourceDeploy, txnHash); err != nil {
+		s.e.Logger.Errorf("error registering contract %s : %s", address.String(), err.Error())
+	}
+}
+
+// registerContractOnSuccess registers a contract once the txn deploying or
+// upgrading it succeeded, see settleContracts.
+func (s *Service) registerContractOnSuccess(txnHash, chainId string, address common.Address, contractABI, alias, source string) {
+	pending := &utils.PendingContract{ChainId: chainId, Address: address.String(), ABI: contractABI, Aliases: aliasList(alias), Source: source}
+	if err := s.contracts.RegisterPending(txnHash, pending); err != nil {
+		s.e.Logger.Errorf("error registering contract %s : %s", address.String(), err.Error())
+	}
+}
+
+// settleContracts registers the contracts waiting for a txn that succeeded,
+// or for the txns it replaced, and drops those waiting for a txn that failed.
+func (s *Service) settleContracts(record *utils.TransactionRecord, receipt *types.Receipt) {
+	switch {
+	case receipt != nil && receipt.Status == types.ReceiptStatusSuccessful:
+		if record.Type == txnTypeCancel {
+			return
+		}
+		for replaced := record; replaced != nil; {
+			if _, err := s.contracts.ConfirmPending(replaced.TxnHash); err != nil {
+				s.e.Logger.Errorf("error registering contract of txn %s : %s", replaced.TxnHash, err.Error())
+			}
+			if replaced.Replaces == "" {
+				break
+			}
+			replaced, _ = s.tracker.Get(replaced.Replaces)
+		}
+	case receipt != nil, record.Status == utils.TxnStatusDropped && record.ReplacedBy == "":
+		if err := s.contracts.DiscardPending(record.TxnHash); err != nil {
+			s.e.Logger.Errorf(err.Error())
+		}
+	}
+}
+
+func aliasList(alias string) []string {
+	if alias == "" {
+		return nil
+	}
+	return []string{alias}
+}
+
+// registeredABIs returns the registered ABIs of the given addresses.
+func (s *Service) registeredABIs(chainId string, addresses []common.Address) map[common.Address]abi.ABI {
+	abis := make(map[common.Address]abi.ABI)
+	for _, address := range addresses {
+		if _, ok := abis[address]; ok {
+			continue
+		}
+		contractABI, err := s.contracts.ABI(chainId, address, 0)
+		if err != nil {
+			continue
+		}
+		if parsed, err := abi.JSON(strings.NewReader(contractABI)); err == nil {
+			abis[address] = parsed
+		}
+	}
+	return abis
+}
+
+// registerContract godoc
+// @Summary Register contract
+// @Description registers the ABI of a contract, as a new version if the contract is registered already, and aliases to call it by.
+// @Param	request  body	utils.RegisterContractRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /registerContract [post]
+func (s *Service) registerContract(c echo.Context) error {
+	u := new(utils.RegisterContractRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Address) {
+		return utils.BadRequestResponse(c, "invalid contract address", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	record, err := s.contracts.Register(chain.ChainId, common.HexToAddress(u.Address), u.ContractABI, u.Aliases, contractSourceManual, "")
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Contract registered successfully", record)
+}
+
+// getContract godoc
+// @Summary Get contract
+// @Description returns a registered contract with all versions of its ABI.
+// @Param	request  body	utils.GetContractRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getContract [post]
+func (s *Service) getContract(c echo.Context) error {
+	u := new(utils.GetContractRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	var address common.Address
+	switch {
+	case u.ContractName != "":
+		if address, err = s.contracts.Lookup(chain.ChainId, u.ContractName); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	case common.IsHexAddress(u.Address):
+		address = common.HexToAddress(u.Address)
+	default:
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	record, err := s.contracts.Get(chain.ChainId, address)
+	if err != nil {
+		return utils.BadRequestResponse(c, "contract not registered", nil)
+	}
+	return utils.SendSuccessResponse(c, "", record)
+}
+
+// listContracts godoc
+// @Summary List contracts
+// @Description lists the registered contracts of a chain.
+// @Param	request  body	utils.ListContractsRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /listContracts [post]
+func (s *Service) listContracts(c echo.Context) error {
+	u := new(utils.ListContractsRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	records, err := s.contracts.List(chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error listing contracts : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", records)
+}
@@ -0,0 +1,120 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+// canonical deterministic deployment proxy, deployed at the same address on
+// most chains. It takes salt and init code as calldata and deploys them with
+// CREATE2.
+const deterministicDeploymentProxy = "0x4e59b44847b379578588920cA78FbF26c0B4956C"
+
+// create2Factory returns the CREATE2 factory of a chain.
+func create2Factory(chain *utils.ChainConfig) common.Address {
+	if chain.Create2Factory != "" {
+		return common.HexToAddress(chain.Create2Factory)
+	}
+	return common.HexToAddress(deterministicDeploymentProxy)
+}
+
+// parseSalt reads a salt given as 0x prefixed hex of at most 32 bytes, left
+// padded, or as any other text, which is hashed.
+func parseSalt(salt string) ([32]byte, error) {
+	if salt == "" {
+		return [32]byte{}, nil
+	}
+	if !strings.HasPrefix(salt, "0x") {
+		return crypto.Keccak256Hash([]byte(salt)), nil
+	}
+	digits := salt[2:]
+	if len(digits) > 64 {
+		return [32]byte{}, fmt.Errorf("salt %s is longer than 32 bytes", salt)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	raw, err := hex.DecodeString(digits)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("invalid salt %s", salt)
+	}
+	return common.BytesToHash(raw), nil
+}
+
+// create2Address returns the address init code deploys to through factory.
+func create2Address(factory common.Address, salt [32]byte, initCode []byte) common.Address {
+	return crypto.CreateAddress2(factory, salt, crypto.Keccak256(initCode))
+}
+
+// deployContractCreate2 deploys init code through the checked CREATE2 factory
+// of the chain. The address is predicted from factory, salt and init code,
+// and no txn is sent if code already exists there.
+func (s *Service) deployContractCreate2(c echo.Context, w *Wallet, chain *utils.ChainConfig, client *ethclient.Client, chainId *big.Int, factory common.Address, salt [32]byte,
+	contractABI *abi.ABI, abiJSON string, initCode []byte, libraries map[string]string, u *utils.DeployContractRequest) error {
+	ctx := c.Request().Context()
+	address := create2Address(factory, salt, initCode)
+	code, err := client.CodeAt(ctx, address, nil)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error reading contract code : "+err.Error(), nil)
+	}
+	if len(code) > 0 {
+		s.registerDeployedContract(chain.ChainId, address, abiJSON, u.ContractName, "")
+		return utils.SendSuccessResponse(c, "Contract already deployed", &utils.DeployContractResponse{ContractAddress: address.String(), AlreadyDeployed: true, Libraries: libraries})
+	}
+	data := append(salt[:], initCode...)
+	if !u.SkipSimulation {
+		msg := ethereum.CallMsg{From: common.HexToAddress(w.Address), To: &factory, Data: data}
+		out, err := client.PendingCallContract(ctx, msg)
+		if err != nil {
+			return transactFailureResponse(c, callFailure(err, contractABI))
+		}
+		// the proxy returns the deployed address, a failing constructor
+		// returns nothing
+		if !bytes.Equal(out, address.Bytes()) {
+			return utils.BadRequestResponse(c, "create2 factory would deploy to "+hexutil.Encode(out)+" instead of "+address.String(), nil)
+		}
+	}
+	nonce, err := s.nonces.Reserve(ctx, client, w, chainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	var txnHash string
+	defer s.releaseUnusedNonce(w, chainId, nonce, &txnHash)
+	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, w, s.vault, chainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error initializing transactor opts : "+err.Error(), nil)
+	}
+	txnOpts.Nonce = new(big.Int).SetUint64(nonce)
+	if err := setTransactionFees(ctx, txnOpts, client, chain); err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	txn, err := bind.NewBoundContract(factory, abi.ABI{}, client, client, client).RawTransact(txnOpts, data)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error deploying contract : "+err.Error(), nil)
+	}
+	txnHash = txn.Hash().String()
+	record, err := s.tracker.Track(w, chainId, txn, "deploy", "")
+	if err == nil {
+		record.ContractAddress = address.String()
+		err = s.tracker.Save(record)
+	}
+	if err != nil {
+		s.e.Logger.Errorf("error tracking txn %s : %s", txnHash, err.Error())
+	}
+	s.registerContractOnSuccess(txnHash, chain.ChainId, address, abiJSON, u.ContractName, contractSourceDeploy)
+	s.syncPlatformNonce(w, chainId, txnHash, "deploy")
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployContractResponse{TxnHash: txnHash, ContractAddress: address.String(), Libraries: libraries})
+}
@@ -0,0 +1,384 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+const erc20ABIJSON = `[
+{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+var erc20ABI = mustParseABI(erc20ABIJSON)
+
+func tokenKey(chainId string, token common.Address) []byte {
+	return []byte(chainId + "/" + strings.ToLower(token.String()))
+}
+
+// callTokenString reads name or symbol of a token. Some early tokens return
+// them as bytes32 instead of string.
+func callTokenString(ctx context.Context, client *ethclient.Client, token common.Address, method string) (string, error) {
+	data, err := erc20ABI.Pack(method)
+	if err != nil {
+		return "", err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return "", err
+	}
+	if values, err := erc20ABI.Unpack(method, out); err == nil {
+		return values[0].(string), nil
+	}
+	if len(out) == 32 {
+		return strings.TrimRight(string(out), "\x00"), nil
+	}
+	return "", fmt.Errorf("invalid %s returned by token", method)
+}
+
+// tokenBalance returns the balance of account in the smallest token unit.
+func tokenBalance(ctx context.Context, client *ethclient.Client, token, account common.Address) (*big.Int, error) {
+	values, err := callContractMethod(ctx, client, erc20ABI, token, "balanceOf", account)
+	if err != nil {
+		return nil, fmt.Errorf("error getting token balance : %s", err.Error())
+	}
+	return values[0].(*big.Int), nil
+}
+
+// tokenMetadata returns name, symbol and decimals of a token. They never
+// change, so they are read from the chain once and cached in the wallet db.
+func (s *Service) tokenMetadata(ctx context.Context, client *ethclient.Client, chainId string, token common.Address) (*utils.TokenMetadata, error) {
+	if data, err := s.db.Get([]byte(utils.TOKEN_NAMESPACE), tokenKey(chainId, token)); err == nil {
+		metadata := &utils.TokenMetadata{}
+		if err := json.Unmarshal(data, metadata); err == nil {
+			return metadata, nil
+		}
+	}
+	values, err := callContractMethod(ctx, client, erc20ABI, token, "decimals")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an ERC-20 token on chain %s : %s", token.String(), chainId, err.Error())
+	}
+	metadata := &utils.TokenMetadata{ChainId: chainId, Token: token.String(), Decimals: values[0].(uint8)}
+	// name and symbol are optional in ERC-20
+	if metadata.Name, err = callTokenString(ctx, client, token, "name"); err != nil {
+		s.e.Logger.Warnf("error getting name of token %s : %s", token.String(), err.Error())
+	}
+	if metadata.Symbol, err = callTokenString(ctx, client, token, "symbol"); err != nil {
+		s.e.Logger.Warnf("error getting symbol of token %s : %s", token.String(), err.Error())
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Set([]byte(utils.TOKEN_NAMESPACE), tokenKey(chainId, token), data); err != nil {
+		s.e.Logger.Errorf("error caching token metadata : %s", err.Error())
+	}
+	return metadata, nil
+}
+
+// transactContract signs and broadcasts a call of a contract method with a
+// nonce from the nonce manager, and tracks the transaction. Unless skipped,
+// the call is simulated first and a *revertError returned if it would revert.
+func (s *Service) transactContract(ctx context.Context, client *ethclient.Client, w *Wallet, contractABI abi.ABI, contract common.Address,
+	skipSimulation bool, txnType, method string, args ...interface{}) (*types.Transaction, error) {
+	if !skipSimulation {
+		data, err := contractABI.Pack(method, args...)
+		if err != nil {
+			return nil, fmt.Errorf("error packing ABI : %s", err.Error())
+		}
+		msg := ethereum.CallMsg{From: common.HexToAddress(w.Address), To: &contract, Data: data}
+		if err := simulateTransaction(ctx, client, msg, &contractABI); err != nil {
+			return nil, err
+		}
+	}
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting chain Id : %s", err.Error())
+	}
+	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, w, s.vault, chainId)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing transactor opts : %s", err.Error())
+	}
+	nonce, err := s.nonces.Reserve(ctx, client, w, chainId)
+	if err != nil {
+		return nil, err
+	}
+	txnOpts.Nonce = new(big.Int).SetUint64(nonce)
+	bound := bind.NewBoundContract(contract, contractABI, client, client, client)
+	txn, err := bound.Transact(txnOpts, method, args...)
+	if err != nil {
+		s.releaseNonce(w, chainId, nonce)
+		return nil, fmt.Errorf("error sending txn to contract : %s", err.Error())
+	}
+	s.confirmNonce(w, chainId, nonce)
+	s.trackTransaction(w, chainId, txn, txnType, "")
+	s.syncPlatformNonce(w, chainId, txn.Hash().String(), txnType)
+	return txn, nil
+}
+
+// getTokenMetadata godoc
+// @Summary Get token metadata
+// @Description retrieves name, symbol and decimals of an ERC-20 token.
+// @Param	request  body	utils.TokenMetadataRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getTokenMetadata [post]
+func (s *Service) getTokenMetadata(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.TokenMetadataRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) {
+		return utils.BadRequestResponse(c, "invalid token address", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, common.HexToAddress(u.Token))
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", metadata)
+}
+
+// getTokenBalance godoc
+// @Summary Get token balance
+// @Description retrieves the ERC-20 token balance of a wallet or address.
+// @Param	request  body	utils.TokenBalanceRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getTokenBalance [post]
+func (s *Service) getTokenBalance(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.TokenBalanceRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) || (u.Address != "" && !common.IsHexAddress(u.Address)) || (u.Address == "" && u.WalletId == "") {
+		return utils.BadRequestResponse(c, "token and walletId or a valid address are mandatory", nil)
+	}
+	address := u.Address
+	if address == "" {
+		wallet, err := s.getWallet(u.WalletId)
+		if err != nil {
+			return utils.UnauthorizedResponse(c, err.Error(), nil)
+		}
+		address = wallet.Address
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	token := common.HexToAddress(u.Token)
+	metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, token)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	balance, err := tokenBalance(ctx, client, token, common.HexToAddress(address))
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", tokenAmountResponse(metadata, balance))
+}
+
+// getTokenAllowance godoc
+// @Summary Get token allowance
+// @Description retrieves how many ERC-20 tokens a spender may transfer on behalf of the owner.
+// @Param	request  body	utils.TokenAllowanceRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getTokenAllowance [post]
+func (s *Service) getTokenAllowance(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.TokenAllowanceRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) || !common.IsHexAddress(u.Spender) || (u.Owner != "" && !common.IsHexAddress(u.Owner)) || (u.Owner == "" && u.WalletId == "") {
+		return utils.BadRequestResponse(c, "token, spender and walletId or a valid owner are mandatory", nil)
+	}
+	owner := u.Owner
+	if owner == "" {
+		wallet, err := s.getWallet(u.WalletId)
+		if err != nil {
+			return utils.UnauthorizedResponse(c, err.Error(), nil)
+		}
+		owner = wallet.Address
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	token := common.HexToAddress(u.Token)
+	metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, token)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	values, err := callContractMethod(ctx, client, erc20ABI, token, "allowance", common.HexToAddress(owner), common.HexToAddress(u.Spender))
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting allowance : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", tokenAmountResponse(metadata, values[0].(*big.Int)))
+}
+
+// transferToken godoc
+// @Summary Transfer tokens
+// @Description transfers ERC-20 tokens from the wallet. The amount is in whole tokens, e.g. "1.5".
+// @Param	request  body	utils.TokenTransferRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /transferToken [post]
+func (s *Service) transferToken(c echo.Context) error {
+	return s.transferTokenHandler(c, false)
+}
+
+// transferTokenFrom godoc
+// @Summary Transfer tokens from
+// @Description transfers ERC-20 tokens the wallet is allowed to spend from another address. The amount is in whole tokens, e.g. "1.5".
+// @Param	request  body	utils.TokenTransferRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /transferTokenFrom [post]
+func (s *Service) transferTokenFrom(c echo.Context) error {
+	return s.transferTokenHandler(c, true)
+}
+
+func (s *Service) transferTokenHandler(c echo.Context, fromOwner bool) error {
+	ctx := c.Request().Context()
+	u := new(utils.TokenTransferRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) || !common.IsHexAddress(u.To) || !u.Amount.IsSet() || (fromOwner && !common.IsHexAddress(u.From)) {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	token := common.HexToAddress(u.Token)
+	metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, token)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	amount, err := u.Amount.Tokens(int(metadata.Decimals))
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	var txn *types.Transaction
+	if fromOwner {
+		txn, err = s.transactContract(ctx, client, wallet, erc20ABI, token, u.SkipSimulation, "transferFrom", "transferFrom", common.HexToAddress(u.From), common.HexToAddress(u.To), amount)
+	} else {
+		txn, err = s.transactContract(ctx, client, wallet, erc20ABI, token, u.SkipSimulation, "transfer", "transfer", common.HexToAddress(u.To), amount)
+	}
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.TokenTxnResponse{TxnHash: txn.Hash().String(), Amount: amount.String()})
+}
+
+// approveToken godoc
+// @Summary Approve tokens
+// @Description allows a spender to transfer ERC-20 tokens of the wallet. The amount is in whole tokens, or "max" for an unlimited allowance.
+// @Param	request  body	utils.TokenApproveRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /approveToken [post]
+func (s *Service) approveToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.TokenApproveRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) || !common.IsHexAddress(u.Spender) || !u.Amount.IsSet() {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	token := common.HexToAddress(u.Token)
+	metadata, err := s.tokenMetadata(ctx, client, chain.ChainId, token)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	amount := abi.MaxUint256
+	if !strings.EqualFold(u.Amount.String(), "max") {
+		amount, err = u.Amount.Tokens(int(metadata.Decimals))
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	}
+	txn, err := s.transactContract(ctx, client, wallet, erc20ABI, token, u.SkipSimulation, "approve", "approve", common.HexToAddress(u.Spender), amount)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.TokenTxnResponse{TxnHash: txn.Hash().String(), Amount: amount.String()})
+}
+
+func tokenAmountResponse(metadata *utils.TokenMetadata, amount *big.Int) *utils.TokenAmountResponse {
+	return &utils.TokenAmountResponse{
+		Token:     metadata.Token,
+		Symbol:    metadata.Symbol,
+		Decimals:  metadata.Decimals,
+		Amount:    amount.String(),
+		Formatted: utils.FormatAmount(amount, int(metadata.Decimals)),
+	}
+}
@@ -0,0 +1,156 @@
+package kms
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+// how long submitTransaction waits for a receipt when asked to
+const receiptTimeout = 2 * time.Minute
+
+// standardABIs are tried after the supplied ABI when decoding logs, so token
+// and NFT transfers are decoded without one.
+var standardABIs = []abi.ABI{erc20ABI, erc721ABI, erc1155ABI}
+
+// decodeLog decodes a log with the first of the ABIs that has a matching
+// event, or returns it raw.
+func decodeLog(log *types.Log, abis []abi.ABI) utils.DecodedEvent {
+	event := utils.DecodedEvent{LogIndex: log.Index, Address: log.Address.String()}
+	if len(log.Topics) > 0 {
+		for _, contractABI := range abis {
+			abiEvent, err := contractABI.EventByID(log.Topics[0])
+			if err != nil {
+				continue
+			}
+			filterer := SmartContractFilterer{Contract: bind.NewBoundContract(log.Address, contractABI, nil, nil, nil)}
+			args := map[string]interface{}{}
+			// events sharing a signature may differ in indexed arguments, e.g.
+			// ERC-20 and ERC-721 Transfer, so a failing ABI is skipped
+			if err := filterer.Contract.UnpackLogIntoMap(args, abiEvent.Name, *log); err != nil {
+				continue
+			}
+			for _, input := range abiEvent.Inputs {
+				if value, ok := args[input.Name]; ok {
+					args[input.Name] = abiValue(input.Type, reflect.ValueOf(value))
+				}
+			}
+			event.Name, event.Signature, event.Args = abiEvent.Name, abiEvent.Sig, args
+			return event
+		}
+	}
+	event.Topics = make([]string, len(log.Topics))
+	for i, topic := range log.Topics {
+		event.Topics[i] = topic.String()
+	}
+	event.Data = hexutil.Encode(log.Data)
+	return event
+}
+
+// receiptEvents decodes the logs of a receipt with the given ABI, if any, the
+// ABI registered for the emitting contract and the standard token ABIs.
+func receiptEvents(receipt *types.Receipt, contractABI *abi.ABI, registered map[common.Address]abi.ABI) *utils.TransactionEventsResponse {
+	res := &utils.TransactionEventsResponse{
+		TxnHash:     receipt.TxHash.String(),
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		Status:      utils.TxnStatusMined,
+		GasUsed:     receipt.GasUsed,
+		Events:      make([]utils.DecodedEvent, len(receipt.Logs)),
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		res.Status = utils.TxnStatusFailed
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		res.ContractAddress = receipt.ContractAddress.String()
+	}
+	for i, log := range receipt.Logs {
+		var abis []abi.ABI
+		if contractABI != nil {
+			abis = append(abis, *contractABI)
+		}
+		if registeredABI, ok := registered[log.Address]; ok {
+			abis = append(abis, registeredABI)
+		}
+		res.Events[i] = decodeLog(log, append(abis, standardABIs...))
+	}
+	return res
+}
+
+// receiptRegisteredABIs returns the registered ABIs of the contracts that
+// emitted the logs of a receipt.
+func (s *Service) receiptRegisteredABIs(chainId string, receipt *types.Receipt) map[common.Address]abi.ABI {
+	addresses := make([]common.Address, len(receipt.Logs))
+	for i, log := range receipt.Logs {
+		addresses[i] = log.Address
+	}
+	return s.registeredABIs(chainId, addresses)
+}
+
+// waitForReceipt waits until txn is mined, at most receiptTimeout, and
+// decodes its events.
+func (s *Service) waitForReceipt(ctx context.Context, client *ethclient.Client, chainId string, txn *types.Transaction, contractABI *abi.ABI) (*utils.TransactionEventsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, receiptTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, client, txn)
+	if err != nil {
+		return nil, err
+	}
+	return receiptEvents(receipt, contractABI, s.receiptRegisteredABIs(chainId, receipt)), nil
+}
+
+// getTransactionEvents godoc
+// @Summary Get transaction events
+// @Description fetches the receipt of a transaction and decodes its logs with the supplied ABI, the registered ABIs of the emitting contracts and the ERC-20, ERC-721 and ERC-1155 ABIs. Logs no ABI matches are returned raw.
+// @Param	request  body	utils.TransactionEventsRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getTransactionEvents [post]
+func (s *Service) getTransactionEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.TransactionEventsRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.TxnHash == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	var contractABI *abi.ABI
+	if u.ContractABI != "" {
+		parsed, err := abi.JSON(strings.NewReader(u.ContractABI))
+		if err != nil {
+			return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+		}
+		contractABI = &parsed
+	}
+	chainId := u.ChainId.String()
+	if chainId == "" {
+		// transactions sent by the service know their chain
+		if record, err := s.tracker.Get(u.TxnHash); err == nil {
+			chainId = record.ChainId
+		}
+	}
+	chain, err := s.config.Chains.Get(chainId)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(u.TxnHash))
+	if err != nil {
+		return utils.BadRequestResponse(c, "error getting receipt, the txn may be pending or unknown : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", receiptEvents(receipt, contractABI, s.receiptRegisteredABIs(chain.ChainId, receipt)))
+}
@@ -0,0 +1,147 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+	"wallet-kms/utils"
+
+	"github.com/google/uuid"
+)
+
+// GasBumpPolicy controls the automatic fee replacement of scheduled
+// transactions that stay pending for too long.
+type GasBumpPolicy struct {
+	// PendingThreshold is how long a transaction may stay pending before it
+	// is replaced.
+	PendingThreshold time.Duration
+	// BumpPercent is the fee increase of every replacement, at least 10.
+	BumpPercent int64
+	// MaxFee is the ceiling in wei for the gas price or max fee per gas of a
+	// replacement. Zero means no ceiling.
+	MaxFee *big.Int
+	// MaxAttempts is the number of replacements per scheduled transaction.
+	MaxAttempts int
+}
+
+// gasBumpPolicyFromEnv reads the gas bump policy from the environment. It
+// returns nil, disabling automatic replacement, unless GAS_BUMP_PENDING_SECONDS
+// is set.
+func gasBumpPolicyFromEnv() *GasBumpPolicy {
+	threshold, err := strconv.Atoi(os.Getenv("GAS_BUMP_PENDING_SECONDS"))
+	if err != nil || threshold <= 0 {
+		return nil
+	}
+	policy := &GasBumpPolicy{
+		PendingThreshold: time.Duration(threshold) * time.Second,
+		BumpPercent:      20,
+		MaxAttempts:      5,
+	}
+	if percent, err := strconv.ParseInt(os.Getenv("GAS_BUMP_PERCENT"), 10, 64); err == nil {
+		policy.BumpPercent = percent
+	}
+	if policy.BumpPercent < minFeeBumpPercent {
+		policy.BumpPercent = minFeeBumpPercent
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("GAS_BUMP_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		policy.MaxAttempts = attempts
+	}
+	if maxFee, ok := new(big.Int).SetString(os.Getenv("GAS_BUMP_MAX_FEE"), 10); ok && maxFee.Sign() > 0 {
+		policy.MaxFee = maxFee
+	}
+	return policy
+}
+
+// BumpStuckTransactions replaces scheduled transactions pending for longer
+// than the policy threshold with ones paying higher fees, until they are
+// mined, the fee ceiling is reached or the attempts are used up. Every attempt
+// is reported to the platform. A run is skipped while the previous one is
+// still in progress.
+func (s *Service) BumpStuckTransactions(ctx context.Context) {
+	if s.gasBump == nil {
+		return
+	}
+	if !s.bumping.CompareAndSwap(false, true) {
+		s.e.Logger.Infof("previous gas bump run still in progress")
+		return
+	}
+	defer s.bumping.Store(false)
+	records, err := s.tracker.Open()
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+		return
+	}
+	for i := range records {
+		record := &records[i]
+		// only transactions of scheduler records carry a reference id
+		if record.Status != utils.TxnStatusPending || record.ReferenceId == "" || record.GasBumpHalted != "" {
+			continue
+		}
+		if time.Since(time.Unix(record.SubmittedAt, 0)) < s.gasBump.PendingThreshold {
+			continue
+		}
+		report := &utils.TxnAttemptRequest{WalletId: record.WalletId, ChainId: record.ChainId, ReferenceId: record.ReferenceId, Type: record.Type,
+			Attempt: record.Attempts + 1, ReplacedTxnHash: record.TxnHash}
+		if record.Attempts >= s.gasBump.MaxAttempts {
+			s.haltGasBump(record, report, "maxAttempts", "maximum number of replacements reached")
+			continue
+		}
+		walletId, err := uuid.Parse(record.WalletId)
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		walletBytes, err := s.db.Get([]byte(utils.NAMESPACE), walletId.NodeID())
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		wallet := &Wallet{}
+		if err := json.Unmarshal(walletBytes, wallet); err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		client, err := utils.GetEthereumClient(ctx, s.config, record.ChainId)
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		txn, err := s.replaceTransaction(ctx, client, wallet, record, false, replacementFees{BumpPercent: s.gasBump.BumpPercent, MaxFee: s.gasBump.MaxFee})
+		if err == errFeeCeilingReached {
+			s.haltGasBump(record, report, "ceilingReached", "fee ceiling of "+s.gasBump.MaxFee.String()+" wei reached")
+			continue
+		} else if err != nil {
+			s.e.Logger.Errorf("error replacing txn %s : %s", record.TxnHash, err.Error())
+			report.Status, report.Message = "error", err.Error()
+			s.reportTxnAttempt(report)
+			continue
+		}
+		s.e.Logger.Infof("replaced stuck txn %s with %s", record.TxnHash, txn.Hash().String())
+		report.Status, report.TxnHash = "replaced", txn.Hash().String()
+		s.reportTxnAttempt(report)
+	}
+}
+
+// haltGasBump stops automatic replacement of a transaction and reports why.
+func (s *Service) haltGasBump(record *utils.TransactionRecord, report *utils.TxnAttemptRequest, status, message string) {
+	s.e.Logger.Warnf("not replacing stuck txn %s : %s", record.TxnHash, message)
+	_, err := s.tracker.Update(record.TxnHash, func(record *utils.TransactionRecord) error {
+		record.GasBumpHalted = message
+		record.UpdatedAt = time.Now().Unix()
+		return nil
+	})
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+	report.Status, report.Message = status, message
+	s.reportTxnAttempt(report)
+}
+
+func (s *Service) reportTxnAttempt(report *utils.TxnAttemptRequest) {
+	if err := utils.ReportTxnAttempt(s.config, report); err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+}
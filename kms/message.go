@@ -0,0 +1,48 @@
+package kms
+
+import (
+	"fmt"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// messageHash returns the hash a message is signed as in a signing mode.
+func messageHash(mode, message, validator string) ([]byte, error) {
+	switch mode {
+	case "", utils.SignModePersonal:
+		return accounts.TextHash([]byte(message)), nil
+	case utils.SignModeBytes:
+		data, err := hexutil.Decode(message)
+		if err != nil {
+			return nil, fmt.Errorf("message is not 0x hex bytes : %s", err.Error())
+		}
+		return accounts.TextHash(data), nil
+	case utils.SignModeHash:
+		hash, err := hexutil.Decode(message)
+		if err != nil || len(hash) != common.HashLength {
+			return nil, fmt.Errorf("message is not a 0x hex 32-byte hash")
+		}
+		return hash, nil
+	case utils.SignModeValidator:
+		if !common.IsHexAddress(validator) {
+			return nil, fmt.Errorf("validator mode needs a validator address")
+		}
+		// data given as 0x hex is signed as bytes, anything else as text
+		data := []byte(message)
+		if strings.HasPrefix(message, "0x") {
+			var err error
+			if data, err = hexutil.Decode(message); err != nil {
+				return nil, fmt.Errorf("message is not 0x hex bytes : %s", err.Error())
+			}
+		}
+		return crypto.Keccak256([]byte{0x19, 0x00}, common.HexToAddress(validator).Bytes(), data), nil
+	case utils.SignModeKeccak:
+		return crypto.Keccak256([]byte(message)), nil
+	}
+	return nil, fmt.Errorf("unsupported mode %s", mode)
+}
@@ -0,0 +1,74 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// canonical Multicall3 address, the same on most chains
+const multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+const multicall3ABIJSON = `[
+{"type":"function","name":"aggregate3","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],"outputs":[{"name":"returnData","type":"tuple[]","components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]},
+{"type":"function","name":"getEthBalance","stateMutability":"view","inputs":[{"name":"addr","type":"address"}],"outputs":[{"name":"balance","type":"uint256"}]}
+]`
+
+var multicall3ABI = mustParseABI(multicall3ABIJSON)
+
+// multicallCall is a call of aggregate3. Field names match the ABI tuple.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// multicallAddress returns the Multicall3 contract of a chain.
+func multicallAddress(chain *utils.ChainConfig) common.Address {
+	if chain.Multicall != "" {
+		return common.HexToAddress(chain.Multicall)
+	}
+	return common.HexToAddress(multicall3Address)
+}
+
+// aggregate3 runs calls in a single eth_call through Multicall3 at the given
+// block, latest if nil. Calls allowed to fail report their failure in the
+// result instead of reverting the whole batch.
+func aggregate3(ctx context.Context, client *ethclient.Client, chain *utils.ChainConfig, calls []multicallCall, block *big.Int) ([]multicallResult, error) {
+	multicall := multicallAddress(chain)
+	code, err := client.CodeAt(ctx, multicall, block)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("multicall3 is not deployed at %s on chain %s", multicall.String(), chain.ChainId)
+	}
+	data, err := multicall3ABI.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &multicall, Data: data}, block)
+	if err != nil {
+		return nil, err
+	}
+	values, err := multicall3ABI.Unpack("aggregate3", out)
+	if err != nil {
+		return nil, err
+	}
+	results := *abi.ConvertType(values[0], new([]multicallResult)).(*[]multicallResult)
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("multicall3 returned %d results for %d calls", len(results), len(calls))
+	}
+	return results, nil
+}
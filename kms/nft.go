@@ -0,0 +1,536 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+// ERC-165 interface ids of the NFT standards
+var (
+	erc721InterfaceId  = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	erc1155InterfaceId = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+)
+
+const erc165ABIJSON = `[
+{"type":"function","name":"supportsInterface","stateMutability":"view","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+const erc721ABIJSON = `[
+{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+{"type":"function","name":"tokenURI","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"safeTransferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]},
+{"type":"function","name":"setApprovalForAll","stateMutability":"nonpayable","inputs":[{"name":"operator","type":"address"},{"name":"approved","type":"bool"}],"outputs":[]},
+{"type":"function","name":"isApprovedForAll","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+]`
+
+const erc1155ABIJSON = `[
+{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"balanceOfBatch","stateMutability":"view","inputs":[{"name":"accounts","type":"address[]"},{"name":"ids","type":"uint256[]"}],"outputs":[{"name":"","type":"uint256[]"}]},
+{"type":"function","name":"uri","stateMutability":"view","inputs":[{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"safeTransferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"id","type":"uint256"},{"name":"amount","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]},
+{"type":"function","name":"safeBatchTransferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"ids","type":"uint256[]"},{"name":"amounts","type":"uint256[]"},{"name":"data","type":"bytes"}],"outputs":[]},
+{"type":"function","name":"setApprovalForAll","stateMutability":"nonpayable","inputs":[{"name":"operator","type":"address"},{"name":"approved","type":"bool"}],"outputs":[]},
+{"type":"function","name":"isApprovedForAll","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+{"type":"event","name":"TransferSingle","anonymous":false,"inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"id","type":"uint256","indexed":false},{"name":"value","type":"uint256","indexed":false}]},
+{"type":"event","name":"TransferBatch","anonymous":false,"inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"ids","type":"uint256[]","indexed":false},{"name":"values","type":"uint256[]","indexed":false}]},
+{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"account","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+]`
+
+var (
+	erc165ABI  = mustParseABI(erc165ABIJSON)
+	erc721ABI  = mustParseABI(erc721ABIJSON)
+	erc1155ABI = mustParseABI(erc1155ABIJSON)
+)
+
+// parseTokenId parses a uint256 token id given in decimal or 0x prefixed hex.
+func parseTokenId(tokenId string) (*big.Int, error) {
+	tokenId = strings.TrimSpace(tokenId)
+	id, ok := new(big.Int), false
+	if strings.HasPrefix(tokenId, "0x") || strings.HasPrefix(tokenId, "0X") {
+		id, ok = id.SetString(tokenId[2:], 16)
+	} else {
+		id, ok = id.SetString(tokenId, 10)
+	}
+	if !ok || id.Sign() < 0 || id.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid token id %s", tokenId)
+	}
+	return id, nil
+}
+
+// nftAmount parses an ERC-1155 amount, a plain non-negative integer which
+// defaults to 1.
+func nftAmount(amount utils.Amount) (*big.Int, error) {
+	if !amount.IsSet() {
+		return big.NewInt(1), nil
+	}
+	value, ok := new(big.Int).SetString(strings.TrimSpace(amount.String()), 10)
+	if !ok || value.Sign() < 0 || value.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid amount %s", amount)
+	}
+	return value, nil
+}
+
+// nftStandard detects through ERC-165 whether a contract is an ERC-721 or an
+// ERC-1155 contract. The result is cached in the wallet db.
+func (s *Service) nftStandard(ctx context.Context, client *ethclient.Client, chainId string, contract common.Address) (string, error) {
+	if data, err := s.db.Get([]byte(utils.NFT_NAMESPACE), tokenKey(chainId, contract)); err == nil {
+		nft := &utils.NftContract{}
+		if err := json.Unmarshal(data, nft); err == nil {
+			return nft.Standard, nil
+		}
+	}
+	nft := &utils.NftContract{ChainId: chainId, Contract: contract.String()}
+	for _, standard := range []struct {
+		name        string
+		interfaceId [4]byte
+	}{{utils.StandardERC721, erc721InterfaceId}, {utils.StandardERC1155, erc1155InterfaceId}} {
+		values, err := callContractMethod(ctx, client, erc165ABI, contract, "supportsInterface", standard.interfaceId)
+		if err != nil {
+			return "", fmt.Errorf("%s does not implement ERC-165 on chain %s : %s", contract.String(), chainId, err.Error())
+		}
+		if values[0].(bool) {
+			nft.Standard = standard.name
+			break
+		}
+	}
+	if nft.Standard == "" {
+		return "", fmt.Errorf("%s is neither an ERC-721 nor an ERC-1155 contract", contract.String())
+	}
+	data, err := json.Marshal(nft)
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Set([]byte(utils.NFT_NAMESPACE), tokenKey(chainId, contract), data); err != nil {
+		s.e.Logger.Errorf("error caching nft standard : %s", err.Error())
+	}
+	return nft.Standard, nil
+}
+
+// getNftOwner godoc
+// @Summary Get NFT owner
+// @Description retrieves the owner of an ERC-721 token.
+// @Param	request  body	utils.NftTokenRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getNftOwner [post]
+func (s *Service) getNftOwner(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftTokenRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) {
+		return utils.BadRequestResponse(c, "invalid contract address", nil)
+	}
+	tokenId, err := parseTokenId(u.TokenId)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if standard != utils.StandardERC721 {
+		return utils.BadRequestResponse(c, "ownerOf is only supported by ERC-721 contracts", nil)
+	}
+	values, err := callContractMethod(ctx, client, erc721ABI, contract, "ownerOf", tokenId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting owner : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", &utils.NftOwnerResponse{Contract: contract.String(), Standard: standard, TokenId: tokenId.String(),
+		Owner: values[0].(common.Address).String()})
+}
+
+// getNftBalance godoc
+// @Summary Get NFT balance
+// @Description retrieves the number of tokens of an ERC-721 contract, or of an ERC-1155 token id, held by a wallet or address.
+// @Param	request  body	utils.NftBalanceRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getNftBalance [post]
+func (s *Service) getNftBalance(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftBalanceRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) || (u.Address != "" && !common.IsHexAddress(u.Address)) || (u.Address == "" && u.WalletId == "") {
+		return utils.BadRequestResponse(c, "contract and walletId or a valid address are mandatory", nil)
+	}
+	address := u.Address
+	if address == "" {
+		wallet, err := s.getWallet(u.WalletId)
+		if err != nil {
+			return utils.UnauthorizedResponse(c, err.Error(), nil)
+		}
+		address = wallet.Address
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	var values []interface{}
+	if standard == utils.StandardERC721 {
+		values, err = callContractMethod(ctx, client, erc721ABI, contract, "balanceOf", common.HexToAddress(address))
+	} else {
+		tokenId, parseErr := parseTokenId(u.TokenId)
+		if parseErr != nil {
+			return utils.BadRequestResponse(c, "tokenId is mandatory for ERC-1155 : "+parseErr.Error(), nil)
+		}
+		values, err = callContractMethod(ctx, client, erc1155ABI, contract, "balanceOf", common.HexToAddress(address), tokenId)
+	}
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting balance : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", &utils.NftBalanceResponse{Contract: contract.String(), Standard: standard, Balance: values[0].(*big.Int).String()})
+}
+
+// getNftBalanceBatch godoc
+// @Summary Get NFT balances
+// @Description retrieves the balances of pairs of owners and token ids of an ERC-1155 contract.
+// @Param	request  body	utils.NftBalanceBatchRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getNftBalanceBatch [post]
+func (s *Service) getNftBalanceBatch(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftBalanceBatchRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) || len(u.Owners) == 0 || len(u.Owners) != len(u.TokenIds) {
+		return utils.BadRequestResponse(c, "contract and the same number of owners and tokenIds are mandatory", nil)
+	}
+	owners := make([]common.Address, len(u.Owners))
+	tokenIds := make([]*big.Int, len(u.TokenIds))
+	for i := range u.Owners {
+		if !common.IsHexAddress(u.Owners[i]) {
+			return utils.BadRequestResponse(c, "invalid owner "+u.Owners[i], nil)
+		}
+		owners[i] = common.HexToAddress(u.Owners[i])
+		tokenId, err := parseTokenId(u.TokenIds[i])
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		tokenIds[i] = tokenId
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if standard != utils.StandardERC1155 {
+		return utils.BadRequestResponse(c, "balanceOfBatch is only supported by ERC-1155 contracts", nil)
+	}
+	values, err := callContractMethod(ctx, client, erc1155ABI, contract, "balanceOfBatch", owners, tokenIds)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting balances : "+err.Error(), nil)
+	}
+	var balances []string
+	for _, balance := range values[0].([]*big.Int) {
+		balances = append(balances, balance.String())
+	}
+	return utils.SendSuccessResponse(c, "", &utils.NftBalanceResponse{Contract: contract.String(), Standard: standard, Balances: balances})
+}
+
+// getNftUri godoc
+// @Summary Get NFT metadata uri
+// @Description retrieves the metadata uri of a token, from tokenURI for ERC-721 and uri for ERC-1155.
+// @Param	request  body	utils.NftTokenRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getNftUri [post]
+func (s *Service) getNftUri(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftTokenRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) {
+		return utils.BadRequestResponse(c, "invalid contract address", nil)
+	}
+	tokenId, err := parseTokenId(u.TokenId)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	var uri string
+	if standard == utils.StandardERC721 {
+		values, err := callContractMethod(ctx, client, erc721ABI, contract, "tokenURI", tokenId)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error getting token uri : "+err.Error(), nil)
+		}
+		uri = values[0].(string)
+	} else {
+		values, err := callContractMethod(ctx, client, erc1155ABI, contract, "uri", tokenId)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error getting token uri : "+err.Error(), nil)
+		}
+		// ERC-1155 clients replace {id} by the lowercase hex id padded to 64 chars
+		uri = strings.ReplaceAll(values[0].(string), "{id}", fmt.Sprintf("%064x", tokenId))
+	}
+	return utils.SendSuccessResponse(c, "", &utils.NftUriResponse{Contract: contract.String(), Standard: standard, TokenId: tokenId.String(), Uri: uri})
+}
+
+// transferNft godoc
+// @Summary Transfer NFT
+// @Description transfers an ERC-721 token, or an amount of an ERC-1155 token, with safeTransferFrom.
+// @Param	request  body	utils.NftTransferRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /transferNft [post]
+func (s *Service) transferNft(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftTransferRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) || !common.IsHexAddress(u.To) || (u.From != "" && !common.IsHexAddress(u.From)) {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	tokenId, err := parseTokenId(u.TokenId)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	amount, err := nftAmount(u.Amount)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	data, err := decodeNftData(u.Data)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	from := common.HexToAddress(wallet.Address)
+	if u.From != "" {
+		from = common.HexToAddress(u.From)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	to := common.HexToAddress(u.To)
+	if standard == utils.StandardERC721 {
+		txn, err := s.transactContract(ctx, client, wallet, erc721ABI, contract, u.SkipSimulation, "nftTransfer", "safeTransferFrom", from, to, tokenId, data)
+		if err != nil {
+			return transactFailureResponse(c, err)
+		}
+		return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.NftTxnResponse{Standard: standard, TxnHash: txn.Hash().String()})
+	}
+	txn, err := s.transactContract(ctx, client, wallet, erc1155ABI, contract, u.SkipSimulation, "nftTransfer", "safeTransferFrom", from, to, tokenId, amount, data)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.NftTxnResponse{Standard: standard, TxnHash: txn.Hash().String()})
+}
+
+// batchTransferNft godoc
+// @Summary Batch transfer NFTs
+// @Description transfers several tokens with a single safeBatchTransferFrom for ERC-1155, or one safeTransferFrom txn per token for ERC-721.
+// @Param	request  body	utils.NftBatchTransferRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /batchTransferNft [post]
+func (s *Service) batchTransferNft(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftBatchTransferRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) || !common.IsHexAddress(u.To) || (u.From != "" && !common.IsHexAddress(u.From)) || len(u.TokenIds) == 0 {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	if len(u.Amounts) != 0 && len(u.Amounts) != len(u.TokenIds) {
+		return utils.BadRequestResponse(c, "amounts must match tokenIds", nil)
+	}
+	tokenIds := make([]*big.Int, len(u.TokenIds))
+	amounts := make([]*big.Int, len(u.TokenIds))
+	for i := range u.TokenIds {
+		tokenId, err := parseTokenId(u.TokenIds[i])
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		tokenIds[i] = tokenId
+		var amount utils.Amount
+		if len(u.Amounts) != 0 {
+			amount = u.Amounts[i]
+		}
+		if amounts[i], err = nftAmount(amount); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+	}
+	data, err := decodeNftData(u.Data)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	from := common.HexToAddress(wallet.Address)
+	if u.From != "" {
+		from = common.HexToAddress(u.From)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	to := common.HexToAddress(u.To)
+	if standard == utils.StandardERC1155 {
+		txn, err := s.transactContract(ctx, client, wallet, erc1155ABI, contract, u.SkipSimulation, "nftBatchTransfer", "safeBatchTransferFrom", from, to, tokenIds, amounts, data)
+		if err != nil {
+			return transactFailureResponse(c, err)
+		}
+		return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.NftTxnResponse{Standard: standard, TxnHash: txn.Hash().String()})
+	}
+	// ERC-721 has no batch transfer, so every token is sent in its own txn
+	res := &utils.NftTxnResponse{Standard: standard}
+	for _, tokenId := range tokenIds {
+		txn, err := s.transactContract(ctx, client, wallet, erc721ABI, contract, u.SkipSimulation, "nftTransfer", "safeTransferFrom", from, to, tokenId, data)
+		if err != nil {
+			if len(res.TxnHashes) == 0 {
+				return transactFailureResponse(c, err)
+			}
+			// the tokens sent so far are reported along with the failure
+			message := fmt.Sprintf("error transferring token %s after %d txns : %s", tokenId.String(), len(res.TxnHashes), err.Error())
+			var reverted *revertError
+			if errors.As(err, &reverted) {
+				return utils.BadRequestResponse(c, message, res)
+			}
+			return utils.UnexpectedFailureResponse(c, message, res)
+		}
+		res.TxnHashes = append(res.TxnHashes, txn.Hash().String())
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txns successfully", res)
+}
+
+// setNftApprovalForAll godoc
+// @Summary Set NFT approval for all
+// @Description allows or disallows an operator to transfer all tokens of the wallet in an ERC-721 or ERC-1155 contract.
+// @Param	request  body	utils.NftApprovalRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /setNftApprovalForAll [post]
+func (s *Service) setNftApprovalForAll(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.NftApprovalRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Contract) || !common.IsHexAddress(u.Operator) {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	contract := common.HexToAddress(u.Contract)
+	standard, err := s.nftStandard(ctx, client, chain.ChainId, contract)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	// setApprovalForAll has the same signature in both standards
+	txn, err := s.transactContract(ctx, client, wallet, erc721ABI, contract, u.SkipSimulation, "nftApproval", "setApprovalForAll", common.HexToAddress(u.Operator), u.Approved)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.NftTxnResponse{Standard: standard, TxnHash: txn.Hash().String()})
+}
+
+func decodeNftData(data string) ([]byte, error) {
+	if data == "" || data == "0x" {
+		return []byte{}, nil
+	}
+	decoded, err := hexutil.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data : %s", err.Error())
+	}
+	return decoded, nil
+}
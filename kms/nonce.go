@@ -0,0 +1,333 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"wallet-kms/store"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/google/uuid"
+)
+
+const (
+	// reservations older than this are treated as lost and their nonces as gaps
+	nonceReservationTimeout = 5 * time.Minute
+
+	// upper bound of gap-filling transactions sent for one wallet per round
+	maxGapFillsPerRound = 16
+)
+
+// NonceRecord is the locally managed nonce state of a wallet on a chain.
+type NonceRecord struct {
+	WalletId string `json:"walletId"`
+	ChainId  string `json:"chainId"`
+	// Next is the nonce handed out by the next reservation.
+	Next uint64 `json:"next"`
+	// Reserved holds nonces handed out but not broadcast yet, with the unix
+	// time of the reservation.
+	Reserved  map[uint64]int64 `json:"reserved,omitempty"`
+	UpdatedAt int64            `json:"updatedAt"`
+}
+
+// NonceReader is the part of the chain client the nonce manager reads from.
+type NonceReader interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// SendClient is the part of the chain client needed to price and broadcast a
+// transaction.
+type SendClient interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// NonceManager hands out nonces per (wallet, chainId) from state kept in the
+// wallet db, so concurrent requests never share a nonce.
+type NonceManager struct {
+	mu sync.Mutex
+	db store.DB
+}
+
+func NewNonceManager(db store.DB) *NonceManager {
+	return &NonceManager{db: db}
+}
+
+func nonceKey(walletId, chainId string) []byte {
+	return []byte(walletId + "/" + chainId)
+}
+
+// Reserve atomically hands out the next nonce for the wallet. The chain's
+// pending nonce is used whenever it is ahead of the local state, e.g. for the
+// first reservation or after transactions were sent outside the KMS. Every
+// reserved nonce must be passed to Confirm or Release.
+func (nm *NonceManager) Reserve(ctx context.Context, client NonceReader, w *Wallet, chainId *big.Int) (uint64, error) {
+	pending, err := client.PendingNonceAt(ctx, common.HexToAddress(w.Address))
+	if err != nil {
+		return 0, fmt.Errorf("error getting pending nonce : %s", err.Error())
+	}
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	var nonce uint64
+	err = nm.update(w.WalletId, chainId.String(), func(record *NonceRecord) error {
+		if record.Next < pending {
+			record.Next = pending
+		}
+		nonce = record.Next
+		record.Next++
+		record.Reserved[nonce] = time.Now().Unix()
+		return nil
+	})
+	return nonce, err
+}
+
+// Confirm marks a reserved nonce as used by a broadcast transaction.
+func (nm *NonceManager) Confirm(walletId string, chainId *big.Int, nonce uint64) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	return nm.update(walletId, chainId.String(), func(record *NonceRecord) error {
+		delete(record.Reserved, nonce)
+		return nil
+	})
+}
+
+// Release gives back a reserved nonce whose transaction was never broadcast.
+// The latest nonce is handed out again; any other one is left as a gap for
+// the reconciler to fill.
+func (nm *NonceManager) Release(walletId string, chainId *big.Int, nonce uint64) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	return nm.update(walletId, chainId.String(), func(record *NonceRecord) error {
+		delete(record.Reserved, nonce)
+		if record.Next == nonce+1 {
+			record.Next = nonce
+		}
+		return nil
+	})
+}
+
+// Records returns the nonce state of every wallet and chain.
+func (nm *NonceManager) Records() ([]NonceRecord, error) {
+	var records []NonceRecord
+	err := nm.db.Iterate([]byte(utils.NONCE_NAMESPACE), nil, func(key, value []byte) error {
+		var record NonceRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// reconcile aligns the local state with the chain: reservations below the
+// mined nonce are dropped and the next nonce never falls behind the pending
+// nonce. It returns the nonce to fill if the chain is stuck on a gap, or
+// false when there is none.
+func (nm *NonceManager) reconcile(walletId string, chainId *big.Int, mined, pending uint64) (uint64, bool, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	var gap uint64
+	var found bool
+	err := nm.update(walletId, chainId.String(), func(record *NonceRecord) error {
+		for nonce := range record.Reserved {
+			if nonce < mined {
+				delete(record.Reserved, nonce)
+			}
+		}
+		if record.Next < pending {
+			record.Next = pending
+		}
+		if pending >= record.Next {
+			return nil
+		}
+		// the pending nonce stops at the first missing nonce, so anything there
+		// that is not about to be broadcast blocks every later transaction
+		reservedAt, ok := record.Reserved[pending]
+		if ok && time.Since(time.Unix(reservedAt, 0)) < nonceReservationTimeout {
+			return nil
+		}
+		delete(record.Reserved, pending)
+		gap, found = pending, true
+		return nil
+	})
+	return gap, found, err
+}
+
+func (nm *NonceManager) update(walletId, chainId string, fn func(record *NonceRecord) error) error {
+	return nm.db.Update([]byte(utils.NONCE_NAMESPACE), nonceKey(walletId, chainId), func(value []byte) ([]byte, error) {
+		record := &NonceRecord{WalletId: walletId, ChainId: chainId}
+		if value != nil {
+			if err := json.Unmarshal(value, record); err != nil {
+				return nil, err
+			}
+		}
+		if record.Reserved == nil {
+			record.Reserved = make(map[uint64]int64)
+		}
+		if err := fn(record); err != nil {
+			return nil, err
+		}
+		record.UpdatedAt = time.Now().Unix()
+		return json.Marshal(record)
+	})
+}
+
+// releaseUnusedNonce releases a reserved nonce if no transaction was broadcast
+// with it, which is the case while txnHash is empty, and confirms it
+// otherwise. It is meant to be deferred right after the reservation.
+func (s *Service) releaseUnusedNonce(w *Wallet, chainId *big.Int, nonce uint64, txnHash *string) {
+	if *txnHash == "" {
+		s.releaseNonce(w, chainId, nonce)
+	} else {
+		s.confirmNonce(w, chainId, nonce)
+	}
+}
+
+func (s *Service) releaseNonce(w *Wallet, chainId *big.Int, nonce uint64) {
+	if err := s.nonces.Release(w.WalletId, chainId, nonce); err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+}
+
+func (s *Service) confirmNonce(w *Wallet, chainId *big.Int, nonce uint64) {
+	if err := s.nonces.Confirm(w.WalletId, chainId, nonce); err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+}
+
+// syncPlatformNonce reports a broadcast transaction to the platform nonce
+// service when NONCE_PLATFORM_SYNC is enabled. Failures are only logged since
+// the transaction is already on its way.
+func (s *Service) syncPlatformNonce(w *Wallet, chainId *big.Int, txnHash, txnType string) {
+	if !s.config.NonceSync {
+		return
+	}
+	err := utils.UpdatePlatformNonce(s.config, &utils.NonceRequest{WalletId: w.WalletId, ChainId: chainId.String(), TxnHash: txnHash, Type: txnType})
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+}
+
+// NonceReconciler reconciles the local nonce state against the chain on
+// startup and then every NONCE_RECONCILE_INTERVAL seconds.
+func (s *Service) NonceReconciler() {
+	ctx := context.Background()
+	interval, err := strconv.Atoi(os.Getenv("NONCE_RECONCILE_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 60
+	}
+	for {
+		s.ReconcileNonces(ctx)
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+// ReconcileNonces checks every locally managed nonce against PendingNonceAt
+// and NonceAt of its chain and fills gaps with zero value self transfers.
+func (s *Service) ReconcileNonces(ctx context.Context) {
+	records, err := s.nonces.Records()
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+		return
+	}
+	clients := make(map[string]*ethclient.Client)
+	for _, record := range records {
+		client, ok := clients[record.ChainId]
+		if !ok {
+			client, err = utils.GetEthereumClient(ctx, s.config, record.ChainId)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			clients[record.ChainId] = client
+		}
+		chainId, ok := new(big.Int).SetString(record.ChainId, 10)
+		if !ok {
+			s.e.Logger.Errorf("invalid chain id %s", record.ChainId)
+			continue
+		}
+		walletId, err := uuid.Parse(record.WalletId)
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		walletBytes, err := s.db.Get([]byte(utils.NAMESPACE), walletId.NodeID())
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		wallet := &Wallet{}
+		if err := json.Unmarshal(walletBytes, wallet); err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		address := common.HexToAddress(wallet.Address)
+		for i := 0; i < maxGapFillsPerRound; i++ {
+			mined, err := client.NonceAt(ctx, address, nil)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				break
+			}
+			pending, err := client.PendingNonceAt(ctx, address)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				break
+			}
+			gap, found, err := s.nonces.reconcile(wallet.WalletId, chainId, mined, pending)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				break
+			}
+			if !found {
+				break
+			}
+			txn, err := s.fillNonceGap(ctx, client, wallet, chainId, gap)
+			if err != nil {
+				s.e.Logger.Errorf("error filling nonce gap %d for wallet %s : %s", gap, wallet.WalletId, err.Error())
+				break
+			}
+			s.e.Logger.Infof("filled nonce gap %d for wallet %s with txn %s", gap, wallet.WalletId, txn.Hash().String())
+			s.trackTransaction(wallet, chainId, txn, "gapFill", "")
+			s.syncPlatformNonce(wallet, chainId, txn.Hash().String(), "gapFill")
+		}
+	}
+}
+
+// fillNonceGap sends a zero value transfer to the wallet itself with the
+// given nonce.
+func (s *Service) fillNonceGap(ctx context.Context, client SendClient, w *Wallet, chainId *big.Int, nonce uint64) (*types.Transaction, error) {
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	to := common.HexToAddress(w.Address)
+	txn := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      21000,
+		Value:    big.NewInt(0),
+		To:       &to,
+	})
+	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, w, s.vault, chainId)
+	if err != nil {
+		return nil, err
+	}
+	txn, err = txnOpts.Signer(txnOpts.From, txn)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SendTransaction(ctx, txn); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
@@ -0,0 +1,122 @@
+package kms
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// decodeOutputs unpacks the output of a call into JSON friendly values,
+// in order and by name for the named outputs.
+func decodeOutputs(outputs abi.Arguments, data []byte) (*utils.CallContractResponse, error) {
+	if len(data) == 0 && len(outputs) > 0 {
+		return nil, fmt.Errorf("empty output, the address may have no contract")
+	}
+	values, err := outputs.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	res := &utils.CallContractResponse{Response: make([]interface{}, len(values))}
+	for i, value := range values {
+		res.Response[i] = abiValue(outputs[i].Type, reflect.ValueOf(value))
+		if outputs[i].Name != "" {
+			if res.Outputs == nil {
+				res.Outputs = make(map[string]interface{})
+			}
+			res.Outputs[outputs[i].Name] = res.Response[i]
+		}
+	}
+	return res, nil
+}
+
+// abiValue converts a value of ABI type t for JSON: numbers become decimal
+// strings, bytes hex strings and tuples maps keyed by their field names in
+// the ABI. Indexed event arguments of dynamic types are decoded as the hash
+// of their value and returned as such.
+func abiValue(t abi.Type, value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		if v, ok := value.Interface().(*big.Int); ok {
+			return v.String()
+		}
+		value = value.Elem()
+	}
+	if v, ok := value.Interface().(common.Hash); ok {
+		return v.String()
+	}
+	switch t.T {
+	case abi.TupleTy:
+		fields := make(map[string]interface{}, len(t.TupleElems))
+		for i, elem := range t.TupleElems {
+			name := t.TupleRawNames[i]
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			fields[name] = abiValue(*elem, value.Field(i))
+		}
+		return fields
+	case abi.SliceTy, abi.ArrayTy:
+		values := make([]interface{}, value.Len())
+		for i := range values {
+			values[i] = abiValue(*t.Elem, value.Index(i))
+		}
+		return values
+	case abi.IntTy:
+		return big.NewInt(value.Int()).String()
+	case abi.UintTy:
+		return new(big.Int).SetUint64(value.Uint()).String()
+	case abi.AddressTy:
+		return value.Interface().(common.Address).String()
+	case abi.BytesTy, abi.FixedBytesTy, abi.FunctionTy:
+		raw := make([]byte, value.Len())
+		reflect.Copy(reflect.ValueOf(raw), value)
+		return hexutil.Encode(raw)
+	}
+	return value.Interface()
+}
+
+// callBlock returns the block a call runs on for a block number or tag, nil
+// for the latest block.
+func callBlock(number, tag string) (*big.Int, error) {
+	if number != "" && tag != "" {
+		return nil, fmt.Errorf("either blockNumber or blockTag can be set")
+	}
+	if number != "" {
+		base := 10
+		if strings.HasPrefix(number, "0x") {
+			number, base = number[2:], 16
+		}
+		block, ok := new(big.Int).SetString(number, base)
+		if !ok || block.Sign() < 0 {
+			return nil, fmt.Errorf("invalid block number %s", number)
+		}
+		return block, nil
+	}
+	switch tag {
+	case "", "latest":
+		return nil, nil
+	case "pending":
+		// ethclient sends -1 as pending, unlike rpc.PendingBlockNumber
+		return big.NewInt(-1), nil
+	case "safe":
+		return big.NewInt(int64(rpc.SafeBlockNumber)), nil
+	case "finalized":
+		return big.NewInt(int64(rpc.FinalizedBlockNumber)), nil
+	case "earliest":
+		return big.NewInt(0), nil
+	}
+	return nil, fmt.Errorf("unsupported block tag %s", tag)
+}
@@ -0,0 +1,402 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/labstack/echo/v4"
+)
+
+// canonical Uniswap Permit2, deployed at the same address on every chain
+const permit2Address = "0x000000000022D473030F116dDEE9F6B43aC78BA3"
+
+const (
+	defaultPermitDeadline   = time.Hour
+	defaultPermitExpiration = 30 * 24 * time.Hour
+	// maxNonceWords limits the words of the Permit2 nonce bitmap searched
+	// for an unused nonce
+	maxNonceWords = 256
+)
+
+const erc2612ABIJSON = `[
+{"type":"function","name":"DOMAIN_SEPARATOR","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+{"type":"function","name":"nonces","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"version","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]}
+]`
+
+const permit2ABIJSON = `[
+{"type":"function","name":"DOMAIN_SEPARATOR","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"token","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"amount","type":"uint160"},{"name":"expiration","type":"uint48"},{"name":"nonce","type":"uint48"}]},
+{"type":"function","name":"nonceBitmap","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"wordPos","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var (
+	erc2612ABI = mustParseABI(erc2612ABIJSON)
+	permit2ABI = mustParseABI(permit2ABIJSON)
+)
+
+// maximum amount of a Permit2 allowance
+var maxUint160 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+
+var permitTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"Permit": {
+		{Name: "owner", Type: "address"},
+		{Name: "spender", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// permit2Types has the types of every Permit2 message, the ones a message
+// does not use are left out of its hash.
+var permit2Types = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"PermitDetails": {
+		{Name: "token", Type: "address"},
+		{Name: "amount", Type: "uint160"},
+		{Name: "expiration", Type: "uint48"},
+		{Name: "nonce", Type: "uint48"},
+	},
+	utils.Permit2PermitSingle: {
+		{Name: "details", Type: "PermitDetails"},
+		{Name: "spender", Type: "address"},
+		{Name: "sigDeadline", Type: "uint256"},
+	},
+	utils.Permit2PermitBatch: {
+		{Name: "details", Type: "PermitDetails[]"},
+		{Name: "spender", Type: "address"},
+		{Name: "sigDeadline", Type: "uint256"},
+	},
+	"TokenPermissions": {
+		{Name: "token", Type: "address"},
+		{Name: "amount", Type: "uint256"},
+	},
+	utils.Permit2PermitTransferFrom: {
+		{Name: "permitted", Type: "TokenPermissions"},
+		{Name: "spender", Type: "address"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+	utils.Permit2PermitBatchTransferFrom: {
+		{Name: "permitted", Type: "TokenPermissions[]"},
+		{Name: "spender", Type: "address"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// signTypedDataVRS signs typed data after checking its domain separator
+// against the one of the verifying contract, and returns the signature with
+// its v, r and s.
+func (s *Service) signTypedDataVRS(ctx context.Context, w *Wallet, data apitypes.TypedData, domainSeparator [32]byte) (string, uint8, string, string, error) {
+	expected, err := data.HashStruct("EIP712Domain", data.Domain.Map())
+	if err != nil {
+		return "", 0, "", "", err
+	}
+	if common.BytesToHash(expected) != common.Hash(domainSeparator) {
+		return "", 0, "", "", fmt.Errorf("domain separator of %s does not match name %q and version %q", data.Domain.VerifyingContract,
+			data.Domain.Name, data.Domain.Version)
+	}
+	signature, err := getEIP712Signature(ctx, w, s.vault, data)
+	if err != nil {
+		return "", 0, "", "", err
+	}
+	raw := common.FromHex(signature)
+	return signature, raw[64], hexutil.Encode(raw[:32]), hexutil.Encode(raw[32:64]), nil
+}
+
+// permitDeadline returns deadline, or the default from now if it is zero.
+func permitDeadline(deadline uint64, fallback time.Duration) uint64 {
+	if deadline == 0 {
+		return uint64(time.Now().Add(fallback).Unix())
+	}
+	return deadline
+}
+
+// permitAmount returns an amount in whole tokens in the smallest unit, or max
+// for "max".
+func (s *Service) permitAmount(ctx context.Context, client *ethclient.Client, chainId string, token common.Address, amount utils.Amount, max *big.Int) (*big.Int, error) {
+	if strings.EqualFold(amount.String(), "max") {
+		return max, nil
+	}
+	metadata, err := s.tokenMetadata(ctx, client, chainId, token)
+	if err != nil {
+		return nil, err
+	}
+	value, err := amount.Tokens(int(metadata.Decimals))
+	if err != nil {
+		return nil, err
+	}
+	if value.Cmp(max) > 0 {
+		return nil, fmt.Errorf("amount %s of %s is too large", amount.String(), token.String())
+	}
+	return value, nil
+}
+
+// unusedPermit2Nonce returns the first unordered nonce of owner that is not
+// used in the Permit2 nonce bitmap.
+func unusedPermit2Nonce(ctx context.Context, client *ethclient.Client, permit2, owner common.Address) (*big.Int, error) {
+	for word := int64(0); word < maxNonceWords; word++ {
+		out, err := callContractMethod(ctx, client, permit2ABI, permit2, "nonceBitmap", owner, big.NewInt(word))
+		if err != nil {
+			return nil, fmt.Errorf("error reading Permit2 nonces : %s", err.Error())
+		}
+		bitmap := out[0].(*big.Int)
+		for bit := 0; bit < 256; bit++ {
+			if bitmap.Bit(bit) == 0 {
+				return big.NewInt(word<<8 | int64(bit)), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no unused Permit2 nonce in the first %d words, set nonce", maxNonceWords)
+}
+
+// signPermit godoc
+// @Summary Sign EIP-2612 permit
+// @Description signs an EIP-2612 permit that lets a spender use tokens of the wallet without an approve transaction. Name, version, nonce and domain separator are read from the token, and the signature is returned with v, r, s and the deadline for calling permit.
+// @Param	request  body	utils.PermitRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /signPermit [post]
+func (s *Service) signPermit(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.PermitRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Token) || !common.IsHexAddress(u.Spender) || !u.Amount.IsSet() {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	token, owner := common.HexToAddress(u.Token), common.HexToAddress(wallet.Address)
+	value, err := s.permitAmount(ctx, client, chain.ChainId, token, u.Amount, abi.MaxUint256)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	out, err := callContractMethod(ctx, client, erc2612ABI, token, "DOMAIN_SEPARATOR")
+	if err != nil {
+		return utils.BadRequestResponse(c, fmt.Sprintf("%s does not support EIP-2612 permits : %s", token.String(), err.Error()), nil)
+	}
+	domainSeparator := out[0].([32]byte)
+	if out, err = callContractMethod(ctx, client, erc2612ABI, token, "nonces", owner); err != nil {
+		return utils.BadRequestResponse(c, fmt.Sprintf("%s does not support EIP-2612 permits : %s", token.String(), err.Error()), nil)
+	}
+	nonce := out[0].(*big.Int)
+	name, err := callTokenString(ctx, client, token, "name")
+	if err != nil {
+		return utils.BadRequestResponse(c, "error reading token name : "+err.Error(), nil)
+	}
+	version := u.Version
+	if version == "" {
+		// version is optional, most tokens without it use "1"
+		version = "1"
+		if out, err := callContractMethod(ctx, client, erc2612ABI, token, "version"); err == nil {
+			version = out[0].(string)
+		}
+	}
+	deadline := permitDeadline(u.Deadline, defaultPermitDeadline)
+	data := apitypes.TypedData{
+		Types:       permitTypes,
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              name,
+			Version:           version,
+			ChainId:           (*math.HexOrDecimal256)(chainId),
+			VerifyingContract: token.String(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    owner.String(),
+			"spender":  common.HexToAddress(u.Spender).String(),
+			"value":    value.String(),
+			"nonce":    nonce.String(),
+			"deadline": fmt.Sprint(deadline),
+		},
+	}
+	signature, v, r, sv, err := s.signTypedDataVRS(ctx, wallet, data, domainSeparator)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error signing permit : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Signed permit successfully", &utils.PermitResponse{
+		Token:     token.String(),
+		Owner:     owner.String(),
+		Spender:   common.HexToAddress(u.Spender).String(),
+		Value:     value.String(),
+		Nonce:     nonce.String(),
+		Deadline:  deadline,
+		Signature: signature,
+		V:         v,
+		R:         r,
+		S:         sv,
+	})
+}
+
+// signPermit2 godoc
+// @Summary Sign Permit2 permit
+// @Description signs a Uniswap Permit2 PermitSingle or PermitBatch allowance, or a PermitTransferFrom or PermitBatchTransferFrom one-time transfer, for tokens the wallet approved to Permit2. Nonces are read from Permit2, and the signed message is returned with v, r, s and the deadline.
+// @Param	request  body	utils.Permit2Request	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /signPermit2 [post]
+func (s *Service) signPermit2(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.Permit2Request)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Spender) || len(u.Tokens) == 0 {
+		return utils.BadRequestResponse(c, "mandatory params missing or invalid", nil)
+	}
+	batch := u.PrimaryType == utils.Permit2PermitBatch || u.PrimaryType == utils.Permit2PermitBatchTransferFrom
+	transfer := u.PrimaryType == utils.Permit2PermitTransferFrom || u.PrimaryType == utils.Permit2PermitBatchTransferFrom
+	if !batch && !transfer && u.PrimaryType != utils.Permit2PermitSingle {
+		return utils.BadRequestResponse(c, "unsupported primaryType "+u.PrimaryType, nil)
+	}
+	if !batch && len(u.Tokens) > 1 {
+		return utils.BadRequestResponse(c, u.PrimaryType+" takes a single token, use the batch type for more", nil)
+	}
+	permit2Hex := permit2Address
+	if u.Permit2 != "" {
+		if !common.IsHexAddress(u.Permit2) {
+			return utils.BadRequestResponse(c, "invalid permit2 address", nil)
+		}
+		permit2Hex = u.Permit2
+	}
+	permit2 := common.HexToAddress(permit2Hex)
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	out, err := callContractMethod(ctx, client, permit2ABI, permit2, "DOMAIN_SEPARATOR")
+	if err != nil {
+		return utils.BadRequestResponse(c, fmt.Sprintf("Permit2 is not deployed at %s : %s", permit2.String(), err.Error()), nil)
+	}
+	domainSeparator := out[0].([32]byte)
+	owner, spender := common.HexToAddress(wallet.Address), common.HexToAddress(u.Spender)
+	deadline := permitDeadline(u.Deadline, defaultPermitDeadline)
+	message := apitypes.TypedDataMessage{"spender": spender.String()}
+	items := make([]interface{}, len(u.Tokens))
+	for i, t := range u.Tokens {
+		if !common.IsHexAddress(t.Token) {
+			return utils.BadRequestResponse(c, "invalid token address "+t.Token, nil)
+		}
+		token := common.HexToAddress(t.Token)
+		if transfer {
+			amount, err := s.permitAmount(ctx, client, chain.ChainId, token, t.Amount, abi.MaxUint256)
+			if err != nil {
+				return utils.BadRequestResponse(c, err.Error(), nil)
+			}
+			items[i] = map[string]interface{}{"token": token.String(), "amount": amount.String()}
+			continue
+		}
+		amount, err := s.permitAmount(ctx, client, chain.ChainId, token, t.Amount, maxUint160)
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		allowance, err := callContractMethod(ctx, client, permit2ABI, permit2, "allowance", owner, token, spender)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error reading Permit2 allowance : "+err.Error(), nil)
+		}
+		items[i] = map[string]interface{}{
+			"token":      token.String(),
+			"amount":     amount.String(),
+			"expiration": fmt.Sprint(permitDeadline(u.Expiration, defaultPermitExpiration)),
+			"nonce":      allowance[2].(*big.Int).String(),
+		}
+	}
+	if transfer {
+		nonce, ok := new(big.Int).SetString(u.Nonce, 10)
+		if u.Nonce == "" {
+			if nonce, err = unusedPermit2Nonce(ctx, client, permit2, owner); err != nil {
+				return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+			}
+		} else if !ok || nonce.Sign() < 0 {
+			return utils.BadRequestResponse(c, "invalid nonce "+u.Nonce, nil)
+		}
+		message["nonce"], message["deadline"] = nonce.String(), fmt.Sprint(deadline)
+		message["permitted"] = items[0]
+		if batch {
+			message["permitted"] = items
+		}
+	} else {
+		message["sigDeadline"] = fmt.Sprint(deadline)
+		message["details"] = items[0]
+		if batch {
+			message["details"] = items
+		}
+	}
+	data := apitypes.TypedData{
+		Types:       permit2Types,
+		PrimaryType: u.PrimaryType,
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Permit2",
+			ChainId:           (*math.HexOrDecimal256)(chainId),
+			VerifyingContract: permit2.String(),
+		},
+		Message: message,
+	}
+	signature, v, r, sv, err := s.signTypedDataVRS(ctx, wallet, data, domainSeparator)
+	if err != nil {
+		return utils.BadRequestResponse(c, "error signing permit : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Signed permit successfully", &utils.Permit2Response{
+		Permit2:     permit2.String(),
+		Owner:       owner.String(),
+		PrimaryType: u.PrimaryType,
+		Permit:      message,
+		Deadline:    deadline,
+		Signature:   signature,
+		V:           v,
+		R:           r,
+		S:           sv,
+	})
+}
@@ -0,0 +1,450 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+// ERC-1967 storage slots, keccak256 of the slot name minus one.
+var (
+	implementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	adminSlot          = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+	beaconSlot         = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+)
+
+const contractSourceUpgrade = "upgrade"
+
+var (
+	erc1967ProxyABI = mustParseABI(`[
+{"type":"constructor","stateMutability":"payable","inputs":[{"name":"implementation","type":"address"},{"name":"data","type":"bytes"}]}
+]`)
+	transparentProxyABI = mustParseABI(`[
+{"type":"constructor","stateMutability":"payable","inputs":[{"name":"logic","type":"address"},{"name":"admin","type":"address"},{"name":"data","type":"bytes"}]}
+]`)
+	// upgradeableABI is implemented by UUPS implementations, and by
+	// transparent proxies for their admin. OpenZeppelin 5 removed upgradeTo
+	// and the upgrade of ProxyAdmin in favour of the AndCall variants.
+	upgradeableABI = mustParseABI(`[
+{"type":"function","name":"proxiableUUID","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+{"type":"function","name":"upgradeTo","stateMutability":"nonpayable","inputs":[{"name":"newImplementation","type":"address"}],"outputs":[]},
+{"type":"function","name":"upgradeToAndCall","stateMutability":"payable","inputs":[{"name":"newImplementation","type":"address"},{"name":"data","type":"bytes"}],"outputs":[]}
+]`)
+	proxyAdminABI = mustParseABI(`[
+{"type":"function","name":"owner","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+{"type":"function","name":"upgrade","stateMutability":"nonpayable","inputs":[{"name":"proxy","type":"address"},{"name":"implementation","type":"address"}],"outputs":[]},
+{"type":"function","name":"upgradeAndCall","stateMutability":"payable","inputs":[{"name":"proxy","type":"address"},{"name":"implementation","type":"address"},{"name":"data","type":"bytes"}],"outputs":[]}
+]`)
+)
+
+// proxyImplementation is a checked utils.ProxyImplementation.
+type proxyImplementation struct {
+	address  common.Address
+	abiJSON  string
+	abi      *abi.ABI
+	initCode []byte
+	// initData is the encoded initializer call, empty without initializer
+	initData []byte
+}
+
+// prepareImplementation reads the ABI of an implementation and encodes its
+// constructor and initializer, so bad requests fail before anything is sent.
+func (s *Service) prepareImplementation(chainId string, u *utils.ProxyImplementation) (*proxyImplementation, error) {
+	impl := &proxyImplementation{}
+	switch {
+	case u.ByteCode != "":
+	case common.IsHexAddress(u.Address):
+		impl.address = common.HexToAddress(u.Address)
+	default:
+		return nil, fmt.Errorf("an implementation address or byteCode is required")
+	}
+	if u.ABI != "" {
+		decoded, err := base64.StdEncoding.DecodeString(u.ABI)
+		if err != nil {
+			return nil, fmt.Errorf("ABI string error %s", err.Error())
+		}
+		impl.abiJSON = string(decoded)
+	} else if u.ByteCode == "" {
+		// an unregistered implementation can still be used without initializer
+		impl.abiJSON, _ = s.contracts.ABI(chainId, impl.address, 0)
+	}
+	if impl.abiJSON != "" {
+		parsed, err := abi.JSON(strings.NewReader(impl.abiJSON))
+		if err != nil {
+			return nil, fmt.Errorf("error reading ABI : %s", err.Error())
+		}
+		impl.abi = &parsed
+	}
+	if (u.ByteCode != "" || u.Initializer != "") && impl.abi == nil {
+		return nil, fmt.Errorf("the implementation ABI is required")
+	}
+	if u.ByteCode != "" {
+		params, err := utils.ConvertParams(*impl.abi, "", u.Params)
+		if err != nil {
+			return nil, fmt.Errorf("error converting params %s", err.Error())
+		}
+		args, err := impl.abi.Pack("", params...)
+		if err != nil {
+			return nil, fmt.Errorf("error packing constructor params : %s", err.Error())
+		}
+		impl.initCode = append(common.FromHex(u.ByteCode), args...)
+	}
+	if u.Initializer != "" {
+		params, err := utils.ConvertParams(*impl.abi, u.Initializer, u.InitializerParams)
+		if err != nil {
+			return nil, fmt.Errorf("error converting initializer params %s", err.Error())
+		}
+		if impl.initData, err = impl.abi.Pack(u.Initializer, params...); err != nil {
+			return nil, fmt.Errorf("error packing initializer : %s", err.Error())
+		}
+	}
+	return impl, nil
+}
+
+// deployImplementation deploys the implementation if it has init code and
+// waits for it to be mined, since the proxy calls into it on deploy.
+// Otherwise it checks the implementation has code.
+func (s *Service) deployImplementation(ctx context.Context, client *ethclient.Client, chain *utils.ChainConfig, w *Wallet,
+	impl *proxyImplementation, skipSimulation bool) (string, error) {
+	if impl.initCode == nil {
+		code, err := client.CodeAt(ctx, impl.address, nil)
+		if err != nil {
+			return "", fmt.Errorf("error reading implementation code : %s", err.Error())
+		}
+		if len(code) == 0 {
+			return "", fmt.Errorf("no contract at implementation %s", impl.address.String())
+		}
+		return "", nil
+	}
+	address, txnHash, err := s.deployCodeAndWait(ctx, client, chain, w, impl.abi, impl.abiJSON, "", impl.initCode, skipSimulation)
+	impl.address = address
+	return txnHash, err
+}
+
+// deployCodeAndWait deploys and registers a contract the next deploy depends
+// on, and waits for it to be mined.
+func (s *Service) deployCodeAndWait(ctx context.Context, client *ethclient.Client, chain *utils.ChainConfig, w *Wallet, contractABI *abi.ABI,
+	abiJSON, alias string, initCode []byte, skipSimulation bool) (common.Address, string, error) {
+	address, txn, err := s.deployCode(ctx, client, chain, w, contractABI, initCode, skipSimulation)
+	if err != nil {
+		return common.Address{}, "", err
+	}
+	txnHash := txn.Hash().String()
+	receipt, err := s.waitForReceipt(ctx, client, chain.ChainId, txn, contractABI)
+	if err != nil {
+		// the tracker registers the contract if the txn still succeeds
+		s.registerContractOnSuccess(txnHash, chain.ChainId, address, abiJSON, alias, contractSourceDeploy)
+		return address, txnHash, fmt.Errorf("error waiting for txn %s : %s", txnHash, err.Error())
+	}
+	if receipt.Status == utils.TxnStatusFailed {
+		return address, txnHash, fmt.Errorf("deploy txn %s failed", txnHash)
+	}
+	s.registerDeployedContract(chain.ChainId, address, abiJSON, alias, txnHash)
+	return address, txnHash, nil
+}
+
+// deployCode deploys init code from the wallet, simulating it first unless
+// skipSimulation is set.
+func (s *Service) deployCode(ctx context.Context, client *ethclient.Client, chain *utils.ChainConfig, w *Wallet, contractABI *abi.ABI,
+	initCode []byte, skipSimulation bool) (common.Address, *types.Transaction, error) {
+	if !skipSimulation {
+		msg := ethereum.CallMsg{From: common.HexToAddress(w.Address), Data: initCode}
+		if err := simulateTransaction(ctx, client, msg, contractABI); err != nil {
+			return common.Address{}, nil, err
+		}
+	}
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("error getting chain Id : %s", err.Error())
+	}
+	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, w, s.vault, chainId)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("error initializing transactor opts : %s", err.Error())
+	}
+	nonce, err := s.nonces.Reserve(ctx, client, w, chainId)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	txnOpts.Nonce = new(big.Int).SetUint64(nonce)
+	if err := setTransactionFees(ctx, txnOpts, client, chain); err != nil {
+		s.releaseNonce(w, chainId, nonce)
+		return common.Address{}, nil, err
+	}
+	address, txn, _, err := bind.DeployContract(txnOpts, abi.ABI{}, initCode, client)
+	if err != nil {
+		s.releaseNonce(w, chainId, nonce)
+		return common.Address{}, nil, fmt.Errorf("error deploying contract : %s", err.Error())
+	}
+	s.confirmNonce(w, chainId, nonce)
+	s.trackTransaction(w, chainId, txn, "deploy", "")
+	s.syncPlatformNonce(w, chainId, txn.Hash().String(), "deploy")
+	return address, txn, nil
+}
+
+// checkProxiable checks a UUPS implementation reports the ERC-1967
+// implementation slot, as upgradeToAndCall requires. A proxy pointing to an
+// implementation without it can never be upgraded.
+func checkProxiable(ctx context.Context, client *ethclient.Client, implementation common.Address) error {
+	out, err := callContractMethod(ctx, client, upgradeableABI, implementation, "proxiableUUID")
+	if err != nil {
+		return fmt.Errorf("implementation %s is not UUPS upgradeable : %s", implementation.String(), err.Error())
+	}
+	if uuid, ok := out[0].([32]byte); !ok || common.Hash(uuid) != implementationSlot {
+		return fmt.Errorf("implementation %s reports an unsupported proxiableUUID", implementation.String())
+	}
+	return nil
+}
+
+// readSlotAddress reads an address stored in a storage slot of a contract.
+func readSlotAddress(ctx context.Context, client *ethclient.Client, contract common.Address, slot common.Hash) (common.Address, error) {
+	value, err := client.StorageAt(ctx, contract, slot, nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(value), nil
+}
+
+// resolveProxy returns the address of a proxy given by address or name.
+func (s *Service) resolveProxy(chainId, proxy, name string) (common.Address, error) {
+	if name != "" {
+		return s.contracts.Lookup(chainId, name)
+	}
+	if !common.IsHexAddress(proxy) {
+		return common.Address{}, fmt.Errorf("a proxy address or contract name is required")
+	}
+	return common.HexToAddress(proxy), nil
+}
+
+// deployProxy godoc
+// @Summary Deploy upgradeable contract
+// @Description deploys an implementation unless an existing one is given, waits for it to be mined, then deploys an ERC-1967 proxy to it with the encoded initializer call. The proxy is registered with the implementation ABI.
+// @Param	request  body	utils.DeployProxyRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /deployProxy [post]
+func (s *Service) deployProxy(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.DeployProxyRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.ProxyByteCode == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	if u.Admin != "" && !common.IsHexAddress(u.Admin) {
+		return utils.BadRequestResponse(c, "invalid admin address", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	impl, err := s.prepareImplementation(chain.ChainId, &u.Implementation)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	if u.ProxyKind != utils.ProxyKindUUPS && u.ProxyKind != utils.ProxyKindTransparent {
+		return utils.BadRequestResponse(c, "unsupported proxy kind "+u.ProxyKind, nil)
+	}
+	implTxnHash, err := s.deployImplementation(ctx, client, chain, wallet, impl, u.SkipSimulation)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	var args []byte
+	if u.ProxyKind == utils.ProxyKindUUPS {
+		if err := checkProxiable(ctx, client, impl.address); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), &utils.DeployProxyResponse{ImplementationAddress: impl.address.String(), ImplementationTxnHash: implTxnHash})
+		}
+		args, err = erc1967ProxyABI.Pack("", impl.address, impl.initData)
+	} else {
+		admin := common.HexToAddress(wallet.Address)
+		if u.Admin != "" {
+			admin = common.HexToAddress(u.Admin)
+		}
+		args, err = transparentProxyABI.Pack("", impl.address, admin, impl.initData)
+	}
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error packing proxy params : "+err.Error(), nil)
+	}
+	proxy, txn, err := s.deployCode(ctx, client, chain, wallet, impl.abi, append(common.FromHex(u.ProxyByteCode), args...), u.SkipSimulation)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	if impl.abiJSON != "" {
+		s.registerContractOnSuccess(txn.Hash().String(), chain.ChainId, proxy, impl.abiJSON, u.ContractName, contractSourceDeploy)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeployProxyResponse{
+		ProxyAddress:          proxy.String(),
+		TxnHash:               txn.Hash().String(),
+		ImplementationAddress: impl.address.String(),
+		ImplementationTxnHash: implTxnHash,
+	})
+}
+
+// upgradeCallSucceeds tells whether an upgrade through the AndCall variant with
+// empty data succeeds. OpenZeppelin 5 contracts only have that variant, older
+// ones revert on it since they delegate the empty call to the implementation,
+// and are upgraded with upgradeTo or upgrade instead.
+func upgradeCallSucceeds(ctx context.Context, client *ethclient.Client, from, contract common.Address, contractABI abi.ABI, method string,
+	args ...interface{}) bool {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return false
+	}
+	_, err = client.CallContract(ctx, ethereum.CallMsg{From: from, To: &contract, Data: data}, nil)
+	return err == nil
+}
+
+// upgradeProxy godoc
+// @Summary Upgrade proxy
+// @Description points an ERC-1967 proxy to a new implementation, deploying it first unless an existing one is given. UUPS proxies are upgraded with upgradeToAndCall, or upgradeTo for contracts before OpenZeppelin 5, transparent proxies through the ProxyAdmin in their admin slot. The proxy registers the new implementation ABI as a new version.
+// @Param	request  body	utils.UpgradeProxyRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /upgradeProxy [post]
+func (s *Service) upgradeProxy(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.UpgradeProxyRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	proxy, err := s.resolveProxy(chain.ChainId, u.Proxy, u.ContractName)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	impl, err := s.prepareImplementation(chain.ChainId, &u.Implementation)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	if u.ProxyKind != utils.ProxyKindUUPS && u.ProxyKind != utils.ProxyKindTransparent {
+		return utils.BadRequestResponse(c, "unsupported proxy kind "+u.ProxyKind, nil)
+	}
+	implTxnHash, err := s.deployImplementation(ctx, client, chain, wallet, impl, u.SkipSimulation)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	from := common.HexToAddress(wallet.Address)
+	withCall := u.UpgradeAndCall || len(impl.initData) > 0
+	admin, err := readSlotAddress(ctx, client, proxy, adminSlot)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error reading proxy admin : "+err.Error(), nil)
+	}
+	var txn *types.Transaction
+	switch {
+	case u.ProxyKind == utils.ProxyKindUUPS || admin == from:
+		// UUPS proxies are upgraded through the implementation, transparent
+		// proxies with the wallet as admin take the same calls
+		if u.ProxyKind == utils.ProxyKindUUPS {
+			if err := checkProxiable(ctx, client, impl.address); err != nil {
+				return utils.BadRequestResponse(c, err.Error(), &utils.UpgradeProxyResponse{ImplementationAddress: impl.address.String(), ImplementationTxnHash: implTxnHash})
+			}
+		}
+		if withCall || upgradeCallSucceeds(ctx, client, from, proxy, upgradeableABI, "upgradeToAndCall", impl.address, impl.initData) {
+			txn, err = s.transactContract(ctx, client, wallet, upgradeableABI, proxy, u.SkipSimulation, "upgrade", "upgradeToAndCall", impl.address, impl.initData)
+		} else {
+			txn, err = s.transactContract(ctx, client, wallet, upgradeableABI, proxy, u.SkipSimulation, "upgrade", "upgradeTo", impl.address)
+		}
+	case admin == (common.Address{}):
+		return utils.BadRequestResponse(c, "proxy "+proxy.String()+" has no admin", nil)
+	default:
+		if withCall || upgradeCallSucceeds(ctx, client, from, admin, proxyAdminABI, "upgradeAndCall", proxy, impl.address, impl.initData) {
+			txn, err = s.transactContract(ctx, client, wallet, proxyAdminABI, admin, u.SkipSimulation, "upgrade", "upgradeAndCall", proxy, impl.address, impl.initData)
+		} else {
+			txn, err = s.transactContract(ctx, client, wallet, proxyAdminABI, admin, u.SkipSimulation, "upgrade", "upgrade", proxy, impl.address)
+		}
+	}
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	if impl.abiJSON != "" {
+		s.registerContractOnSuccess(txn.Hash().String(), chain.ChainId, proxy, impl.abiJSON, "", contractSourceUpgrade)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.UpgradeProxyResponse{
+		TxnHash:               txn.Hash().String(),
+		ImplementationAddress: impl.address.String(),
+		ImplementationTxnHash: implTxnHash,
+	})
+}
+
+// getProxy godoc
+// @Summary Get proxy
+// @Description reads the implementation, admin and beacon of a proxy from its ERC-1967 storage slots, and the owner of the admin when it is a ProxyAdmin.
+// @Param	request  body	utils.GetProxyRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getProxy [post]
+func (s *Service) getProxy(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.GetProxyRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	proxy, err := s.resolveProxy(chain.ChainId, u.Proxy, u.ContractName)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	info := &utils.ProxyInfo{Proxy: proxy.String()}
+	slots := []struct {
+		slot  common.Hash
+		value *string
+	}{{implementationSlot, &info.Implementation}, {adminSlot, &info.Admin}, {beaconSlot, &info.Beacon}}
+	for _, slot := range slots {
+		address, err := readSlotAddress(ctx, client, proxy, slot.slot)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error reading proxy storage : "+err.Error(), nil)
+		}
+		if address != (common.Address{}) {
+			*slot.value = address.String()
+		}
+	}
+	if info.Admin != "" {
+		// an EOA admin or an admin without owner has no owner to report
+		if out, err := callContractMethod(ctx, client, proxyAdminABI, common.HexToAddress(info.Admin), "owner"); err == nil {
+			if owner, ok := out[0].(common.Address); ok {
+				info.AdminOwner = owner.String()
+			}
+		}
+	}
+	return utils.SendSuccessResponse(c, "", info)
+}
@@ -0,0 +1,346 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// minimum fee increase in percent nodes accept for a replacement transaction
+const minFeeBumpPercent = 10
+
+// types of replacement transactions
+const (
+	txnTypeSpeedUp = "speedUp"
+	txnTypeCancel  = "cancel"
+)
+
+// errFeeCeilingReached is returned when the fee ceiling leaves no room for the
+// minimum fee bump of a replacement.
+var errFeeCeilingReached = errors.New("fee ceiling reached")
+
+// replacementFees controls the fees of a replacement transaction. Fees left
+// nil are derived from the replaced transaction and the current network fees.
+// Derived fees are capped at MaxFee if set.
+type replacementFees struct {
+	BumpPercent int64
+	GasPrice    *big.Int
+	GasFeeCap   *big.Int
+	GasTipCap   *big.Int
+	MaxFee      *big.Int
+}
+
+// capFee limits fee to the ceiling, if any.
+func (f replacementFees) capFee(fee *big.Int) *big.Int {
+	if f.MaxFee != nil && fee.Cmp(f.MaxFee) > 0 {
+		return new(big.Int).Set(f.MaxFee)
+	}
+	return fee
+}
+
+// bumpFee increases fee by percent, rounding up.
+func bumpFee(fee *big.Int, percent int64) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// replacementTxn builds an unsigned transaction with the nonce of original and
+// fees bumped by at least the given percentage. A speed-up keeps recipient,
+// value and data, a cancel is a zero value transfer to the sender itself.
+// Legacy transactions are replaced by legacy ones with a higher gas price,
+// EIP-1559 ones by EIP-1559 ones with a higher tip and fee cap.
+func replacementTxn(ctx context.Context, client *ethclient.Client, from common.Address, original *types.Transaction, cancel bool, fees replacementFees) (*types.Transaction, error) {
+	if fees.BumpPercent < minFeeBumpPercent {
+		fees.BumpPercent = minFeeBumpPercent
+	}
+	to, value, data, gas := original.To(), original.Value(), original.Data(), original.Gas()
+	if cancel {
+		to, value, data, gas = &from, big.NewInt(0), nil, 21000
+	}
+	switch original.Type() {
+	case types.LegacyTxType:
+		minGasPrice := bumpFee(original.GasPrice(), minFeeBumpPercent)
+		gasPrice := fees.GasPrice
+		if gasPrice == nil {
+			suggested, err := client.SuggestGasPrice(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error calculating gas price : %s", err.Error())
+			}
+			gasPrice = fees.capFee(maxBig(bumpFee(original.GasPrice(), fees.BumpPercent), suggested))
+			if gasPrice.Cmp(minGasPrice) < 0 {
+				return nil, errFeeCeilingReached
+			}
+		}
+		if gasPrice.Cmp(minGasPrice) < 0 {
+			return nil, fmt.Errorf("gas price must be at least %s wei to replace the txn", minGasPrice.String())
+		}
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    original.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}), nil
+	case types.DynamicFeeTxType:
+		minTipCap := bumpFee(original.GasTipCap(), minFeeBumpPercent)
+		minFeeCap := bumpFee(original.GasFeeCap(), minFeeBumpPercent)
+		tipCap := fees.GasTipCap
+		if tipCap == nil {
+			suggested, err := client.SuggestGasTipCap(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error calculating gas tip cap : %s", err.Error())
+			}
+			tipCap = maxBig(bumpFee(original.GasTipCap(), fees.BumpPercent), suggested)
+		}
+		feeCap := fees.GasFeeCap
+		if feeCap == nil {
+			header, err := client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("error getting latest header : %s", err.Error())
+			}
+			feeCap = bumpFee(original.GasFeeCap(), fees.BumpPercent)
+			if header.BaseFee != nil {
+				// keep the txn includable for a few blocks of rising base fee
+				current := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tipCap)
+				feeCap = maxBig(feeCap, current)
+			}
+			feeCap = fees.capFee(maxBig(feeCap, tipCap))
+			if feeCap.Cmp(minFeeCap) < 0 {
+				return nil, errFeeCeilingReached
+			}
+			if tipCap.Cmp(feeCap) > 0 {
+				tipCap = feeCap
+			}
+			if tipCap.Cmp(minTipCap) < 0 {
+				return nil, errFeeCeilingReached
+			}
+		}
+		if tipCap.Cmp(minTipCap) < 0 {
+			return nil, fmt.Errorf("max priority fee must be at least %s wei to replace the txn", minTipCap.String())
+		}
+		if feeCap.Cmp(minFeeCap) < 0 {
+			return nil, fmt.Errorf("max fee must be at least %s wei to replace the txn", minFeeCap.String())
+		}
+		if feeCap.Cmp(tipCap) < 0 {
+			return nil, fmt.Errorf("max fee must not be lower than max priority fee")
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    original.ChainId(),
+			Nonce:      original.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: original.AccessList(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported txn type %d", original.Type())
+	}
+}
+
+// replaceTransaction signs and broadcasts a replacement for a tracked pending
+// transaction and records the replacement on both tracked transactions.
+func (s *Service) replaceTransaction(ctx context.Context, client *ethclient.Client, w *Wallet, record *utils.TransactionRecord, cancel bool,
+	fees replacementFees) (*types.Transaction, error) {
+	if err := replaceable(record); err != nil {
+		return nil, err
+	}
+	chainId, ok := new(big.Int).SetString(record.ChainId, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid chain id %s", record.ChainId)
+	}
+	rawTxn, err := hexutil.Decode(record.RawTxn)
+	if err != nil {
+		return nil, err
+	}
+	original := new(types.Transaction)
+	if err := original.UnmarshalBinary(rawTxn); err != nil {
+		return nil, err
+	}
+	from := common.HexToAddress(w.Address)
+	mined, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return nil, err
+	}
+	if original.Nonce() < mined {
+		return nil, fmt.Errorf("nonce %d of txn is already mined", original.Nonce())
+	}
+	txn, err := replacementTxn(ctx, client, from, original, cancel, fees)
+	if err != nil {
+		return nil, err
+	}
+	txnOpts, err := TransactionOptionsWithKMSSigning(ctx, w, s.vault, chainId)
+	if err != nil {
+		return nil, err
+	}
+	txn, err = txnOpts.Signer(txnOpts.From, txn)
+	if err != nil {
+		return nil, err
+	}
+	// mark the transaction replaced before broadcasting, re-checking it under
+	// the update so a concurrent replacement or receipt is not overwritten
+	replacedBy := txn.Hash().String()
+	replaced, err := s.tracker.Update(record.TxnHash, func(record *utils.TransactionRecord) error {
+		if err := replaceable(record); err != nil {
+			return err
+		}
+		record.Status = utils.TxnStatusReplaced
+		record.ReplacedBy = replacedBy
+		record.UpdatedAt = time.Now().Unix()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SendTransaction(ctx, txn); err != nil {
+		_, revertErr := s.tracker.Update(record.TxnHash, func(record *utils.TransactionRecord) error {
+			if record.Status != utils.TxnStatusReplaced || record.ReplacedBy != replacedBy {
+				return errTxnUnchanged
+			}
+			record.Status = utils.TxnStatusPending
+			record.ReplacedBy = ""
+			record.UpdatedAt = time.Now().Unix()
+			return nil
+		})
+		if revertErr != nil && revertErr != errTxnUnchanged {
+			s.e.Logger.Errorf(revertErr.Error())
+		}
+		return nil, err
+	}
+	txnType := txnTypeSpeedUp
+	if cancel {
+		txnType = txnTypeCancel
+	}
+	if _, err := s.tracker.Track(w, chainId, txn, txnType, record.ReferenceId); err != nil {
+		s.e.Logger.Errorf("error tracking txn %s : %s", replacedBy, err.Error())
+		return txn, nil
+	}
+	_, err = s.tracker.Update(replacedBy, func(replacement *utils.TransactionRecord) error {
+		replacement.Replaces = replaced.TxnHash
+		replacement.Attempts = replaced.Attempts + 1
+		return nil
+	})
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+	}
+	return txn, nil
+}
+
+// replaceable returns why a tracked transaction can not be replaced, if it
+// can not.
+func replaceable(record *utils.TransactionRecord) error {
+	if record.Status == utils.TxnStatusPending {
+		return nil
+	}
+	if record.ReplacedBy != "" {
+		return fmt.Errorf("txn is %s by %s", record.Status, record.ReplacedBy)
+	}
+	return fmt.Errorf("txn is %s and can not be replaced", record.Status)
+}
+
+// speedUpTransaction godoc
+// @Summary Speed up transaction
+// @Description re-signs a pending transaction with the same nonce and higher fees.
+// @Param	request  body	utils.ReplaceTransactionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /speedUpTransaction [post]
+func (s *Service) speedUpTransaction(c echo.Context) error {
+	return s.replaceTransactionHandler(c, false)
+}
+
+// cancelTransaction godoc
+// @Summary Cancel transaction
+// @Description replaces a pending transaction with a zero value transfer to the wallet itself.
+// @Param	request  body	utils.ReplaceTransactionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /cancelTransaction [post]
+func (s *Service) cancelTransaction(c echo.Context) error {
+	return s.replaceTransactionHandler(c, true)
+}
+
+func (s *Service) replaceTransactionHandler(c echo.Context, cancel bool) error {
+	ctx := c.Request().Context()
+	u := new(utils.ReplaceTransactionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.TxnHash == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	walletId, err := uuid.Parse(u.WalletId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	walletBytes, err := s.db.Get([]byte(utils.NAMESPACE), walletId.NodeID())
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	wallet := &Wallet{}
+	if err := json.Unmarshal(walletBytes, wallet); err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	tracked, err := s.tracker.Has(u.TxnHash)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting txn : "+err.Error(), nil)
+	}
+	if !tracked {
+		return utils.BadRequestResponse(c, "transaction not found", nil)
+	}
+	record, err := s.tracker.Get(u.TxnHash)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting txn : "+err.Error(), nil)
+	}
+	if !strings.EqualFold(record.WalletId, wallet.WalletId) {
+		return utils.UnauthorizedResponse(c, "txn was not sent by the wallet", nil)
+	}
+	var fees replacementFees
+	for _, fee := range []struct {
+		value utils.Amount
+		dest  **big.Int
+	}{{u.GasPrice, &fees.GasPrice}, {u.MaxFeePerGas, &fees.GasFeeCap}, {u.MaxPriorityFeePerGas, &fees.GasTipCap}} {
+		if !fee.value.IsSet() {
+			continue
+		}
+		value, err := fee.value.Wei()
+		if err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		*fee.dest = value
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, record.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	txn, err := s.replaceTransaction(ctx, client, wallet, record, cancel, fees)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error replacing txn : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.ReplaceTransactionResponse{TxnHash: txn.Hash().String(), ReplacedTxnHash: record.TxnHash})
+}
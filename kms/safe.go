@@ -0,0 +1,512 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/labstack/echo/v4"
+)
+
+// Safe v1.3.0 contracts, deployed at the same addresses on most chains
+const (
+	safeSingletonL2     = "0x3E5c63644E683549055b9Be8653de26E0B4CD36E"
+	safeProxyFactory    = "0xa6B71E26C5e0845f74c812102Ca7114b6a896AB2"
+	safeFallbackHandler = "0xf48f2B2d2a534e402487b3ee7C18c33Aec0Fe5e4"
+)
+
+const (
+	safeSignatureLength = 65
+	// v of eth_sign signatures, which the Safe tells apart by adding 4
+	safeEthSignRecoveryBase = 31
+)
+
+const safeABIJSON = `[
+{"type":"function","name":"VERSION","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"nonce","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"getThreshold","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"getOwners","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address[]"}]},
+{"type":"function","name":"setup","stateMutability":"nonpayable","inputs":[{"name":"_owners","type":"address[]"},{"name":"_threshold","type":"uint256"},{"name":"to","type":"address"},{"name":"data","type":"bytes"},{"name":"fallbackHandler","type":"address"},{"name":"paymentToken","type":"address"},{"name":"payment","type":"uint256"},{"name":"paymentReceiver","type":"address"}],"outputs":[]},
+{"type":"function","name":"execTransaction","stateMutability":"payable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}],"outputs":[{"name":"success","type":"bool"}]}
+]`
+
+const safeProxyFactoryABIJSON = `[
+{"type":"function","name":"proxyCreationCode","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"bytes"}]},
+{"type":"function","name":"createProxyWithNonce","stateMutability":"nonpayable","inputs":[{"name":"_singleton","type":"address"},{"name":"initializer","type":"bytes"},{"name":"saltNonce","type":"uint256"}],"outputs":[{"name":"proxy","type":"address"}]}
+]`
+
+var (
+	safeABI             = mustParseABI(safeABIJSON)
+	safeProxyFactoryABI = mustParseABI(safeProxyFactoryABIJSON)
+)
+
+// safeTxTypes are the EIP-712 types of a Safe v1.3.0 transaction.
+var safeTxTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"SafeTx": {
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "data", Type: "bytes"},
+		{Name: "operation", Type: "uint8"},
+		{Name: "safeTxGas", Type: "uint256"},
+		{Name: "baseGas", Type: "uint256"},
+		{Name: "gasPrice", Type: "uint256"},
+		{Name: "gasToken", Type: "address"},
+		{Name: "refundReceiver", Type: "address"},
+		{Name: "nonce", Type: "uint256"},
+	},
+}
+
+// safeTransaction is a parsed utils.SafeTransaction.
+type safeTransaction struct {
+	to             common.Address
+	value          *big.Int
+	data           []byte
+	operation      uint8
+	safeTxGas      *big.Int
+	baseGas        *big.Int
+	gasPrice       *big.Int
+	gasToken       common.Address
+	refundReceiver common.Address
+	nonce          *big.Int
+}
+
+// parseSafeTransaction reads a Safe transaction, taking the current nonce of
+// the Safe when none is given.
+func parseSafeTransaction(ctx context.Context, client *ethclient.Client, safe common.Address, t utils.SafeTransaction) (*safeTransaction, error) {
+	if !common.IsHexAddress(t.To) {
+		return nil, fmt.Errorf("invalid to address %s", t.To)
+	}
+	for _, address := range []string{t.GasToken, t.RefundReceiver} {
+		if address != "" && !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid address %s", address)
+		}
+	}
+	if t.Operation != utils.SafeOperationCall && t.Operation != utils.SafeOperationDelegateCall {
+		return nil, fmt.Errorf("operation must be 0 for a call or 1 for a delegatecall")
+	}
+	tx := &safeTransaction{
+		to:             common.HexToAddress(t.To),
+		operation:      t.Operation,
+		safeTxGas:      new(big.Int).SetUint64(t.SafeTxGas),
+		baseGas:        new(big.Int).SetUint64(t.BaseGas),
+		gasToken:       common.HexToAddress(t.GasToken),
+		refundReceiver: common.HexToAddress(t.RefundReceiver),
+	}
+	var err error
+	if tx.value, err = t.Value.Wei(); err != nil {
+		return nil, fmt.Errorf("invalid value : %s", err.Error())
+	}
+	if tx.gasPrice, err = t.GasPrice.Wei(); err != nil {
+		return nil, fmt.Errorf("invalid gasPrice : %s", err.Error())
+	}
+	if t.Data != "" {
+		if tx.data, err = hexutil.Decode(t.Data); err != nil {
+			return nil, fmt.Errorf("invalid data : %s", err.Error())
+		}
+	}
+	if t.Nonce != "" {
+		nonce, ok := new(big.Int).SetString(t.Nonce, 10)
+		if !ok || nonce.Sign() < 0 {
+			return nil, fmt.Errorf("invalid nonce %s", t.Nonce)
+		}
+		tx.nonce = nonce
+		return tx, nil
+	}
+	out, err := callContractMethod(ctx, client, safeABI, safe, "nonce")
+	if err != nil {
+		return nil, fmt.Errorf("error reading Safe nonce, %s may not be a Safe : %s", safe.String(), err.Error())
+	}
+	tx.nonce = out[0].(*big.Int)
+	return tx, nil
+}
+
+// typedData returns the EIP-712 SafeTx owners sign.
+func (tx *safeTransaction) typedData(chainId *big.Int, safe common.Address) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types:       safeTxTypes,
+		PrimaryType: "SafeTx",
+		Domain: apitypes.TypedDataDomain{
+			ChainId:           (*math.HexOrDecimal256)(chainId),
+			VerifyingContract: safe.String(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"to":             tx.to.String(),
+			"value":          tx.value.String(),
+			"data":           hexutil.Encode(tx.data),
+			"operation":      strconv.Itoa(int(tx.operation)),
+			"safeTxGas":      tx.safeTxGas.String(),
+			"baseGas":        tx.baseGas.String(),
+			"gasPrice":       tx.gasPrice.String(),
+			"gasToken":       tx.gasToken.String(),
+			"refundReceiver": tx.refundReceiver.String(),
+			"nonce":          tx.nonce.String(),
+		},
+	}
+}
+
+// safeOwners returns the owners and threshold of a Safe.
+func safeOwners(ctx context.Context, client *ethclient.Client, safe common.Address) ([]common.Address, uint64, error) {
+	out, err := callContractMethod(ctx, client, safeABI, safe, "getOwners")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading Safe owners, %s may not be a Safe : %s", safe.String(), err.Error())
+	}
+	owners := out[0].([]common.Address)
+	out, err = callContractMethod(ctx, client, safeABI, safe, "getThreshold")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading Safe threshold : %s", err.Error())
+	}
+	return owners, out[0].(*big.Int).Uint64(), nil
+}
+
+func isSafeOwner(owners []common.Address, address common.Address) bool {
+	for _, owner := range owners {
+		if owner == address {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverSafeSigner returns the owner that signed a Safe transaction hash,
+// either as EIP-712 with v 27 or 28, or with eth_sign and v 31 or 32 as the
+// Safe expects it.
+func recoverSafeSigner(safeTxHash common.Hash, signature []byte) (common.Address, error) {
+	if len(signature) != safeSignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(signature))
+	}
+	hash := safeTxHash.Bytes()
+	sig := append([]byte{}, signature...)
+	switch v := sig[crypto.RecoveryIDOffset]; {
+	case v == 27 || v == 28:
+		sig[crypto.RecoveryIDOffset] = v - 27
+	case v == safeEthSignRecoveryBase || v == safeEthSignRecoveryBase+1:
+		sig[crypto.RecoveryIDOffset] = v - safeEthSignRecoveryBase
+		hash = accounts.TextHash(hash)
+	default:
+		return common.Address{}, fmt.Errorf("unsupported signature type v=%d", v)
+	}
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// predictSafeAddress returns the address createProxyWithNonce deploys a Safe
+// at, from the factory's proxy creation code, the singleton, the initializer
+// and the salt nonce.
+func predictSafeAddress(ctx context.Context, client *ethclient.Client, factory, singleton common.Address, initializer []byte, saltNonce *big.Int) (common.Address, error) {
+	out, err := callContractMethod(ctx, client, safeProxyFactoryABI, factory, "proxyCreationCode")
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error reading proxy creation code, %s may not be a Safe proxy factory : %s", factory.String(), err.Error())
+	}
+	initCode := append(out[0].([]byte), common.LeftPadBytes(singleton.Bytes(), 32)...)
+	salt := crypto.Keccak256Hash(crypto.Keccak256(initializer), common.LeftPadBytes(saltNonce.Bytes(), 32))
+	return create2Address(factory, salt, initCode), nil
+}
+
+// deploySafe godoc
+// @Summary Deploy Safe
+// @Description deploys a Safe v1.3.0 proxy through the Safe proxy factory, owned by the given owners or by the wallet alone. The address is predicted before signing, and when the Safe already exists no transaction is sent.
+// @Param	request  body	utils.DeploySafeRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /deploySafe [post]
+func (s *Service) deploySafe(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.DeploySafeRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	if len(u.Owners) == 0 {
+		u.Owners = []string{wallet.Address}
+	}
+	owners := make([]common.Address, len(u.Owners))
+	for i, owner := range u.Owners {
+		if !common.IsHexAddress(owner) {
+			return utils.BadRequestResponse(c, "invalid owner address "+owner, nil)
+		}
+		owners[i] = common.HexToAddress(owner)
+	}
+	if u.Threshold == 0 {
+		u.Threshold = 1
+	}
+	if u.Threshold > uint64(len(owners)) {
+		return utils.BadRequestResponse(c, fmt.Sprintf("threshold %d is more than the %d owners", u.Threshold, len(owners)), nil)
+	}
+	contracts := map[string]string{"singleton": safeSingletonL2, "factory": safeProxyFactory, "fallbackHandler": safeFallbackHandler}
+	for name, override := range map[string]string{"singleton": u.Singleton, "factory": u.Factory, "fallbackHandler": u.FallbackHandler} {
+		if override == "" {
+			continue
+		}
+		if !common.IsHexAddress(override) {
+			return utils.BadRequestResponse(c, "invalid "+name+" address", nil)
+		}
+		contracts[name] = override
+	}
+	singleton, factory := common.HexToAddress(contracts["singleton"]), common.HexToAddress(contracts["factory"])
+	initializer, err := safeABI.Pack("setup", owners, new(big.Int).SetUint64(u.Threshold), common.Address{}, []byte{},
+		common.HexToAddress(contracts["fallbackHandler"]), common.Address{}, new(big.Int), common.Address{})
+	if err != nil {
+		return utils.BadRequestResponse(c, "error packing Safe setup : "+err.Error(), nil)
+	}
+	saltNonce := new(big.Int).SetUint64(u.SaltNonce)
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	safe, err := predictSafeAddress(ctx, client, factory, singleton, initializer, saltNonce)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	code, err := client.CodeAt(ctx, safe, nil)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if len(code) > 0 {
+		return utils.SendSuccessResponse(c, "Safe already deployed", &utils.DeploySafeResponse{Safe: safe.String(), AlreadyDeployed: true})
+	}
+	txn, err := s.transactContract(ctx, client, wallet, safeProxyFactoryABI, factory, u.SkipSimulation, "deploySafe", "createProxyWithNonce", singleton, initializer, saltNonce)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", &utils.DeploySafeResponse{Safe: safe.String(), TxnHash: txn.Hash().String()})
+}
+
+// getSafe godoc
+// @Summary Get Safe
+// @Description returns the version, owners, threshold and nonce of a Safe.
+// @Param	request  body	utils.GetSafeRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getSafe [post]
+func (s *Service) getSafe(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.GetSafeRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Safe) {
+		return utils.BadRequestResponse(c, "invalid Safe address", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	safe := common.HexToAddress(u.Safe)
+	owners, threshold, err := safeOwners(ctx, client, safe)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	res := &utils.SafeInfo{Safe: safe.String(), Owners: make([]string, len(owners)), Threshold: threshold}
+	for i, owner := range owners {
+		res.Owners[i] = owner.String()
+	}
+	out, err := callContractMethod(ctx, client, safeABI, safe, "nonce")
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error reading Safe nonce : "+err.Error(), nil)
+	}
+	res.Nonce = out[0].(*big.Int).String()
+	if out, err = callContractMethod(ctx, client, safeABI, safe, "VERSION"); err == nil {
+		res.Version = out[0].(string)
+	}
+	return utils.SendSuccessResponse(c, "", res)
+}
+
+// signSafeTransaction godoc
+// @Summary Sign Safe transaction
+// @Description signs the EIP-712 SafeTx hash of a Safe transaction with a wallet that owns the Safe, for executing it once enough owners signed.
+// @Param	request  body	utils.SignSafeTransactionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /signSafeTransaction [post]
+func (s *Service) signSafeTransaction(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.SignSafeTransactionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Safe) {
+		return utils.BadRequestResponse(c, "invalid Safe address", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	safe := common.HexToAddress(u.Safe)
+	owners, _, err := safeOwners(ctx, client, safe)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	if !isSafeOwner(owners, common.HexToAddress(wallet.Address)) {
+		return utils.BadRequestResponse(c, fmt.Sprintf("wallet %s is not an owner of Safe %s", wallet.Address, safe.String()), nil)
+	}
+	tx, err := parseSafeTransaction(ctx, client, safe, u.Transaction)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	data := tx.typedData(chainId, safe)
+	safeTxHash, err := typedDataHash(data)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error hashing Safe transaction : "+err.Error(), nil)
+	}
+	signature, err := getEIP712Signature(ctx, wallet, s.vault, data)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting signature : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Signed Safe transaction successfully", &utils.SignSafeTransactionResponse{
+		SafeTxHash: safeTxHash.String(),
+		Nonce:      tx.nonce.String(),
+		Signer:     common.HexToAddress(wallet.Address).String(),
+		Signature:  signature,
+	})
+}
+
+// executeSafeTransaction godoc
+// @Summary Execute Safe transaction
+// @Description signs a Safe transaction with the KMS owners in ownerWalletIds, adds the signatures collected elsewhere, and once the threshold is met calls execTransaction on the Safe with the signatures ordered by owner. The transaction is sent by walletId.
+// @Param	request  body	utils.ExecuteSafeTransactionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /executeSafeTransaction [post]
+func (s *Service) executeSafeTransaction(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.ExecuteSafeTransactionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if !common.IsHexAddress(u.Safe) {
+		return utils.BadRequestResponse(c, "invalid Safe address", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	safe := common.HexToAddress(u.Safe)
+	owners, threshold, err := safeOwners(ctx, client, safe)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	tx, err := parseSafeTransaction(ctx, client, safe, u.Transaction)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	data := tx.typedData(chainId, safe)
+	safeTxHash, err := typedDataHash(data)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error hashing Safe transaction : "+err.Error(), nil)
+	}
+	signatures := make(map[common.Address][]byte)
+	for _, sig := range u.Signatures {
+		signature, err := hexutil.Decode(sig.Signature)
+		if err != nil {
+			return utils.BadRequestResponse(c, "invalid signature : "+err.Error(), nil)
+		}
+		signer, err := recoverSafeSigner(safeTxHash, signature)
+		if err != nil {
+			return utils.BadRequestResponse(c, "invalid signature : "+err.Error(), nil)
+		}
+		if sig.Signer != "" && common.HexToAddress(sig.Signer) != signer {
+			return utils.BadRequestResponse(c, fmt.Sprintf("signature of %s was signed by %s, it may be for another transaction", sig.Signer, signer.String()), nil)
+		}
+		if !isSafeOwner(owners, signer) {
+			return utils.BadRequestResponse(c, fmt.Sprintf("signer %s is not an owner of Safe %s", signer.String(), safe.String()), nil)
+		}
+		signatures[signer] = signature
+	}
+	for _, id := range u.OwnerWalletIds {
+		owner, err := s.getWallet(id)
+		if err != nil {
+			return utils.UnauthorizedResponse(c, err.Error(), nil)
+		}
+		address := common.HexToAddress(owner.Address)
+		if !isSafeOwner(owners, address) {
+			return utils.BadRequestResponse(c, fmt.Sprintf("wallet %s is not an owner of Safe %s", owner.Address, safe.String()), nil)
+		}
+		if _, ok := signatures[address]; ok {
+			continue
+		}
+		signature, err := getEIP712Signature(ctx, owner, s.vault, data)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, "error getting signature : "+err.Error(), nil)
+		}
+		signatures[address] = common.FromHex(signature)
+	}
+	if uint64(len(signatures)) < threshold {
+		return utils.BadRequestResponse(c, fmt.Sprintf("%d of %d required owner signatures", len(signatures), threshold), nil)
+	}
+	// the Safe requires signatures ordered by owner address
+	signers := make([]common.Address, 0, len(signatures))
+	for signer := range signatures {
+		signers = append(signers, signer)
+	}
+	sort.Slice(signers, func(i, j int) bool { return bytes.Compare(signers[i].Bytes(), signers[j].Bytes()) < 0 })
+	res := &utils.ExecuteSafeTransactionResponse{SafeTxHash: safeTxHash.String(), Signatures: make([]utils.SafeSignature, len(signers))}
+	var packed []byte
+	for i, signer := range signers {
+		packed = append(packed, signatures[signer]...)
+		res.Signatures[i] = utils.SafeSignature{Signer: signer.String(), Signature: hexutil.Encode(signatures[signer])}
+	}
+	txn, err := s.transactContract(ctx, client, wallet, safeABI, safe, u.SkipSimulation, "safeExecute", "execTransaction",
+		tx.to, tx.value, tx.data, tx.operation, tx.safeTxGas, tx.baseGas, tx.gasPrice, tx.gasToken, tx.refundReceiver, packed)
+	if err != nil {
+		return transactFailureResponse(c, err)
+	}
+	res.TxnHash = txn.Hash().String()
+	return utils.SendSuccessResponse(c, "Signed and executed txn successfully", res)
+}
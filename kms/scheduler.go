@@ -7,10 +7,12 @@ import (
 	"math/big"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 	"wallet-kms/utils"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/google/uuid"
@@ -28,6 +30,7 @@ func (s *Service) ScheduleService() error {
 		s.executor.Publish(s.DeployContracts, ctx)
 		s.executor.Publish(s.SubmitTransactions, ctx)
 		s.executor.Publish(s.ApprovePendingWallets, ctx)
+		s.executor.Publish(s.BumpStuckTransactions, ctx)
 	}
 }
 
@@ -52,22 +55,22 @@ func (s *Service) DeployContracts(ctx context.Context) {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		client, err := utils.GetEthereumClient(ctx, s.config)
+		chain, err := s.config.Chains.Get(record.ChainId.String())
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		chainId, err := client.ChainID(ctx)
+		client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		rawDecodedText, err := base64.StdEncoding.DecodeString(record.ABI)
+		chainId, err := client.ChainID(ctx)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		nonce, err := utils.GetNonceFromPlatform(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
+		rawDecodedText, err := base64.StdEncoding.DecodeString(record.ABI)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
@@ -77,28 +80,42 @@ func (s *Service) DeployContracts(ctx context.Context) {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		transactOpts.Nonce = big.NewInt(int64(nonce))
-		tipCap, _ := client.SuggestGasTipCap(ctx)
-		feeCap, _ := client.SuggestGasPrice(ctx)
-		transactOpts.GasFeeCap = feeCap
-		transactOpts.GasTipCap = tipCap
+		if err := setTransactionFees(ctx, transactOpts, client, chain); err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
 		transactOpts.GasLimit = 5000000 // Todo Need an attention
 		contractMeta := ContractMetadata{
 			ABI:     string(rawDecodedText),
 			Bin:     record.ByteCode,
 			ChainId: chainId.String(),
 		}
-		params, err := utils.ConvertParamsAsPerTypes(record.Params)
+		contractABI, err := abi.JSON(strings.NewReader(contractMeta.ABI))
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		params, err := utils.ConvertParams(contractABI, "", record.Params)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
 		contractMeta.Params = params
+		nonce, err := s.nonces.Reserve(ctx, client, wallet, chainId)
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		transactOpts.Nonce = big.NewInt(int64(nonce))
 		address, txn, _, err := DeploySmartContract(transactOpts, client, contractMeta)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
+			s.releaseNonce(wallet, chainId, nonce)
 			continue
 		}
+		s.confirmNonce(wallet, chainId, nonce)
+		s.trackTransaction(wallet, chainId, txn, "deploy", record.ReferenceId)
+		s.registerContractOnSuccess(txn.Hash().String(), chain.ChainId, address, contractMeta.ABI, "", contractSourceDeploy)
 		err = utils.UpdatePlatformNonce(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String(), TxnHash: txn.Hash().String(),
 			ContractAddress: address.String(), Type: "deploy", ReferenceId: record.ReferenceId})
 		if err != nil {
@@ -132,31 +149,34 @@ func (s *Service) SubmitTransactions(ctx context.Context) {
 		if record.To != "" {
 			to = common.HexToAddress(record.To)
 		}
-		client, err := utils.GetEthereumClient(ctx, s.config)
+		value, err := record.Value.Wei()
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		chainId, err := client.ChainID(ctx)
+		gasPrice, err := record.GasPrice.Wei()
+		if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		client, err := utils.GetEthereumClient(ctx, s.config, record.ChainId.String())
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
-		nonce, err := utils.GetNonceFromPlatform(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String()})
+		chainId, err := client.ChainID(ctx)
 		if err != nil {
 			s.e.Logger.Errorf(err.Error())
 			continue
 		}
 		var txnHash string
 		if record.IsContractTxn {
-			var contractABI string
-			if record.ContractABI != "" {
-				contractABI = record.ContractABI
-			} else {
+			address, contractABI, err := s.resolveContract(record.ChainId.String(), record.To, "", record.ContractABI, 0)
+			if err != nil {
 				s.e.Logger.Errorf(err.Error())
 				continue
 			}
-			contract, err := NewSmartContract(to, contractABI, client)
+			contract, err := NewSmartContract(common.HexToAddress(address), contractABI, client)
 			if err != nil {
 				s.e.Logger.Errorf(err.Error())
 				continue
@@ -166,25 +186,44 @@ func (s *Service) SubmitTransactions(ctx context.Context) {
 				s.e.Logger.Errorf(err.Error())
 				continue
 			}
-			if record.Value > 0 {
-				txnOpts.Value = big.NewInt(record.Value)
+			if value.Sign() > 0 {
+				txnOpts.Value = value
 			}
-			txnOpts.Nonce = big.NewInt(int64(nonce))
-			params, err := utils.ConvertParamsAsPerTypes(record.Params)
+			if record.GasPrice.IsSet() {
+				txnOpts.GasPrice = gasPrice
+			}
+			parsed, err := abi.JSON(strings.NewReader(contractABI))
 			if err != nil {
 				s.e.Logger.Errorf(err.Error())
 				continue
 			}
+			params, err := utils.ConvertParams(parsed, record.Method, record.Params)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			nonce, err := s.nonces.Reserve(ctx, client, wallet, chainId)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			txnOpts.Nonce = big.NewInt(int64(nonce))
 			txn, err := contract.SmartContractTransactor.Contract.Transact(txnOpts, record.Method, params...)
 			if err != nil {
 				s.e.Logger.Errorf(err.Error())
+				s.releaseNonce(wallet, chainId, nonce)
 				continue
 			}
+			s.confirmNonce(wallet, chainId, nonce)
+			s.trackTransaction(wallet, chainId, txn, "txn", record.ReferenceId)
 			txnHash = txn.Hash().String()
 		} else {
-			gasPrice, err := client.SuggestGasPrice(ctx)
-			if err != nil {
-				s.e.Logger.Errorf(err.Error())
+			if !record.GasPrice.IsSet() {
+				gasPrice, err = client.SuggestGasPrice(ctx)
+				if err != nil {
+					s.e.Logger.Errorf(err.Error())
+					continue
+				}
 			}
 			var dataBytes []byte
 			if record.Data != "" {
@@ -198,31 +237,45 @@ func (s *Service) SubmitTransactions(ctx context.Context) {
 			if record.Gas > 0 {
 				estimatedGas = record.Gas
 			} else {
-				estimatedGas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: big.NewInt(record.Value), Data: dataBytes, GasPrice: gasPrice})
+				estimatedGas, err = client.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(wallet.Address), To: &to, Value: value, Data: dataBytes, GasPrice: gasPrice})
 				if err != nil {
 					s.e.Logger.Errorf(err.Error())
 					continue
 				}
 			}
+			nonce, err := s.nonces.Reserve(ctx, client, wallet, chainId)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
 			txn := types.NewTx(&types.LegacyTx{
 				Nonce:    nonce,
 				GasPrice: gasPrice,
 				Gas:      estimatedGas,
-				Value:    big.NewInt(record.Value),
+				Value:    value,
 				Data:     dataBytes,
 				To:       &to,
 			})
 			signer := types.LatestSignerForChainID(chainId)
-			signature, err := SignTransactionHash(ctx, wallet, s.vault, txn.Hash().Bytes())
+			signature, err := SignTransactionHash(ctx, wallet, s.vault, signer.Hash(txn).Bytes())
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				s.releaseNonce(wallet, chainId, nonce)
+				continue
+			}
 			txn, err = txn.WithSignature(signer, signature)
 			if err != nil {
 				s.e.Logger.Errorf(err.Error())
+				s.releaseNonce(wallet, chainId, nonce)
 				continue
 			}
 			if err := client.SendTransaction(ctx, txn); err != nil {
 				s.e.Logger.Errorf(err.Error())
+				s.releaseNonce(wallet, chainId, nonce)
 				continue
 			}
+			s.confirmNonce(wallet, chainId, nonce)
+			s.trackTransaction(wallet, chainId, txn, "txn", record.ReferenceId)
 			txnHash = txn.Hash().String()
 		}
 		err = utils.UpdatePlatformNonce(s.config, &utils.NonceRequest{WalletId: wallet.WalletId, ChainId: chainId.String(), TxnHash: txnHash,
@@ -0,0 +1,139 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// selector of Error(string)
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// selector of Panic(uint256)
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// panicReasons maps the Solidity panic codes to their meaning.
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized function",
+}
+
+// revertError is returned when a transaction would revert.
+type revertError struct {
+	failure *utils.SimulationFailure
+}
+
+func (e *revertError) Error() string {
+	return "transaction would revert : " + e.failure.Reason
+}
+
+// decodeRevert decodes revert data of Error(string), Panic(uint256) or, given
+// the ABI of the contract, one of its custom errors.
+func decodeRevert(data []byte, contractABI *abi.ABI) *utils.SimulationFailure {
+	failure := &utils.SimulationFailure{Kind: utils.RevertUnknown, Reason: "execution reverted"}
+	if len(data) > 0 {
+		failure.Data = hexutil.Encode(data)
+	}
+	if len(data) < 4 {
+		return failure
+	}
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, errorSelector):
+		if reason, err := abi.UnpackRevert(data); err == nil {
+			failure.Kind, failure.Reason = utils.RevertError, reason
+		}
+		return failure
+	case bytes.Equal(selector, panicSelector):
+		if len(data) != 36 {
+			return failure
+		}
+		code := new(big.Int).SetBytes(data[4:])
+		reason, ok := panicReasons[code.Uint64()]
+		if !ok || !code.IsUint64() {
+			reason = "unknown panic"
+		}
+		failure.Kind, failure.Code = utils.RevertPanic, hexutil.EncodeBig(code)
+		failure.Reason = fmt.Sprintf("panic %s : %s", failure.Code, reason)
+		return failure
+	}
+	if contractABI == nil {
+		return failure
+	}
+	for _, abiErr := range contractABI.Errors {
+		if !bytes.Equal(abiErr.ID[:4], selector) {
+			continue
+		}
+		args := map[string]interface{}{}
+		if err := abiErr.Inputs.UnpackIntoMap(args, data[4:]); err != nil {
+			return failure
+		}
+		failure.Kind, failure.Error, failure.Args = utils.RevertCustom, abiErr.Sig, args
+		failure.Reason = abiErr.Name
+		return failure
+	}
+	return failure
+}
+
+// callFailure turns the error of a node rejecting a call or a gas estimation
+// into a *revertError with the decoded reason. Transport errors are returned
+// unchanged.
+func callFailure(err error, contractABI *abi.ABI) error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	var data []byte
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			data, _ = hexutil.Decode(hexData)
+		}
+	}
+	failure := decodeRevert(data, contractABI)
+	if failure.Kind == utils.RevertUnknown && !strings.Contains(err.Error(), "revert") {
+		// rejected for another reason, e.g. insufficient funds
+		failure.Reason = err.Error()
+	}
+	return &revertError{failure: failure}
+}
+
+// simulateTransaction runs msg with eth_call at the pending block before it
+// is signed, so a transaction that would revert fails without using a nonce.
+// The ABI, if given, is used to decode custom errors.
+func simulateTransaction(ctx context.Context, client *ethclient.Client, msg ethereum.CallMsg, contractABI *abi.ABI) error {
+	if _, err := client.PendingCallContract(ctx, msg); err != nil {
+		return callFailure(err, contractABI)
+	}
+	return nil
+}
+
+// transactFailureResponse responds with the decoded failure of a transaction
+// that would revert, or with an unexpected failure otherwise.
+func transactFailureResponse(c echo.Context, err error) error {
+	var reverted *revertError
+	if errors.As(err, &reverted) {
+		return utils.BadRequestResponse(c, reverted.Error(), reverted.failure)
+	}
+	return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+}
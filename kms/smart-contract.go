@@ -12,12 +12,14 @@ import (
 	"strings"
 	"wallet-kms/vault"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 type ContractMetadata struct {
@@ -138,3 +140,32 @@ func SignTransactionHash(ctx context.Context, w *Wallet, vault vault.Vault, tran
 	}
 	return signature, nil
 }
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// callContractMethod calls a view method of a contract and returns its
+// unpacked outputs.
+func callContractMethod(ctx context.Context, client *ethclient.Client, contractABI abi.ABI, contract common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	return callContractMethodAt(ctx, client, contractABI, contract, nil, method, args...)
+}
+
+// callContractMethodAt calls a view method of a contract at the given block,
+// latest if nil.
+func callContractMethodAt(ctx context.Context, client *ethclient.Client, contractABI abi.ABI, contract common.Address, block *big.Int,
+	method string, args ...interface{}) ([]interface{}, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, block)
+	if err != nil {
+		return nil, err
+	}
+	return contractABI.Unpack(method, out)
+}
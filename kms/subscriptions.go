@@ -0,0 +1,532 @@
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wallet-kms/store"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// blocks per FilterLogs request
+	maxLogRange = 2000
+	// FilterLogs requests per subscription and poll
+	maxRangesPerPoll = 10
+)
+
+// Subscriptions persists contract event subscriptions and their progress.
+type Subscriptions struct {
+	db       store.DB
+	webhooks *WebhookPolicy
+	// wake triggers a poll before the next interval
+	wake chan struct{}
+
+	mu      sync.Mutex
+	changed map[string]chan struct{}
+	// polling holds the ids of the subscriptions being delivered
+	polling map[string]bool
+}
+
+func NewSubscriptions(db store.DB, webhooks *WebhookPolicy) *Subscriptions {
+	return &Subscriptions{db: db, webhooks: webhooks, wake: make(chan struct{}, 1), changed: make(map[string]chan struct{}), polling: make(map[string]bool)}
+}
+
+// Save stores a subscription, replacing any previous state.
+func (s *Subscriptions) Save(sub *utils.EventSubscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Set([]byte(utils.SUBSCRIPTION_NAMESPACE), []byte(sub.Id), data); err != nil {
+		return err
+	}
+	s.notify(sub.ChainId)
+	return nil
+}
+
+// Progress stores the delivery progress of a subscription unless it was
+// deleted in the meantime.
+func (s *Subscriptions) Progress(sub *utils.EventSubscription) error {
+	return s.db.Update([]byte(utils.SUBSCRIPTION_NAMESPACE), []byte(sub.Id), func(value []byte) ([]byte, error) {
+		if value == nil {
+			return nil, fmt.Errorf("subscription %s was deleted", sub.Id)
+		}
+		stored := &utils.EventSubscription{}
+		if err := json.Unmarshal(value, stored); err != nil {
+			return nil, err
+		}
+		stored.LastBlock, stored.LastBlockHash = sub.LastBlock, sub.LastBlockHash
+		stored.LastError, stored.Reorgs, stored.ReorgUntil = sub.LastError, sub.Reorgs, sub.ReorgUntil
+		stored.UpdatedAt = time.Now().Unix()
+		return json.Marshal(stored)
+	})
+}
+
+// Get returns the subscription with the given id.
+func (s *Subscriptions) Get(id string) (*utils.EventSubscription, error) {
+	data, err := s.db.Get([]byte(utils.SUBSCRIPTION_NAMESPACE), []byte(id))
+	if err != nil {
+		return nil, err
+	}
+	sub := &utils.EventSubscription{}
+	if err := json.Unmarshal(data, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *Subscriptions) Delete(sub *utils.EventSubscription) error {
+	if err := s.db.Delete([]byte(utils.SUBSCRIPTION_NAMESPACE), []byte(sub.Id)); err != nil {
+		return err
+	}
+	s.notify(sub.ChainId)
+	return nil
+}
+
+// List returns all subscriptions, oldest first.
+func (s *Subscriptions) List() ([]utils.EventSubscription, error) {
+	subs := []utils.EventSubscription{}
+	err := s.db.Iterate([]byte(utils.SUBSCRIPTION_NAMESPACE), nil, func(key, value []byte) error {
+		var sub utils.EventSubscription
+		if err := json.Unmarshal(value, &sub); err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt < subs[j].CreatedAt
+	})
+	return subs, nil
+}
+
+// Wake triggers a poll of all subscriptions.
+func (s *Subscriptions) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Changed returns a channel that is closed when a subscription of the chain
+// is created or deleted.
+func (s *Subscriptions) Changed(chainId string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.changed[chainId]
+	if !ok {
+		ch = make(chan struct{})
+		s.changed[chainId] = ch
+	}
+	return ch
+}
+
+// startPoll marks a subscription as being delivered. It returns false if a
+// previous poll of the subscription is still running.
+func (s *Subscriptions) startPoll(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.polling[id] {
+		return false
+	}
+	s.polling[id] = true
+	return true
+}
+
+func (s *Subscriptions) endPoll(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.polling, id)
+}
+
+func (s *Subscriptions) notify(chainId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch, ok := s.changed[chainId]; ok {
+		close(ch)
+		delete(s.changed, chainId)
+	}
+}
+
+// SubscriptionWatcher delivers the events of subscribed contracts every
+// SUBSCRIPTION_POLL_INTERVAL seconds, or as soon as a WebSocket endpoint
+// reports new logs.
+func (s *Service) SubscriptionWatcher() {
+	ctx := context.Background()
+	interval, err := strconv.Atoi(os.Getenv("SUBSCRIPTION_POLL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 15
+	}
+	for _, chainId := range s.config.Chains.ChainIds() {
+		if chain, err := s.config.Chains.Get(chainId); err == nil && chain.WsUrl != "" {
+			go s.streamContractLogs(chain)
+		}
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.subscriptions.wake:
+		}
+		s.PollSubscriptions(ctx)
+	}
+}
+
+// streamContractLogs subscribes to the logs of the subscribed contracts of a
+// chain over WebSocket and wakes the watcher whenever one arrives. Delivery
+// itself stays with the watcher, so progress and reorg handling are the same
+// as when polling.
+func (s *Service) streamContractLogs(chain *utils.ChainConfig) {
+	ctx := context.Background()
+	for {
+		changed := s.subscriptions.Changed(chain.ChainId)
+		if err := s.streamContractLogsOnce(ctx, chain, changed); err != nil {
+			s.e.Logger.Warnf("event stream of chain %s : %s", chain.ChainId, err.Error())
+			time.Sleep(10 * time.Second)
+		}
+	}
+}
+
+func (s *Service) streamContractLogsOnce(ctx context.Context, chain *utils.ChainConfig, changed <-chan struct{}) error {
+	subs, err := s.subscriptions.List()
+	if err != nil {
+		return err
+	}
+	var contracts []common.Address
+	for _, sub := range subs {
+		if sub.ChainId == chain.ChainId {
+			contracts = append(contracts, common.HexToAddress(sub.Contract))
+		}
+	}
+	if len(contracts) == 0 {
+		<-changed
+		return nil
+	}
+	client, err := ethclient.DialContext(ctx, chain.WsUrl)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	logs := make(chan types.Log, 64)
+	stream, err := client.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Addresses: contracts}, logs)
+	if err != nil {
+		return err
+	}
+	defer stream.Unsubscribe()
+	for {
+		select {
+		case <-logs:
+			s.subscriptions.Wake()
+		case err := <-stream.Err():
+			return err
+		case <-changed:
+			return nil
+		}
+	}
+}
+
+// PollSubscriptions delivers the events of every subscription up to the
+// latest block with enough confirmations. Every subscription is delivered in
+// its own goroutine, so a slow or dead webhook only delays its own
+// subscription, which is skipped until its previous poll has finished.
+func (s *Service) PollSubscriptions(ctx context.Context) {
+	subs, err := s.subscriptions.List()
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+		return
+	}
+	clients := make(map[string]*ethclient.Client)
+	heads := make(map[string]uint64)
+	for i := range subs {
+		sub := &subs[i]
+		client, ok := clients[sub.ChainId]
+		if !ok {
+			client, err = utils.GetEthereumClient(ctx, s.config, sub.ChainId)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			clients[sub.ChainId] = client
+		}
+		head, ok := heads[sub.ChainId]
+		if !ok {
+			head, err = client.BlockNumber(ctx)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			heads[sub.ChainId] = head
+		}
+		if !s.subscriptions.startPoll(sub.Id) {
+			continue
+		}
+		go func(client *ethclient.Client, sub *utils.EventSubscription, head uint64) {
+			defer s.subscriptions.endPoll(sub.Id)
+			if err := s.pollSubscription(ctx, client, sub, head); err != nil {
+				s.e.Logger.Warnf("subscription %s : %s", sub.Id, err.Error())
+				sub.LastError = err.Error()
+				if err := s.subscriptions.Progress(sub); err != nil {
+					s.e.Logger.Errorf(err.Error())
+				}
+			}
+		}(client, sub, head)
+	}
+}
+
+// pollSubscription delivers the events of a subscription in ranges of blocks
+// and stores its progress after every delivered range. A block whose hash
+// changed since it was delivered has been reorged, the subscription then
+// rewinds and delivers the replaced blocks again.
+func (s *Service) pollSubscription(ctx context.Context, client *ethclient.Client, sub *utils.EventSubscription, head uint64) error {
+	contractABI, err := abi.JSON(strings.NewReader(sub.ContractABI))
+	if err != nil {
+		return err
+	}
+	var eventIds []common.Hash
+	for _, name := range sub.Events {
+		eventIds = append(eventIds, contractABI.Events[name].ID)
+	}
+	query := ethereum.FilterQuery{Addresses: []common.Address{common.HexToAddress(sub.Contract)}}
+	if len(eventIds) > 0 {
+		query.Topics = [][]common.Hash{eventIds}
+	}
+	if sub.LastBlockHash != "" {
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(sub.LastBlock))
+		if err != nil {
+			return err
+		}
+		if header.Hash().String() != sub.LastBlockHash {
+			depth := sub.Confirmations
+			if depth < defaultConfirmations {
+				depth = defaultConfirmations
+			}
+			if depth > sub.LastBlock {
+				depth = sub.LastBlock
+			}
+			s.e.Logger.Warnf("subscription %s : block %d was reorged, delivering again from block %d", sub.Id, sub.LastBlock, sub.LastBlock-depth+1)
+			sub.Reorgs++
+			if sub.LastBlock > sub.ReorgUntil {
+				sub.ReorgUntil = sub.LastBlock
+			}
+			sub.LastBlock, sub.LastBlockHash = sub.LastBlock-depth, ""
+			if err := s.subscriptions.Progress(sub); err != nil {
+				return err
+			}
+		}
+	}
+	confirmations := sub.Confirmations
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	if head+1 < confirmations {
+		return nil
+	}
+	target := head + 1 - confirmations
+	for i := 0; i < maxRangesPerPoll && sub.LastBlock < target; i++ {
+		from, to := sub.LastBlock+1, sub.LastBlock+maxLogRange
+		if to > target {
+			to = target
+		}
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+		if err != nil {
+			return err
+		}
+		query.FromBlock, query.ToBlock = new(big.Int).SetUint64(from), new(big.Int).SetUint64(to)
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return err
+		}
+		// logs of a block reorged while they were fetched are fetched again
+		// on the next poll
+		if after, err := client.HeaderByNumber(ctx, query.ToBlock); err != nil || after.Hash() != header.Hash() {
+			return err
+		}
+		events := make([]utils.DecodedEvent, 0, len(logs))
+		for j := range logs {
+			if logs[j].Removed {
+				continue
+			}
+			event := decodeLog(&logs[j], []abi.ABI{contractABI})
+			event.TxnHash, event.BlockNumber, event.BlockHash = logs[j].TxHash.String(), logs[j].BlockNumber, logs[j].BlockHash.String()
+			events = append(events, event)
+		}
+		if len(events) > 0 {
+			payload := &utils.WebhookPayload{
+				SubscriptionId: sub.Id,
+				ChainId:        sub.ChainId,
+				Contract:       sub.Contract,
+				FromBlock:      from,
+				ToBlock:        to,
+				Reorg:          from <= sub.ReorgUntil,
+				Events:         events,
+			}
+			if err := s.subscriptions.webhooks.Deliver(ctx, sub.WebhookUrl, sub.Secret, payload); err != nil {
+				return fmt.Errorf("error delivering blocks %d to %d : %s", from, to, err.Error())
+			}
+		}
+		sub.LastBlock, sub.LastBlockHash, sub.LastError = to, header.Hash().String(), ""
+		if sub.LastBlock >= sub.ReorgUntil {
+			sub.ReorgUntil = 0
+		}
+		if err := s.subscriptions.Progress(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSubscription godoc
+// @Summary Create event subscription
+// @Description subscribes a webhook to events of a contract. Events are decoded with the ABI and POSTed with an HMAC-SHA256 signature once their block has enough confirmations.
+// @Param	request  body	utils.CreateSubscriptionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /createSubscription [post]
+func (s *Service) createSubscription(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.CreateSubscriptionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	if u.Contract, u.ContractABI, err = s.resolveContract(chain.ChainId, u.Contract, u.ContractName, u.ContractABI, 0); err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	if webhook, err := url.Parse(u.WebhookUrl); err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return utils.BadRequestResponse(c, "invalid webhook url", nil)
+	}
+	contractABI, err := abi.JSON(strings.NewReader(u.ContractABI))
+	if err != nil {
+		return utils.BadRequestResponse(c, "error reading ABI : "+err.Error(), nil)
+	}
+	for _, name := range u.Events {
+		if _, ok := contractABI.Events[name]; !ok {
+			return utils.BadRequestResponse(c, "unknown event "+name, nil)
+		}
+	}
+	fromBlock, err := parseBlockNumber(u.FromBlock)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	sub := &utils.EventSubscription{
+		Id:            uuid.New().String(),
+		ChainId:       chain.ChainId,
+		Contract:      common.HexToAddress(u.Contract).String(),
+		ContractABI:   u.ContractABI,
+		Events:        u.Events,
+		WebhookUrl:    u.WebhookUrl,
+		Secret:        u.Secret,
+		Confirmations: s.confirmationsFor(chain.ChainId),
+		CreatedAt:     time.Now().Unix(),
+	}
+	if u.Confirmations != nil {
+		sub.Confirmations = *u.Confirmations
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	if fromBlock != nil {
+		if fromBlock.Sign() > 0 {
+			sub.LastBlock = fromBlock.Uint64() - 1
+		}
+	} else {
+		client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+		if err != nil {
+			return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+		}
+		if sub.LastBlock, err = client.BlockNumber(ctx); err != nil {
+			return utils.UnexpectedFailureResponse(c, "error getting latest block : "+err.Error(), nil)
+		}
+	}
+	sub.UpdatedAt = sub.CreatedAt
+	if err := s.subscriptions.Save(sub); err != nil {
+		return utils.UnexpectedFailureResponse(c, "error storing subscription : "+err.Error(), nil)
+	}
+	s.subscriptions.Wake()
+	return utils.SendSuccessResponse(c, "Subscription created successfully", sub)
+}
+
+// listSubscriptions godoc
+// @Summary List event subscriptions
+// @Description lists event subscriptions and their progress, optionally of a chain or contract. Secrets are left out.
+// @Param	request  body	utils.ListSubscriptionsRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /listSubscriptions [post]
+func (s *Service) listSubscriptions(c echo.Context) error {
+	u := new(utils.ListSubscriptionsRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	subs, err := s.subscriptions.List()
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error listing subscriptions : "+err.Error(), nil)
+	}
+	res := []utils.EventSubscription{}
+	for _, sub := range subs {
+		if u.ChainId != "" && sub.ChainId != u.ChainId.String() {
+			continue
+		}
+		if u.Contract != "" && !strings.EqualFold(sub.Contract, u.Contract) {
+			continue
+		}
+		sub.Secret = ""
+		res = append(res, sub)
+	}
+	return utils.SendSuccessResponse(c, "", res)
+}
+
+// deleteSubscription godoc
+// @Summary Delete event subscription
+// @Description stops delivering the events of a subscription.
+// @Param	request  body	utils.DeleteSubscriptionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /deleteSubscription [post]
+func (s *Service) deleteSubscription(c echo.Context) error {
+	u := new(utils.DeleteSubscriptionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	sub, err := s.subscriptions.Get(u.Id)
+	if err != nil {
+		return utils.BadRequestResponse(c, "subscription not found", nil)
+	}
+	if err := s.subscriptions.Delete(sub); err != nil {
+		return utils.UnexpectedFailureResponse(c, "error deleting subscription : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "Subscription deleted successfully", nil)
+}
@@ -0,0 +1,444 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-kms/store"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultConfirmations = 12
+
+// errTxnUnchanged is returned by Tracker.Update callbacks that leave the
+// record as it is.
+var errTxnUnchanged = errors.New("txn unchanged")
+
+// Tracker persists every transaction broadcast by the KMS and follows it until
+// it has enough confirmations.
+type Tracker struct {
+	db            store.DB
+	confirmations uint64
+}
+
+func NewTracker(db store.DB, confirmations uint64) *Tracker {
+	if confirmations == 0 {
+		confirmations = defaultConfirmations
+	}
+	return &Tracker{db: db, confirmations: confirmations}
+}
+
+// Track stores a freshly broadcast transaction as pending.
+func (t *Tracker) Track(w *Wallet, chainId *big.Int, txn *types.Transaction, txnType, referenceId string) (*utils.TransactionRecord, error) {
+	raw, err := txn.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	from := common.HexToAddress(w.Address)
+	record := &utils.TransactionRecord{
+		TxnHash:     txn.Hash().String(),
+		WalletId:    w.WalletId,
+		ChainId:     chainId.String(),
+		From:        from.String(),
+		Nonce:       txn.Nonce(),
+		Type:        txnType,
+		ReferenceId: referenceId,
+		Status:      utils.TxnStatusPending,
+		RawTxn:      hexutil.Encode(raw),
+		SubmittedAt: now,
+		UpdatedAt:   now,
+	}
+	if txn.To() != nil {
+		record.To = txn.To().String()
+	} else {
+		record.ContractAddress = crypto.CreateAddress(from, txn.Nonce()).String()
+	}
+	if err := t.Save(record); err != nil {
+		return nil, err
+	}
+	if err := t.db.Set([]byte(utils.WALLET_TXN_NAMESPACE), walletTxnKey(w.WalletId, record.TxnHash), []byte(record.TxnHash)); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Save stores the record, replacing any previous state of the transaction.
+func (t *Tracker) Save(record *utils.TransactionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// an open txn is indexed before it is stored, so Open never misses it,
+	// a final one is removed from the index once it is stored
+	if isOpen(record.Status) {
+		if err := t.index(record); err != nil {
+			return err
+		}
+	}
+	if err := t.db.Set([]byte(utils.TXN_NAMESPACE), txnKey(record.TxnHash), data); err != nil {
+		return err
+	}
+	if !isOpen(record.Status) {
+		return t.index(record)
+	}
+	return nil
+}
+
+// Update applies fn to the stored state of a tracked transaction and saves the
+// result atomically, so concurrent updates of the same transaction are not
+// lost. Nothing is saved if fn returns an error, which is passed on. fn may be
+// called more than once on conflicts.
+func (t *Tracker) Update(txnHash string, fn func(record *utils.TransactionRecord) error) (*utils.TransactionRecord, error) {
+	var record *utils.TransactionRecord
+	err := t.db.Update([]byte(utils.TXN_NAMESPACE), txnKey(txnHash), func(value []byte) ([]byte, error) {
+		if value == nil {
+			return nil, fmt.Errorf("txn %s is not tracked", txnHash)
+		}
+		record = &utils.TransactionRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			return nil, err
+		}
+		if err := fn(record); err != nil {
+			return nil, err
+		}
+		return json.Marshal(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := t.index(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Has reports whether a transaction is tracked.
+func (t *Tracker) Has(txnHash string) (bool, error) {
+	return t.db.Has([]byte(utils.TXN_NAMESPACE), txnKey(txnHash))
+}
+
+// Get returns the tracked transaction with the given hash.
+func (t *Tracker) Get(txnHash string) (*utils.TransactionRecord, error) {
+	data, err := t.db.Get([]byte(utils.TXN_NAMESPACE), txnKey(txnHash))
+	if err != nil {
+		return nil, err
+	}
+	record := &utils.TransactionRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListByWallet returns the tracked transactions of a wallet, newest first,
+// optionally filtered by status.
+func (t *Tracker) ListByWallet(walletId, status string) ([]utils.TransactionRecord, error) {
+	records := []utils.TransactionRecord{}
+	err := t.db.Iterate([]byte(utils.WALLET_TXN_NAMESPACE), []byte(walletId+"/"), func(key, value []byte) error {
+		record, err := t.Get(string(value))
+		if err != nil {
+			return err
+		}
+		if status == "" || record.Status == status {
+			records = append(records, *record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].SubmittedAt > records[j].SubmittedAt
+	})
+	return records, nil
+}
+
+// Open returns the tracked transactions that are not final yet. Replaced
+// transactions stay open since they may still be mined instead of their
+// replacement.
+func (t *Tracker) Open() ([]utils.TransactionRecord, error) {
+	var records []utils.TransactionRecord
+	var stale []*utils.TransactionRecord
+	err := t.db.Iterate([]byte(utils.OPEN_TXN_NAMESPACE), nil, func(key, value []byte) error {
+		record, err := t.Get(string(value))
+		if err != nil {
+			return err
+		}
+		if isOpen(record.Status) {
+			records = append(records, *record)
+		} else {
+			stale = append(stale, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// entries of txns finalized by a concurrent update
+	for _, record := range stale {
+		if err := t.index(record); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// Reindex rebuilds the index of open transactions from the stored records,
+// e.g. for records stored before the index existed.
+func (t *Tracker) Reindex() error {
+	var records []utils.TransactionRecord
+	err := t.db.Iterate([]byte(utils.TXN_NAMESPACE), nil, func(key, value []byte) error {
+		var record utils.TransactionRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if isOpen(record.Status) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if err := t.index(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index adds the record to the index of open transactions or removes it once
+// it is final.
+func (t *Tracker) index(record *utils.TransactionRecord) error {
+	if isOpen(record.Status) {
+		return t.db.Set([]byte(utils.OPEN_TXN_NAMESPACE), txnKey(record.TxnHash), []byte(record.TxnHash))
+	}
+	return t.db.Delete([]byte(utils.OPEN_TXN_NAMESPACE), txnKey(record.TxnHash))
+}
+
+// isOpen reports whether a txn with the given status may still change.
+func isOpen(status string) bool {
+	switch status {
+	case utils.TxnStatusPending, utils.TxnStatusMined, utils.TxnStatusReplaced:
+		return true
+	}
+	return false
+}
+
+func txnKey(txnHash string) []byte {
+	return []byte(strings.ToLower(txnHash))
+}
+
+func walletTxnKey(walletId, txnHash string) []byte {
+	return []byte(walletId + "/" + strings.ToLower(txnHash))
+}
+
+// TransactionTracker polls receipts of open transactions every
+// TRACKER_POLL_INTERVAL seconds.
+func (s *Service) TransactionTracker() {
+	ctx := context.Background()
+	interval, err := strconv.Atoi(os.Getenv("TRACKER_POLL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = 15
+	}
+	for {
+		time.Sleep(time.Duration(interval) * time.Second)
+		s.PollTransactions(ctx)
+	}
+}
+
+// PollTransactions refreshes the receipt, confirmations and status of every
+// open transaction. A mined transaction whose receipt disappears or moves to
+// another block has been reorged and is followed again from there.
+func (s *Service) PollTransactions(ctx context.Context) {
+	records, err := s.tracker.Open()
+	if err != nil {
+		s.e.Logger.Errorf(err.Error())
+		return
+	}
+	clients := make(map[string]*ethclient.Client)
+	heads := make(map[string]uint64)
+	minedNonces := make(map[string]uint64)
+	for i := range records {
+		record := &records[i]
+		client, ok := clients[record.ChainId]
+		if !ok {
+			client, err = utils.GetEthereumClient(ctx, s.config, record.ChainId)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			clients[record.ChainId] = client
+		}
+		head, ok := heads[record.ChainId]
+		if !ok {
+			head, err = client.BlockNumber(ctx)
+			if err != nil {
+				s.e.Logger.Errorf(err.Error())
+				continue
+			}
+			heads[record.ChainId] = head
+		}
+		receipt, err := client.TransactionReceipt(ctx, common.HexToHash(record.TxnHash))
+		if err == ethereum.NotFound {
+			receipt = nil
+			if record.Status != utils.TxnStatusMined {
+				// a transaction without receipt whose nonce is used on chain
+				// was replaced or dropped from the mempool
+				key := record.ChainId + "/" + record.From
+				if _, ok := minedNonces[key]; !ok {
+					mined, err := client.NonceAt(ctx, common.HexToAddress(record.From), nil)
+					if err != nil {
+						s.e.Logger.Errorf(err.Error())
+						continue
+					}
+					minedNonces[key] = mined
+				}
+			}
+		} else if err != nil {
+			s.e.Logger.Errorf(err.Error())
+			continue
+		}
+		// apply the result to the stored record rather than the copy read
+		// above, which may have been replaced in the meantime
+		updated, err := s.tracker.Update(record.TxnHash, func(record *utils.TransactionRecord) error {
+			return s.applyReceipt(record, receipt, head, minedNonces)
+		})
+		if err != nil {
+			if err != errTxnUnchanged {
+				s.e.Logger.Errorf(err.Error())
+			}
+			continue
+		}
+		s.settleContracts(updated, receipt)
+	}
+}
+
+// applyReceipt updates the status of a tracked transaction from its receipt,
+// nil if there is none, and the chain head. Without a receipt, the nonces
+// mined by the senders tell whether a pending transaction was dropped.
+func (s *Service) applyReceipt(record *utils.TransactionRecord, receipt *types.Receipt, head uint64, minedNonces map[string]uint64) error {
+	switch record.Status {
+	case utils.TxnStatusPending, utils.TxnStatusMined, utils.TxnStatusReplaced:
+	default:
+		return errTxnUnchanged
+	}
+	if receipt == nil {
+		if record.Status == utils.TxnStatusMined {
+			s.e.Logger.Warnf("txn %s removed from block %d by a reorg", record.TxnHash, record.BlockNumber)
+			record.Status = utils.TxnStatusPending
+			record.Reorgs++
+			record.BlockNumber, record.BlockHash, record.Confirmations = 0, "", 0
+			record.GasUsed, record.EffectiveGasPrice = 0, ""
+		} else {
+			mined, ok := minedNonces[record.ChainId+"/"+record.From]
+			if !ok || record.Nonce >= mined {
+				return errTxnUnchanged
+			}
+			record.Status = utils.TxnStatusDropped
+		}
+	} else {
+		if record.Status == utils.TxnStatusMined && record.BlockHash != receipt.BlockHash.String() {
+			s.e.Logger.Warnf("txn %s moved from block %d to %d by a reorg", record.TxnHash, record.BlockNumber, receipt.BlockNumber.Uint64())
+			record.Reorgs++
+		}
+		record.BlockNumber = receipt.BlockNumber.Uint64()
+		record.BlockHash = receipt.BlockHash.String()
+		record.GasUsed = receipt.GasUsed
+		if receipt.EffectiveGasPrice != nil {
+			record.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+		}
+		record.Confirmations = 0
+		if head >= record.BlockNumber {
+			record.Confirmations = head - record.BlockNumber + 1
+		}
+		record.Status = utils.TxnStatusMined
+		if record.Confirmations >= s.confirmationsFor(record.ChainId) {
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				record.Status = utils.TxnStatusConfirmed
+			} else {
+				record.Status = utils.TxnStatusFailed
+			}
+		}
+	}
+	record.UpdatedAt = time.Now().Unix()
+	return nil
+}
+
+// trackTransaction starts tracking a broadcast transaction. Failures are only
+// logged since the transaction is already on its way.
+func (s *Service) trackTransaction(w *Wallet, chainId *big.Int, txn *types.Transaction, txnType, referenceId string) {
+	if _, err := s.tracker.Track(w, chainId, txn, txnType, referenceId); err != nil {
+		s.e.Logger.Errorf("error tracking txn %s : %s", txn.Hash().String(), err.Error())
+	}
+}
+
+// getTransaction godoc
+// @Summary Get transaction
+// @Description retrieves the status, receipt details and confirmations of a transaction sent by the KMS.
+// @Param	request  body	utils.GetTransactionRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getTransaction [post]
+func (s *Service) getTransaction(c echo.Context) error {
+	u := new(utils.GetTransactionRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.TxnHash == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	tracked, err := s.tracker.Has(u.TxnHash)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting txn : "+err.Error(), nil)
+	}
+	if !tracked {
+		return utils.BadRequestResponse(c, "transaction not found", nil)
+	}
+	record, err := s.tracker.Get(u.TxnHash)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error getting txn : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", record)
+}
+
+// listTransactions godoc
+// @Summary List transactions
+// @Description lists the transactions sent by a wallet, newest first.
+// @Param	request  body	utils.ListTransactionsRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /listTransactions [post]
+func (s *Service) listTransactions(c echo.Context) error {
+	u := new(utils.ListTransactionsRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if u.WalletId == "" {
+		return utils.BadRequestResponse(c, "mandatory params missing", nil)
+	}
+	records, err := s.tracker.ListByWallet(u.WalletId, u.Status)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, "error listing txns : "+err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", records)
+}
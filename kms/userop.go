@@ -0,0 +1,600 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/labstack/echo/v4"
+)
+
+// canonical ERC-4337 EntryPoints and the SimpleAccountFactory deployed for each
+const (
+	entryPointV06           = "0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"
+	entryPointV07           = "0x0000000071727De22E5E9d8BAf0edAc6f37da032"
+	simpleAccountFactoryV06 = "0x9406Cc6185a346906296840746125a0E44976454"
+	simpleAccountFactoryV07 = "0x91E60e0613810449d098b0b5Ec8b51A0FE8c8985"
+)
+
+// dummySignature is a well formed signature sent while estimating gas, so
+// the account's ecrecover runs as it will with the real signature.
+const dummySignature = "0xfffffffffffffffffffffffffffffff0000000000000000000000000000000007aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa1c"
+
+const entryPointABIJSON = `[
+{"type":"function","name":"getNonce","stateMutability":"view","inputs":[{"name":"sender","type":"address"},{"name":"key","type":"uint192"}],"outputs":[{"name":"nonce","type":"uint256"}]},
+{"type":"function","name":"getSenderAddress","stateMutability":"nonpayable","inputs":[{"name":"initCode","type":"bytes"}],"outputs":[]},
+{"type":"error","name":"SenderAddressResult","inputs":[{"name":"sender","type":"address"}]},
+{"type":"error","name":"FailedOp","inputs":[{"name":"opIndex","type":"uint256"},{"name":"reason","type":"string"}]}
+]`
+
+const simpleAccountFactoryABIJSON = `[
+{"type":"function","name":"createAccount","stateMutability":"nonpayable","inputs":[{"name":"owner","type":"address"},{"name":"salt","type":"uint256"}],"outputs":[{"name":"ret","type":"address"}]}
+]`
+
+var (
+	entryPointABI           = mustParseABI(entryPointABIJSON)
+	simpleAccountFactoryABI = mustParseABI(simpleAccountFactoryABIJSON)
+)
+
+var (
+	// packed fields of a user operation hashed by EntryPoint v0.6 and v0.7
+	userOpV06Arguments  = abiArguments("address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes32")
+	userOpV07Arguments  = abiArguments("address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "bytes32", "bytes32")
+	userOpHashArguments = abiArguments("bytes32", "address", "uint256")
+)
+
+func abiArguments(types ...string) abi.Arguments {
+	arguments := make(abi.Arguments, len(types))
+	for i, typ := range types {
+		t, err := abi.NewType(typ, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		arguments[i] = abi.Argument{Type: t}
+	}
+	return arguments
+}
+
+// smartAccount is a counterfactual account of a wallet behind an EntryPoint.
+type smartAccount struct {
+	version     string
+	entryPoint  common.Address
+	factory     common.Address
+	factoryData []byte
+	owner       common.Address
+	address     common.Address
+	deployed    bool
+}
+
+// newSmartAccount reads the EntryPoint and factory of the account of w.
+func newSmartAccount(w *Wallet, u *utils.SmartAccountRequest) (*smartAccount, error) {
+	account := &smartAccount{version: u.EntryPointVersion, owner: common.HexToAddress(w.Address)}
+	var entryPoint, factory string
+	switch u.EntryPointVersion {
+	case "", utils.EntryPointV07:
+		account.version, entryPoint, factory = utils.EntryPointV07, entryPointV07, simpleAccountFactoryV07
+	case utils.EntryPointV06:
+		entryPoint, factory = entryPointV06, simpleAccountFactoryV06
+	default:
+		return nil, fmt.Errorf("unsupported entry point version %s", u.EntryPointVersion)
+	}
+	if u.EntryPoint != "" {
+		entryPoint = u.EntryPoint
+	}
+	if u.Factory != "" {
+		factory = u.Factory
+	}
+	if !common.IsHexAddress(entryPoint) || !common.IsHexAddress(factory) {
+		return nil, fmt.Errorf("invalid entryPoint or factory address")
+	}
+	account.entryPoint, account.factory = common.HexToAddress(entryPoint), common.HexToAddress(factory)
+	if u.FactoryData != "" {
+		data, err := hexutil.Decode(u.FactoryData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid factoryData : %s", err.Error())
+		}
+		account.factoryData = data
+		return account, nil
+	}
+	data, err := simpleAccountFactoryABI.Pack("createAccount", account.owner, new(big.Int).SetUint64(u.Salt))
+	if err != nil {
+		return nil, err
+	}
+	account.factoryData = data
+	return account, nil
+}
+
+// locate finds the address of the account with the EntryPoint's
+// getSenderAddress, which always reverts with SenderAddressResult.
+func (a *smartAccount) locate(ctx context.Context, client *ethclient.Client) error {
+	data, err := entryPointABI.Pack("getSenderAddress", append(a.factory.Bytes(), a.factoryData...))
+	if err != nil {
+		return err
+	}
+	_, err = client.CallContract(ctx, ethereum.CallMsg{To: &a.entryPoint, Data: data}, nil)
+	if err == nil {
+		return fmt.Errorf("getSenderAddress did not revert, %s may not be an entry point", a.entryPoint.String())
+	}
+	err = callFailure(err, &entryPointABI)
+	var reverted *revertError
+	if !errors.As(err, &reverted) {
+		return err
+	}
+	sender, ok := reverted.failure.Args["sender"].(common.Address)
+	if !ok {
+		return err
+	}
+	if sender == (common.Address{}) {
+		return fmt.Errorf("factory %s did not create an account, check factory and factoryData", a.factory.String())
+	}
+	code, err := client.CodeAt(ctx, sender, nil)
+	if err != nil {
+		return err
+	}
+	a.address, a.deployed = sender, len(code) > 0
+	return nil
+}
+
+// nonce returns the next nonce of the account for a nonce key.
+func (a *smartAccount) nonce(ctx context.Context, client *ethclient.Client, key uint64) (*big.Int, error) {
+	out, err := callContractMethod(ctx, client, entryPointABI, a.entryPoint, "getNonce", a.address, new(big.Int).SetUint64(key))
+	if err != nil {
+		return nil, fmt.Errorf("error reading account nonce : %s", err.Error())
+	}
+	return out[0].(*big.Int), nil
+}
+
+// userOperation is a user operation of either EntryPoint version. The v0.6
+// initCode and paymasterAndData are built from the factory and paymaster.
+type userOperation struct {
+	version                       string
+	sender                        common.Address
+	nonce                         *big.Int
+	factory                       *common.Address
+	factoryData                   []byte
+	callData                      []byte
+	callGasLimit                  *big.Int
+	verificationGasLimit          *big.Int
+	preVerificationGas            *big.Int
+	maxFeePerGas                  *big.Int
+	maxPriorityFeePerGas          *big.Int
+	paymaster                     *common.Address
+	paymasterVerificationGasLimit *big.Int
+	paymasterPostOpGasLimit       *big.Int
+	paymasterData                 []byte
+	signature                     []byte
+}
+
+func (op *userOperation) initCode() []byte {
+	if op.factory == nil {
+		return nil
+	}
+	return append(op.factory.Bytes(), op.factoryData...)
+}
+
+func (op *userOperation) paymasterAndData() []byte {
+	if op.paymaster == nil {
+		return nil
+	}
+	data := op.paymaster.Bytes()
+	if op.version == utils.EntryPointV07 {
+		data = append(data, common.LeftPadBytes(op.paymasterVerificationGasLimit.Bytes(), 16)...)
+		data = append(data, common.LeftPadBytes(op.paymasterPostOpGasLimit.Bytes(), 16)...)
+	}
+	return append(data, op.paymasterData...)
+}
+
+// packUint128s packs two uint128 values into one word, high first.
+func packUint128s(high, low *big.Int) [32]byte {
+	return common.BigToHash(new(big.Int).Or(new(big.Int).Lsh(high, 128), low))
+}
+
+// hash returns the userOpHash the EntryPoint computes and the account checks
+// the signature against.
+func (op *userOperation) hash(entryPoint common.Address, chainId *big.Int) (common.Hash, error) {
+	initCode := [32]byte(crypto.Keccak256Hash(op.initCode()))
+	callData := [32]byte(crypto.Keccak256Hash(op.callData))
+	paymasterAndData := [32]byte(crypto.Keccak256Hash(op.paymasterAndData()))
+	var packed []byte
+	var err error
+	if op.version == utils.EntryPointV06 {
+		packed, err = userOpV06Arguments.Pack(op.sender, op.nonce, initCode, callData, op.callGasLimit, op.verificationGasLimit,
+			op.preVerificationGas, op.maxFeePerGas, op.maxPriorityFeePerGas, paymasterAndData)
+	} else {
+		packed, err = userOpV07Arguments.Pack(op.sender, op.nonce, initCode, callData, packUint128s(op.verificationGasLimit, op.callGasLimit),
+			op.preVerificationGas, packUint128s(op.maxPriorityFeePerGas, op.maxFeePerGas), paymasterAndData)
+	}
+	if err != nil {
+		return common.Hash{}, err
+	}
+	encoded, err := userOpHashArguments.Pack([32]byte(crypto.Keccak256Hash(packed)), entryPoint, chainId)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// rpc returns the operation in the JSON form bundlers take for its version.
+func (op *userOperation) rpc() *utils.UserOperation {
+	res := &utils.UserOperation{
+		Sender:               op.sender.String(),
+		Nonce:                hexutil.EncodeBig(op.nonce),
+		CallData:             hexutil.Encode(op.callData),
+		CallGasLimit:         hexutil.EncodeBig(op.callGasLimit),
+		VerificationGasLimit: hexutil.EncodeBig(op.verificationGasLimit),
+		PreVerificationGas:   hexutil.EncodeBig(op.preVerificationGas),
+		MaxFeePerGas:         hexutil.EncodeBig(op.maxFeePerGas),
+		MaxPriorityFeePerGas: hexutil.EncodeBig(op.maxPriorityFeePerGas),
+		Signature:            hexutil.Encode(op.signature),
+	}
+	if op.version == utils.EntryPointV06 {
+		res.InitCode = hexutil.Encode(op.initCode())
+		res.PaymasterAndData = hexutil.Encode(op.paymasterAndData())
+		return res
+	}
+	if op.factory != nil {
+		res.Factory, res.FactoryData = op.factory.String(), hexutil.Encode(op.factoryData)
+	}
+	if op.paymaster != nil {
+		res.Paymaster, res.PaymasterData = op.paymaster.String(), hexutil.Encode(op.paymasterData)
+		res.PaymasterVerificationGasLimit = hexutil.EncodeBig(op.paymasterVerificationGasLimit)
+		res.PaymasterPostOpGasLimit = hexutil.EncodeBig(op.paymasterPostOpGasLimit)
+	}
+	return res
+}
+
+// accountCallData packs the calls of a user operation into execute, or
+// executeBatch for more than one call.
+func (s *Service) accountCallData(chainId, version string, calls []utils.ContractCall) ([]byte, error) {
+	dests := make([]common.Address, len(calls))
+	values := make([]*big.Int, len(calls))
+	datas := make([][]byte, len(calls))
+	hasValue := false
+	for i, call := range calls {
+		to, data, _, err := s.packContractCall(chainId, call)
+		if err != nil {
+			return nil, fmt.Errorf("call %d : %s", i, err.Error())
+		}
+		value, err := call.Value.Wei()
+		if err != nil {
+			return nil, fmt.Errorf("call %d : %s", i, err.Error())
+		}
+		dests[i], values[i], datas[i] = to, value, data
+		hasValue = hasValue || value.Sign() > 0
+	}
+	if len(calls) == 1 {
+		return simpleAccountABI.Pack("execute", dests[0], values[0], datas[0])
+	}
+	if version == utils.EntryPointV06 {
+		if hasValue {
+			return nil, fmt.Errorf("v0.6 executeBatch does not support values")
+		}
+		return simpleAccountV06ABI.Pack("executeBatch", dests, datas)
+	}
+	return simpleAccountABI.Pack("executeBatch", dests, values, datas)
+}
+
+// setUserOperationFees prices op at twice the base fee plus the suggested
+// tip, or the gas price on chains without EIP-1559, unless fees are given.
+func setUserOperationFees(ctx context.Context, op *userOperation, client *ethclient.Client, chain *utils.ChainConfig, maxFee, maxPriorityFee utils.Amount) error {
+	var err error
+	if op.maxFeePerGas, err = maxFee.Wei(); err != nil {
+		return fmt.Errorf("invalid maxFeePerGas : %s", err.Error())
+	}
+	if op.maxPriorityFeePerGas, err = maxPriorityFee.Wei(); err != nil {
+		return fmt.Errorf("invalid maxPriorityFeePerGas : %s", err.Error())
+	}
+	if maxFee.IsSet() && maxPriorityFee.IsSet() {
+		return nil
+	}
+	if !chain.EIP1559 {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return fmt.Errorf("error calculating gas price : %s", err.Error())
+		}
+		if !maxFee.IsSet() {
+			op.maxFeePerGas = gasPrice
+		}
+		if !maxPriorityFee.IsSet() {
+			op.maxPriorityFeePerGas = op.maxFeePerGas
+		}
+		return nil
+	}
+	if !maxPriorityFee.IsSet() {
+		if op.maxPriorityFeePerGas, err = client.SuggestGasTipCap(ctx); err != nil {
+			return fmt.Errorf("error calculating gas tip cap : %s", err.Error())
+		}
+	}
+	if !maxFee.IsSet() {
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error reading latest block : %s", err.Error())
+		}
+		baseFee := header.BaseFee
+		if baseFee == nil {
+			baseFee = new(big.Int)
+		}
+		op.maxFeePerGas = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), op.maxPriorityFeePerGas)
+	}
+	return nil
+}
+
+// dialBundler connects to the bundler of a chain.
+func dialBundler(ctx context.Context, chain *utils.ChainConfig) (*rpc.Client, error) {
+	if chain.BundlerUrl == "" {
+		return nil, fmt.Errorf("no bundler configured for chain %s", chain.ChainId)
+	}
+	return rpc.DialContext(ctx, chain.BundlerUrl)
+}
+
+// estimateUserOperationGas fills the gas limits of op that are not set with
+// the estimates of the bundler.
+func estimateUserOperationGas(ctx context.Context, bundler *rpc.Client, op *userOperation, entryPoint common.Address) error {
+	var estimate struct {
+		PreVerificationGas            *hexutil.Big `json:"preVerificationGas"`
+		VerificationGasLimit          *hexutil.Big `json:"verificationGasLimit"`
+		CallGasLimit                  *hexutil.Big `json:"callGasLimit"`
+		PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit"`
+		PaymasterPostOpGasLimit       *hexutil.Big `json:"paymasterPostOpGasLimit"`
+	}
+	signature := op.signature
+	op.signature = common.FromHex(dummySignature)
+	defer func() { op.signature = signature }()
+	if err := bundler.CallContext(ctx, &estimate, "eth_estimateUserOperationGas", op.rpc(), entryPoint); err != nil {
+		return err
+	}
+	fill := func(limit **big.Int, estimated *hexutil.Big) {
+		if (*limit).Sign() == 0 && estimated != nil {
+			*limit = estimated.ToInt()
+		}
+	}
+	fill(&op.preVerificationGas, estimate.PreVerificationGas)
+	fill(&op.verificationGasLimit, estimate.VerificationGasLimit)
+	fill(&op.callGasLimit, estimate.CallGasLimit)
+	if op.paymaster != nil && op.version == utils.EntryPointV07 {
+		fill(&op.paymasterVerificationGasLimit, estimate.PaymasterVerificationGasLimit)
+		fill(&op.paymasterPostOpGasLimit, estimate.PaymasterPostOpGasLimit)
+	}
+	return nil
+}
+
+// bundlerFailureResponse reports a user operation the bundler rejected as a
+// bad request, and other errors as failures.
+func bundlerFailureResponse(c echo.Context, err error) error {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return utils.BadRequestResponse(c, "bundler rejected user operation : "+err.Error(), nil)
+	}
+	return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+}
+
+// getSmartAccount godoc
+// @Summary Get smart account
+// @Description returns the ERC-4337 smart account of a wallet, owned by the wallet and deployed by a SimpleAccountFactory on its first user operation, with whether it is deployed and its nonce.
+// @Param	request  body	utils.SmartAccountRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getSmartAccount [post]
+func (s *Service) getSmartAccount(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.SmartAccountRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	account, err := newSmartAccount(wallet, u)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if err := account.locate(ctx, client); err != nil {
+		return transactFailureResponse(c, err)
+	}
+	nonce, err := account.nonce(ctx, client, 0)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	return utils.SendSuccessResponse(c, "", &utils.SmartAccount{
+		Address:           account.address.String(),
+		Owner:             account.owner.String(),
+		EntryPoint:        account.entryPoint.String(),
+		EntryPointVersion: account.version,
+		Factory:           account.factory.String(),
+		Deployed:          account.deployed,
+		Nonce:             nonce.String(),
+	})
+}
+
+// sendUserOperation godoc
+// @Summary Send user operation
+// @Description runs calls from the ERC-4337 smart account of a wallet. The user operation deploys the account if needed, gets its gas limits estimated by the chain's bundler unless given, is signed by the wallet and sent to the bundler. With skipSubmit the signed operation is returned without sending it.
+// @Param	request  body	utils.SendUserOperationRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /sendUserOperation [post]
+func (s *Service) sendUserOperation(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.SendUserOperationRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if len(u.Calls) == 0 || len(u.Calls) > maxBatchCalls {
+		return utils.BadRequestResponse(c, fmt.Sprintf("between 1 and %d calls are required", maxBatchCalls), nil)
+	}
+	if u.Paymaster != "" && !common.IsHexAddress(u.Paymaster) {
+		return utils.BadRequestResponse(c, "invalid paymaster address", nil)
+	}
+	var paymasterData []byte
+	if u.PaymasterData != "" {
+		var err error
+		if paymasterData, err = hexutil.Decode(u.PaymasterData); err != nil {
+			return utils.BadRequestResponse(c, "invalid paymasterData : "+err.Error(), nil)
+		}
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	chainId, ok := new(big.Int).SetString(chain.ChainId, 10)
+	if !ok {
+		return utils.UnexpectedFailureResponse(c, "invalid chain id "+chain.ChainId, nil)
+	}
+	wallet, err := s.getWallet(u.WalletId)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, err.Error(), nil)
+	}
+	if wallet.Algorithm != "secp256k1" {
+		return utils.BadRequestResponse(c, "smart accounts need a secp256k1 wallet", nil)
+	}
+	account, err := newSmartAccount(wallet, &utils.SmartAccountRequest{
+		WalletId:          u.WalletId,
+		ChainId:           u.ChainId,
+		EntryPointVersion: u.EntryPointVersion,
+		EntryPoint:        u.EntryPoint,
+		Factory:           u.Factory,
+		FactoryData:       u.FactoryData,
+		Salt:              u.Salt,
+	})
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	callData, err := s.accountCallData(chain.ChainId, account.version, u.Calls)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	client, err := utils.GetEthereumClient(ctx, s.config, chain.ChainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	if err := account.locate(ctx, client); err != nil {
+		return transactFailureResponse(c, err)
+	}
+	nonce, err := account.nonce(ctx, client, u.NonceKey)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	op := &userOperation{
+		version:                       account.version,
+		sender:                        account.address,
+		nonce:                         nonce,
+		callData:                      callData,
+		callGasLimit:                  new(big.Int).SetUint64(u.CallGasLimit),
+		verificationGasLimit:          new(big.Int).SetUint64(u.VerificationGasLimit),
+		preVerificationGas:            new(big.Int).SetUint64(u.PreVerificationGas),
+		paymasterVerificationGasLimit: new(big.Int).SetUint64(u.PaymasterVerificationGasLimit),
+		paymasterPostOpGasLimit:       new(big.Int).SetUint64(u.PaymasterPostOpGasLimit),
+		paymasterData:                 paymasterData,
+	}
+	if !account.deployed {
+		op.factory, op.factoryData = &account.factory, account.factoryData
+	}
+	if u.Paymaster != "" {
+		paymaster := common.HexToAddress(u.Paymaster)
+		op.paymaster = &paymaster
+	}
+	if err := setUserOperationFees(ctx, op, client, chain, u.MaxFeePerGas, u.MaxPriorityFeePerGas); err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	var bundler *rpc.Client
+	if !u.SkipSubmit || u.CallGasLimit == 0 || u.VerificationGasLimit == 0 || u.PreVerificationGas == 0 {
+		if bundler, err = dialBundler(ctx, chain); err != nil {
+			return utils.BadRequestResponse(c, err.Error(), nil)
+		}
+		defer bundler.Close()
+	}
+	if u.CallGasLimit == 0 || u.VerificationGasLimit == 0 || u.PreVerificationGas == 0 {
+		if err := estimateUserOperationGas(ctx, bundler, op, account.entryPoint); err != nil {
+			s.e.Logger.Errorf("error estimating user operation gas : %s", err.Error())
+			return bundlerFailureResponse(c, err)
+		}
+	}
+	userOpHash, err := op.hash(account.entryPoint, chainId)
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	// accounts check an EIP-191 signature of the userOpHash
+	signature, err := SignTransactionHash(ctx, wallet, s.vault, accounts.TextHash(userOpHash.Bytes()))
+	if err != nil {
+		return utils.UnexpectedFailureResponse(c, err.Error(), nil)
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	op.signature = signature
+	res := &utils.SendUserOperationResponse{
+		UserOpHash:    userOpHash.String(),
+		Sender:        account.address.String(),
+		EntryPoint:    account.entryPoint.String(),
+		UserOperation: op.rpc(),
+	}
+	if u.SkipSubmit {
+		return utils.SendSuccessResponse(c, "Signed user operation successfully", res)
+	}
+	var submitted common.Hash
+	if err := bundler.CallContext(ctx, &submitted, "eth_sendUserOperation", res.UserOperation, account.entryPoint); err != nil {
+		s.e.Logger.Errorf("error sending user operation %s : %s", res.UserOpHash, err.Error())
+		return bundlerFailureResponse(c, err)
+	}
+	if submitted != userOpHash {
+		s.e.Logger.Errorf("bundler returned user operation hash %s for %s", submitted.String(), res.UserOpHash)
+	}
+	res.Submitted = true
+	return utils.SendSuccessResponse(c, "Signed and sent user operation successfully", res)
+}
+
+// getUserOperationReceipt godoc
+// @Summary Get user operation receipt
+// @Description returns the receipt of a user operation from the chain's bundler, empty while the operation is pending.
+// @Param	request  body	utils.UserOperationReceiptRequest	true	"Request Body"
+// @Accept json
+// @Produce json
+// @Success 200 	{object} 	utils.ResponseBody
+// @Router /getUserOperationReceipt [post]
+func (s *Service) getUserOperationReceipt(c echo.Context) error {
+	ctx := c.Request().Context()
+	u := new(utils.UserOperationReceiptRequest)
+	if err := c.Bind(u); err != nil {
+		return utils.BadRequestResponse(c, "bad request", nil)
+	}
+	if len(common.FromHex(u.UserOpHash)) != common.HashLength {
+		return utils.BadRequestResponse(c, "invalid userOpHash", nil)
+	}
+	chain, err := s.config.Chains.Get(u.ChainId.String())
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	bundler, err := dialBundler(ctx, chain)
+	if err != nil {
+		return utils.BadRequestResponse(c, err.Error(), nil)
+	}
+	defer bundler.Close()
+	var receipt json.RawMessage
+	if err := bundler.CallContext(ctx, &receipt, "eth_getUserOperationReceipt", common.HexToHash(u.UserOpHash)); err != nil {
+		return bundlerFailureResponse(c, err)
+	}
+	if len(receipt) == 0 || string(receipt) == "null" {
+		return utils.SendSuccessResponse(c, "user operation is pending or unknown", nil)
+	}
+	return utils.SendSuccessResponse(c, "", receipt)
+}
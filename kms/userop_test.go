@@ -0,0 +1,335 @@
+package kms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wallet-kms/store"
+	"wallet-kms/utils"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/google/uuid"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/labstack/echo/v4"
+)
+
+// word left pads b to a 32 byte ABI word.
+func word(b []byte) []byte {
+	return common.LeftPadBytes(b, 32)
+}
+
+// uint128 left pads n to 16 bytes.
+func uint128(n int64) []byte {
+	return common.LeftPadBytes(big.NewInt(n).Bytes(), 16)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// expectedUserOpHash follows getUserOpHash of the EntryPoint byte by byte:
+// keccak256(abi.encode(keccak256(pack(userOp)), entryPoint, chainId)).
+func expectedUserOpHash(packed []byte, entryPoint common.Address, chainId int64) common.Hash {
+	return crypto.Keccak256Hash(concat(crypto.Keccak256(packed), word(entryPoint.Bytes()), word(big.NewInt(chainId).Bytes())))
+}
+
+func TestUserOperationHash(t *testing.T) {
+	sender := common.HexToAddress("0x1306b01bC3e4AD202612D3843387e94737673F53")
+	factory := common.HexToAddress("0x9406Cc6185a346906296840746125a0E44976454")
+	paymaster := common.HexToAddress("0x00000f79B7FaF42EEBAdbA19aCc07cD08Af44789")
+	factoryData := common.FromHex("0x5fbfb9cf000000000000000000000000f39fd6e51aad88f6f4ce6ab8827279cfffb922660000000000000000000000000000000000000000000000000000000000000000")
+	callData := common.FromHex("0xb61d27f6000000000000000000000000f39fd6e51aad88f6f4ce6ab8827279cfffb92266000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000600000000000000000000000000000000000000000000000000000000000000000")
+	paymasterData := common.FromHex("0xdeadbeef")
+	newOp := func(version string) *userOperation {
+		return &userOperation{
+			version:                       version,
+			sender:                        sender,
+			nonce:                         big.NewInt(7),
+			factory:                       &factory,
+			factoryData:                   factoryData,
+			callData:                      callData,
+			callGasLimit:                  big.NewInt(100000),
+			verificationGasLimit:          big.NewInt(150000),
+			preVerificationGas:            big.NewInt(48000),
+			maxFeePerGas:                  big.NewInt(30000000000),
+			maxPriorityFeePerGas:          big.NewInt(1500000000),
+			paymaster:                     &paymaster,
+			paymasterVerificationGasLimit: big.NewInt(60000),
+			paymasterPostOpGasLimit:       big.NewInt(20000),
+			paymasterData:                 paymasterData,
+			signature:                     common.FromHex(dummySignature),
+		}
+	}
+	initCodeHash := crypto.Keccak256(concat(factory.Bytes(), factoryData))
+	callDataHash := crypto.Keccak256(callData)
+	// packed is the operation encoded by hand as the EntryPoint packs it,
+	// want pins the resulting hash
+	tests := []struct {
+		version    string
+		entryPoint common.Address
+		packed     []byte
+		want       string
+	}{
+		{
+			version:    utils.EntryPointV06,
+			entryPoint: common.HexToAddress(entryPointV06),
+			packed: concat(word(sender.Bytes()), word(big.NewInt(7).Bytes()), initCodeHash, callDataHash,
+				word(big.NewInt(100000).Bytes()), word(big.NewInt(150000).Bytes()), word(big.NewInt(48000).Bytes()),
+				word(big.NewInt(30000000000).Bytes()), word(big.NewInt(1500000000).Bytes()),
+				crypto.Keccak256(concat(paymaster.Bytes(), paymasterData))),
+			want: "0x11f985df8713626b09dba18bb13fbc93f831e734f049e6233ffc98d66e8940f8",
+		},
+		{
+			version:    utils.EntryPointV07,
+			entryPoint: common.HexToAddress(entryPointV07),
+			packed: concat(word(sender.Bytes()), word(big.NewInt(7).Bytes()), initCodeHash, callDataHash,
+				concat(uint128(150000), uint128(100000)), word(big.NewInt(48000).Bytes()),
+				concat(uint128(1500000000), uint128(30000000000)),
+				crypto.Keccak256(concat(paymaster.Bytes(), uint128(60000), uint128(20000), paymasterData))),
+			want: "0x6895d60894cf4a439fe66739ba05149b390135c1dec7a471683b93846209de1d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := newOp(tt.version).hash(tt.entryPoint, big.NewInt(11155111))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := expectedUserOpHash(tt.packed, tt.entryPoint, 11155111); got != want {
+				t.Fatalf("hash = %s, want %s", got.String(), want.String())
+			}
+			if got.String() != tt.want {
+				t.Fatalf("hash = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// testVault holds the private key of a single secp256k1 wallet.
+type testVault struct {
+	privateKey string
+}
+
+func (v *testVault) GetSecret(ctx context.Context, secretKey string) (map[string]interface{}, error) {
+	return map[string]interface{}{"private_key": v.privateKey}, nil
+}
+
+func (v *testVault) GetPublicKey(keyName string) (*ecdsa.PublicKey, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (v *testVault) GenerateKey(keyName, algorithm string) (*vaultapi.Secret, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (v *testVault) SignTransactionHash(keyName string, transactionHash []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (v *testVault) AddSecret(ctx context.Context, secretKey string, data map[string]interface{}) error {
+	return errors.New("not implemented")
+}
+
+func (v *testVault) DeleteSecret(ctx context.Context, secretPath string) error {
+	return errors.New("not implemented")
+}
+
+func (v *testVault) GetEIP712Signature(data apitypes.TypedData, keyName string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// stubNode answers the node and bundler calls of sendUserOperation for a
+// counterfactual v0.7 account at sender. Sent user operations are passed to
+// sent, whose return value is the bundler's answer.
+func stubNode(t *testing.T, sender common.Address, sent func(op *utils.UserOperation) common.Hash) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid request %s", string(body))
+			return
+		}
+		var result interface{}
+		var rpcErr map[string]interface{}
+		switch req.Method {
+		case "eth_call":
+			var call struct {
+				Input hexutil.Bytes `json:"input"`
+				Data  hexutil.Bytes `json:"data"`
+			}
+			json.Unmarshal(req.Params[0], &call)
+			input := append(call.Input, call.Data...)
+			switch {
+			case strings.HasPrefix(hexutil.Encode(input), hexutil.Encode(entryPointABI.Methods["getSenderAddress"].ID)):
+				selector := entryPointABI.Errors["SenderAddressResult"].ID
+				rpcErr = map[string]interface{}{"code": 3, "message": "execution reverted",
+					"data": hexutil.Encode(concat(selector[:4], word(sender.Bytes())))}
+			case strings.HasPrefix(hexutil.Encode(input), hexutil.Encode(entryPointABI.Methods["getNonce"].ID)):
+				result = hexutil.Encode(word(big.NewInt(3).Bytes()))
+			default:
+				t.Errorf("unexpected eth_call %s", hexutil.Encode(input))
+			}
+		case "eth_getCode":
+			result = "0x"
+		case "eth_gasPrice":
+			result = "0x3b9aca00"
+		case "eth_estimateUserOperationGas":
+			var op utils.UserOperation
+			json.Unmarshal(req.Params[0], &op)
+			if op.Signature != dummySignature {
+				t.Errorf("estimated with signature %s", op.Signature)
+			}
+			result = map[string]string{"preVerificationGas": "0xbb80", "verificationGasLimit": "0x249f0", "callGasLimit": "0x186a0"}
+		case "eth_sendUserOperation":
+			var op utils.UserOperation
+			var entryPoint common.Address
+			json.Unmarshal(req.Params[0], &op)
+			json.Unmarshal(req.Params[1], &entryPoint)
+			if entryPoint != common.HexToAddress(entryPointV07) {
+				t.Errorf("sent to entry point %s", entryPoint.String())
+			}
+			result = sent(&op)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if rpcErr != nil {
+			res["error"] = rpcErr
+		} else {
+			res["result"] = result
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+// newTestService returns a service with a single secp256k1 wallet on a chain
+// whose RPC and bundler are served by node.
+func newTestService(t *testing.T, node *httptest.Server) (*Service, *Wallet) {
+	db, err := store.NewBadgerDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	chains := filepath.Join(t.TempDir(), "chains.json")
+	config := `{"chains": [{"chainId": "11155111", "rpcUrls": ["` + node.URL + `"], "bundlerUrl": "` + node.URL + `"}]}`
+	if err := os.WriteFile(chains, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := utils.LoadChainRegistry(chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{e: echo.New(), db: db, contracts: NewContractRegistry(db), config: &utils.Config{Chains: registry}}
+	if err := registry.StartPools(s.config, utils.RpcPoolOptions{FailureThreshold: 3}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(ecPrivateKey{Version: ecPrivKeyVersion, PrivateKey: crypto.FromECDSA(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.vault = &testVault{privateKey: base64.RawStdEncoding.EncodeToString(der)}
+	walletId := uuid.New()
+	wallet := &Wallet{Name: "test", Address: crypto.PubkeyToAddress(key.PublicKey).String(), Algorithm: "secp256k1", WalletId: walletId.String()}
+	data, _ := json.Marshal(wallet)
+	if err := db.Set([]byte(utils.NAMESPACE), walletId.NodeID(), data); err != nil {
+		t.Fatal(err)
+	}
+	return s, wallet
+}
+
+func TestSendUserOperation(t *testing.T) {
+	sender := common.HexToAddress("0x1306b01bC3e4AD202612D3843387e94737673F53")
+	var sentOp *utils.UserOperation
+	var sentHash common.Hash
+	node := stubNode(t, sender, func(op *utils.UserOperation) common.Hash {
+		// the bundler answers with the userOpHash of what it received
+		sentOp = op
+		packed := concat(word(sender.Bytes()), word(common.FromHex(op.Nonce)), crypto.Keccak256(common.FromHex(op.Factory+op.FactoryData[2:])),
+			crypto.Keccak256(common.FromHex(op.CallData)), concat(uint128(150000), uint128(100000)), word(big.NewInt(48000).Bytes()),
+			concat(uint128(1000000000), uint128(1000000000)), crypto.Keccak256(nil))
+		sentHash = expectedUserOpHash(packed, common.HexToAddress(entryPointV07), 11155111)
+		return sentHash
+	})
+	defer node.Close()
+	s, wallet := newTestService(t, node)
+
+	body := `{"walletId": "` + wallet.WalletId + `", "calls": [{"to": "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", "value": "1", "data": "0x"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/wallet/sendUserOperation", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	if err := s.sendUserOperation(s.e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d : %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Data utils.SendUserOperationResponse
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Data.Submitted || sentOp == nil {
+		t.Fatal("user operation was not sent to the bundler")
+	}
+	if sentOp.Sender != sender.String() || sentOp.Nonce != "0x3" || sentOp.Factory != simpleAccountFactoryV07 {
+		t.Fatalf("unexpected sender, nonce or factory in %+v", sentOp)
+	}
+	if sentOp.CallGasLimit != "0x186a0" || sentOp.VerificationGasLimit != "0x249f0" || sentOp.PreVerificationGas != "0xbb80" {
+		t.Fatalf("estimated gas limits not used in %+v", sentOp)
+	}
+	if sentOp.MaxFeePerGas != "0x3b9aca00" || sentOp.MaxPriorityFeePerGas != "0x3b9aca00" {
+		t.Fatalf("gas price not used in %+v", sentOp)
+	}
+	wantCallData, _ := simpleAccountABI.Pack("execute", common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), big.NewInt(1), []byte{})
+	if sentOp.CallData != hexutil.Encode(wantCallData) {
+		t.Fatalf("callData = %s, want %s", sentOp.CallData, hexutil.Encode(wantCallData))
+	}
+
+	// the signature is an EIP-191 signature of the userOpHash by the owner
+	signature := common.FromHex(sentOp.Signature)
+	if len(signature) != 65 || signature[64] < 27 {
+		t.Fatalf("invalid signature %s", sentOp.Signature)
+	}
+	signature[64] -= 27
+	userOpHash := common.HexToHash(res.Data.UserOpHash)
+	pub, err := crypto.SigToPub(accounts.TextHash(userOpHash.Bytes()), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := crypto.PubkeyToAddress(*pub); owner != common.HexToAddress(wallet.Address) {
+		t.Fatalf("signed by %s, want %s", owner.String(), wallet.Address)
+	}
+	if userOpHash != sentHash {
+		t.Fatalf("userOpHash = %s, bundler computed %s", userOpHash.String(), sentHash.String())
+	}
+}
@@ -0,0 +1,99 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// webhookSignatureHeader carries the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the subscription secret
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// WebhookPolicy controls the delivery of webhooks.
+type WebhookPolicy struct {
+	// MaxAttempts is the number of deliveries of a payload before giving up
+	// until the next poll.
+	MaxAttempts int
+	// Backoff is the wait after the first failed delivery, doubled after
+	// every further failure.
+	Backoff time.Duration
+	Timeout time.Duration
+}
+
+// webhookPolicyFromEnv reads the webhook policy from WEBHOOK_MAX_ATTEMPTS and
+// WEBHOOK_BACKOFF_SECONDS.
+func webhookPolicyFromEnv() *WebhookPolicy {
+	policy := &WebhookPolicy{MaxAttempts: 5, Backoff: time.Second, Timeout: 10 * time.Second}
+	if attempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		policy.MaxAttempts = attempts
+	}
+	if backoff, err := strconv.Atoi(os.Getenv("WEBHOOK_BACKOFF_SECONDS")); err == nil && backoff > 0 {
+		policy.Backoff = time.Duration(backoff) * time.Second
+	}
+	return policy
+}
+
+// webhookSignature signs a payload sent at timestamp with secret.
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver POSTs payload to url, signed with secret, until it is accepted with
+// a 2xx status or the attempts are used up.
+func (p *WebhookPolicy) Deliver(ctx context.Context, url, secret string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: p.Timeout}
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err = p.post(ctx, client, url, secret, body)
+		if err == nil || attempt >= p.MaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (p *WebhookPolicy) post(ctx context.Context, client *http.Client, url, secret string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+webhookSignature(secret, timestamp, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -32,6 +32,9 @@ type (
 		Get(namespace, key []byte) (value []byte, err error)
 		Set(namespace, key, value []byte) error
 		Has(namespace, key []byte) (bool, error)
+		Delete(namespace, key []byte) error
+		Update(namespace, key []byte, fn func(value []byte) ([]byte, error)) error
+		Iterate(namespace, prefix []byte, fn func(key, value []byte) error) error
 		Close() error
 	}
 
@@ -123,6 +126,73 @@ func (bdb *BadgerDB) Has(namespace, key []byte) (ok bool, err error) {
 	return
 }
 
+// Delete implements the DB interface. It removes the value stored for a given
+// key and namespace. Deleting a key that does not exist is not an error.
+func (bdb *BadgerDB) Delete(namespace, key []byte) error {
+	return bdb.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(badgerNamespaceKey(namespace, key))
+	})
+}
+
+// Update implements the DB interface. It atomically reads the value for a
+// given key and namespace, passes it to fn and stores the returned value within
+// a single transaction. The value passed to fn is nil if the key does not
+// exist. If fn returns an error, nothing is written and the error is returned.
+// Conflicting concurrent updates are retried.
+func (bdb *BadgerDB) Update(namespace, key []byte, fn func(value []byte) ([]byte, error)) error {
+	for {
+		err := bdb.db.Update(func(txn *badger.Txn) error {
+			var value []byte
+			item, err := txn.Get(badgerNamespaceKey(namespace, key))
+			switch err {
+			case nil:
+				value, err = item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+			case badger.ErrKeyNotFound:
+			default:
+				return err
+			}
+
+			value, err = fn(value)
+			if err != nil {
+				return err
+			}
+			return txn.Set(badgerNamespaceKey(namespace, key), value)
+		})
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+// Iterate implements the DB interface. It calls fn for every key/value pair in
+// the namespace whose key starts with prefix, in key order. The key passed to
+// fn has the namespace stripped. Iteration stops at the first error returned
+// by fn and that error is returned.
+func (bdb *BadgerDB) Iterate(namespace, prefix []byte, fn func(key, value []byte) error) error {
+	return bdb.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		nsPrefix := badgerNamespaceKey(namespace, nil)
+		seek := badgerNamespaceKey(namespace, prefix)
+		for it.Seek(seek); it.ValidForPrefix(seek); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(item.KeyCopy(nil)[len(nsPrefix):], value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // Close implements the DB interface. It closes the connection to the underlying
 // BadgerDB database as well as invoking the context's cancel function.
 func (bdb *BadgerDB) Close() error {
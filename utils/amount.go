@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]*)?([eE][+-]?[0-9]{1,2})?$`)
+
+// amountUnits maps the supported units to their number of decimals in wei.
+var amountUnits = map[string]int{
+	"wei":        0,
+	"kwei":       3,
+	"babbage":    3,
+	"mwei":       6,
+	"lovelace":   6,
+	"gwei":       9,
+	"shannon":    9,
+	"szabo":      12,
+	"microether": 12,
+	"finney":     15,
+	"milliether": 15,
+	"ether":      18,
+	"eth":        18,
+}
+
+// Amount is an arbitrary-precision amount given as a decimal string with an
+// optional unit, e.g. "1000", "1.5 ether" or "30 gwei". Plain numbers are in
+// the smallest unit (wei, or the smallest unit of a token). JSON numbers are
+// accepted as well and kept exactly as written.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*a = ""
+	case len(data) > 0 && data[0] == '"':
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*a = Amount(strings.TrimSpace(value))
+	default:
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return fmt.Errorf("invalid amount %s", string(data))
+		}
+		*a = Amount(number)
+	}
+	return nil
+}
+
+func (a Amount) String() string {
+	return string(a)
+}
+
+// IsSet reports whether an amount was given.
+func (a Amount) IsSet() bool {
+	return strings.TrimSpace(string(a)) != ""
+}
+
+// Wei returns the amount in wei. An empty amount is zero.
+func (a Amount) Wei() (*big.Int, error) {
+	return a.Int(18)
+}
+
+// Int returns the amount in the smallest unit of a currency with the given
+// decimals. Besides the ether units, the unit "token" (or "tokens") scales by
+// decimals, so "1.5 tokens" of a 6 decimals token is 1500000. An empty amount
+// is zero.
+func (a Amount) Int(decimals int) (*big.Int, error) {
+	value := strings.TrimSpace(string(a))
+	if value == "" {
+		return big.NewInt(0), nil
+	}
+	number, unit := value, ""
+	if i := strings.IndexAny(value, " \t"); i >= 0 {
+		number, unit = value[:i], strings.ToLower(strings.TrimSpace(value[i:]))
+	}
+	exponent := 0
+	if unit != "" {
+		if unit == "token" || unit == "tokens" {
+			exponent = decimals
+		} else if e, ok := amountUnits[unit]; ok {
+			exponent = e
+		} else {
+			return nil, fmt.Errorf("invalid amount %s : unknown unit %s", value, unit)
+		}
+	}
+	if strings.HasPrefix(number, "-") {
+		return nil, fmt.Errorf("invalid amount %s : must not be negative", value)
+	}
+	if !amountPattern.MatchString(number) {
+		return nil, fmt.Errorf("invalid amount %s", value)
+	}
+	rat, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %s", value)
+	}
+	rat.Mul(rat, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)))
+	if !rat.IsInt() {
+		if unit == "" {
+			return nil, fmt.Errorf("invalid amount %s : fractional amounts need a unit, e.g. \"%s ether\"", value, number)
+		}
+		return nil, fmt.Errorf("invalid amount %s : more decimals than the unit allows", value)
+	}
+	return new(big.Int).Set(rat.Num()), nil
+}
+
+// Tokens returns the amount in the smallest unit of a token with the given
+// decimals. Unlike Int, plain numbers are whole tokens, so "1.5" of a 6
+// decimals token is 1500000. Smallest units can still be given as "1500000 wei".
+func (a Amount) Tokens(decimals int) (*big.Int, error) {
+	value := strings.TrimSpace(string(a))
+	if value != "" && !strings.ContainsAny(value, " \t") {
+		value += " tokens"
+	}
+	return Amount(value).Int(decimals)
+}
+
+// AmountFromInt returns the amount of value in the smallest unit.
+func AmountFromInt(value *big.Int) Amount {
+	if value == nil {
+		return ""
+	}
+	return Amount(value.String())
+}
+
+// FormatAmount formats value, given in the smallest unit of a currency with the
+// given decimals, as a decimal number of whole units, e.g. 1500000000000000000
+// with 18 decimals is "1.5".
+func FormatAmount(value *big.Int, decimals int) string {
+	if value == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(value).String()
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+		digits = whole
+		if fraction != "" {
+			digits += "." + fraction
+		}
+	}
+	if value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type NativeCurrency struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// ChainConfig describes a chain the KMS can send transactions to.
+type ChainConfig struct {
+	ChainId        string         `json:"chainId"`
+	Name           string         `json:"name"`
+	RpcUrls        []string       `json:"rpcUrls"`
+	NativeCurrency NativeCurrency `json:"nativeCurrency"`
+	EIP1559        bool           `json:"eip1559"`
+	Confirmations  uint64         `json:"confirmations"`
+	Explorer       string         `json:"explorer"`
+	// Multicall is the Multicall3 contract of the chain, by default the one at
+	// its canonical address
+	Multicall string `json:"multicall,omitempty"`
+	// WsUrl is a WebSocket RPC endpoint used to receive contract events as
+	// they happen instead of polling for them
+	WsUrl string `json:"wsUrl,omitempty"`
+	// Create2Factory deploys contracts with create2, by default the
+	// deterministic deployment proxy at its canonical address
+	Create2Factory string `json:"create2Factory,omitempty"`
+	// BundlerUrl is an ERC-4337 bundler RPC endpoint user operations are
+	// estimated with and sent to
+	BundlerUrl string `json:"bundlerUrl,omitempty"`
+}
+
+// ChainInfo is the public part of a ChainConfig. RPC URLs are left out since
+// they often carry api keys.
+type ChainInfo struct {
+	ChainId        string         `json:"chainId"`
+	Name           string         `json:"name"`
+	NativeCurrency NativeCurrency `json:"nativeCurrency"`
+	EIP1559        bool           `json:"eip1559"`
+	Confirmations  uint64         `json:"confirmations"`
+	Explorer       string         `json:"explorer,omitempty"`
+	Default        bool           `json:"default"`
+}
+
+// ChainRegistry holds the configured chains by chain id.
+type ChainRegistry struct {
+	DefaultChainId string         `json:"defaultChainId"`
+	Chains         []*ChainConfig `json:"chains"`
+	chains         map[string]*ChainConfig
+	mu             sync.RWMutex
+	pools          map[string]*RpcPool
+}
+
+// ChainId is a chain id accepted as a JSON string or number.
+type ChainId string
+
+func (c *ChainId) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*c = ""
+	case string:
+		*c = ChainId(v)
+	case json.Number:
+		id, err := strconv.ParseUint(v.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid chain id %s", string(data))
+		}
+		*c = ChainId(strconv.FormatUint(id, 10))
+	default:
+		return fmt.Errorf("invalid chain id %s", string(data))
+	}
+	return nil
+}
+
+func (c ChainId) String() string {
+	return string(c)
+}
+
+// LoadChainRegistry reads the chain registry from a JSON file of the form
+// {"defaultChainId": "80001", "chains": [{"chainId": "80001", "rpcUrls": [...]}]}.
+func LoadChainRegistry(path string) (*ChainRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	registry := &ChainRegistry{}
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("error reading chain config : %s", err.Error())
+	}
+	return registry, registry.index()
+}
+
+// NewSingleChainRegistry builds a registry for a single RPC endpoint, asking
+// the endpoint for its chain id and EIP-1559 support.
+func NewSingleChainRegistry(ctx context.Context, config *Config, endpoint string) (*ChainRegistry, error) {
+	client, err := dialEthereumClient(ctx, config, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting chain id of %s : %s", endpoint, err.Error())
+	}
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest header of %s : %s", endpoint, err.Error())
+	}
+	registry := &ChainRegistry{
+		DefaultChainId: chainId.String(),
+		Chains: []*ChainConfig{{
+			ChainId:        chainId.String(),
+			RpcUrls:        []string{endpoint},
+			NativeCurrency: NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18},
+			EIP1559:        header.BaseFee != nil,
+		}},
+	}
+	return registry, registry.index()
+}
+
+func (r *ChainRegistry) index() error {
+	r.chains = make(map[string]*ChainConfig)
+	for _, chain := range r.Chains {
+		if chain.ChainId == "" || len(chain.RpcUrls) == 0 {
+			return fmt.Errorf("chain id and rpc urls are mandatory for every chain")
+		}
+		if _, ok := r.chains[chain.ChainId]; ok {
+			return fmt.Errorf("chain %s configured twice", chain.ChainId)
+		}
+		if chain.NativeCurrency.Decimals == 0 {
+			chain.NativeCurrency.Decimals = 18
+		}
+		r.chains[chain.ChainId] = chain
+	}
+	if len(r.Chains) == 0 {
+		return fmt.Errorf("no chains configured")
+	}
+	if r.DefaultChainId == "" && len(r.Chains) == 1 {
+		r.DefaultChainId = r.Chains[0].ChainId
+	}
+	if _, ok := r.chains[r.DefaultChainId]; r.DefaultChainId != "" && !ok {
+		return fmt.Errorf("default chain %s is not configured", r.DefaultChainId)
+	}
+	return nil
+}
+
+// Get returns the chain with the given id, or the default chain if chainId is
+// empty. Unknown chains are rejected.
+func (r *ChainRegistry) Get(chainId string) (*ChainConfig, error) {
+	chainId = strings.TrimSpace(chainId)
+	if chainId == "" {
+		if r.DefaultChainId == "" {
+			return nil, fmt.Errorf("chainId is mandatory, no default chain configured")
+		}
+		chainId = r.DefaultChainId
+	}
+	chain, ok := r.chains[chainId]
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain id %s, configured chains are %s", chainId, strings.Join(r.ChainIds(), ", "))
+	}
+	return chain, nil
+}
+
+// ChainIds returns the ids of all configured chains in ascending order.
+func (r *ChainRegistry) ChainIds() []string {
+	ids := make([]string, 0, len(r.chains))
+	for id := range r.chains {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// StartPools creates the RPC pool of every configured chain.
+func (r *ChainRegistry) StartPools(config *Config, options RpcPoolOptions) error {
+	pools := make(map[string]*RpcPool)
+	for _, chain := range r.Chains {
+		pool, err := NewRpcPool(chain, rpcHeader(config), options)
+		if err != nil {
+			return err
+		}
+		pools[chain.ChainId] = pool
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pools = pools
+	return nil
+}
+
+// Pool returns the RPC pool of the given chain, or of the default chain if
+// chainId is empty.
+func (r *ChainRegistry) Pool(chainId string) (*RpcPool, error) {
+	chain, err := r.Get(chainId)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pool, ok := r.pools[chain.ChainId]
+	if !ok {
+		return nil, fmt.Errorf("rpc pool of chain %s is not started", chain.ChainId)
+	}
+	return pool, nil
+}
+
+// Pools returns the RPC pools of all configured chains in chain id order.
+func (r *ChainRegistry) Pools() []*RpcPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var pools []*RpcPool
+	for _, id := range r.ChainIds() {
+		if pool, ok := r.pools[id]; ok {
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// Info returns the public details of all configured chains.
+func (r *ChainRegistry) Info() []ChainInfo {
+	var infos []ChainInfo
+	for _, id := range r.ChainIds() {
+		chain := r.chains[id]
+		infos = append(infos, ChainInfo{
+			ChainId:        chain.ChainId,
+			Name:           chain.Name,
+			NativeCurrency: chain.NativeCurrency,
+			EIP1559:        chain.EIP1559,
+			Confirmations:  chain.Confirmations,
+			Explorer:       chain.Explorer,
+			Default:        chain.ChainId == r.DefaultChainId,
+		})
+	}
+	return infos
+}
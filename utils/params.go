@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var bigIntType = reflect.TypeOf(&big.Int{})
+
+// ConvertParams converts params to the Go values go-ethereum packs for the
+// inputs of method, or of the constructor if method is empty. Values are JSON:
+// numbers as numbers or decimal or 0x hex strings, bytes as 0x hex or base64,
+// arrays as JSON arrays or comma separated strings, and tuples as objects
+// keyed by component name or as arrays.
+func ConvertParams(contractABI abi.ABI, method string, params []Param) ([]interface{}, error) {
+	inputs := contractABI.Constructor.Inputs
+	if method != "" {
+		m, ok := contractABI.Methods[method]
+		if !ok {
+			return nil, fmt.Errorf("method %s not found in ABI", method)
+		}
+		inputs = m.Inputs
+	}
+	if len(params) != len(inputs) {
+		return nil, fmt.Errorf("expected %d params, got %d", len(inputs), len(params))
+	}
+	values := make([]interface{}, len(params))
+	for i, input := range inputs {
+		path := input.Name
+		if path == "" {
+			path = fmt.Sprintf("params[%d]", i)
+		}
+		if err := checkParamType(params[i].Type, input.Type); err != nil {
+			return nil, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		var value interface{}
+		if len(params[i].Value) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(params[i].Value))
+			decoder.UseNumber()
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("%s : invalid JSON value : %s", path, err.Error())
+			}
+		}
+		converted, err := convertValue(input.Type, value, path)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = converted.Interface()
+	}
+	return values, nil
+}
+
+// checkParamType checks the optional type of a param matches the ABI. Go
+// style slices like []address and the uint and int aliases are accepted.
+func checkParamType(paramType string, t abi.Type) error {
+	if paramType == "" || strings.HasPrefix(paramType, "tuple") {
+		return nil
+	}
+	normalized := paramType
+	for strings.HasPrefix(normalized, "[]") {
+		normalized = strings.TrimPrefix(normalized, "[]") + "[]"
+	}
+	// the legacy uint and int aliases were converted to uint64 and int64, the
+	// value is range checked against the ABI type instead
+	if base, dims, _ := strings.Cut(normalized, "["); base == "uint" || base == "int" {
+		elem := t
+		for elem.Elem != nil {
+			elem = *elem.Elem
+		}
+		if (base == "uint") != (elem.T == abi.UintTy) || (base == "int") != (elem.T == abi.IntTy) {
+			return fmt.Errorf("type %s does not match ABI type %s", paramType, t.String())
+		}
+		normalized = elem.String()
+		if dims != "" {
+			normalized += "[" + dims
+		}
+	}
+	parsed, err := abi.NewType(normalized, "", nil)
+	if err != nil {
+		return fmt.Errorf("unknown type %s", paramType)
+	}
+	if parsed.String() != t.String() {
+		return fmt.Errorf("type %s does not match ABI type %s", paramType, t.String())
+	}
+	return nil
+}
+
+// convertValue converts a decoded JSON value to the Go type of t.
+func convertValue(t abi.Type, value interface{}, path string) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Value{}, fmt.Errorf("%s : missing value", path)
+	}
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, err := parseInteger(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		if err := checkIntegerRange(n, t); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		goType := t.GetType()
+		if goType == bigIntType {
+			return reflect.ValueOf(n), nil
+		}
+		v := reflect.New(goType).Elem()
+		if t.T == abi.UintTy {
+			v.SetUint(n.Uint64())
+		} else {
+			v.SetInt(n.Int64())
+		}
+		return v, nil
+	case abi.BoolTy:
+		switch b := value.(type) {
+		case bool:
+			return reflect.ValueOf(b), nil
+		case string:
+			if b == "true" || b == "false" {
+				return reflect.ValueOf(b == "true"), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("%s : invalid boolean value", path)
+	case abi.StringTy:
+		switch s := value.(type) {
+		case string:
+			return reflect.ValueOf(s), nil
+		case json.Number:
+			return reflect.ValueOf(s.String()), nil
+		}
+		return reflect.Value{}, fmt.Errorf("%s : expected a string", path)
+	case abi.AddressTy:
+		s, ok := value.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return reflect.Value{}, fmt.Errorf("%s : invalid address", path)
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+	case abi.BytesTy:
+		b, err := parseBytes(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		return reflect.ValueOf(b), nil
+	case abi.FixedBytesTy, abi.FunctionTy:
+		b, err := parseBytes(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		v := reflect.New(t.GetType()).Elem()
+		if len(b) > v.Len() {
+			return reflect.Value{}, fmt.Errorf("%s : %d bytes do not fit %s", path, len(b), t.String())
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v, nil
+	case abi.SliceTy, abi.ArrayTy:
+		items, err := parseList(value)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		var v reflect.Value
+		if t.T == abi.ArrayTy {
+			if len(items) != t.Size {
+				return reflect.Value{}, fmt.Errorf("%s : expected %d items, got %d", path, t.Size, len(items))
+			}
+			v = reflect.New(t.GetType()).Elem()
+		} else {
+			v = reflect.MakeSlice(t.GetType(), len(items), len(items))
+		}
+		for i, item := range items {
+			converted, err := convertValue(*t.Elem, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(converted)
+		}
+		return v, nil
+	case abi.TupleTy:
+		return convertTuple(t, value, path)
+	}
+	return reflect.Value{}, fmt.Errorf("%s : unsupported type %s", path, t.String())
+}
+
+// convertTuple converts an object keyed by component name, or an array in
+// component order, to the struct go-ethereum packs for t.
+func convertTuple(t abi.Type, value interface{}, path string) (reflect.Value, error) {
+	if s, ok := value.(string); ok {
+		decoded, err := decodeJSONString(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s : %s", path, err.Error())
+		}
+		value = decoded
+	}
+	v := reflect.New(t.GetType()).Elem()
+	switch fields := value.(type) {
+	case map[string]interface{}:
+		for i, elem := range t.TupleElems {
+			name := t.TupleRawNames[i]
+			field, ok := fields[name]
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("%s.%s : missing value", path, name)
+			}
+			converted, err := convertValue(*elem, field, path+"."+name)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Field(i).Set(converted)
+		}
+		for name := range fields {
+			if !containsString(t.TupleRawNames, name) {
+				return reflect.Value{}, fmt.Errorf("%s.%s : unknown component", path, name)
+			}
+		}
+	case []interface{}:
+		if len(fields) != len(t.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("%s : expected %d components, got %d", path, len(t.TupleElems), len(fields))
+		}
+		for i, elem := range t.TupleElems {
+			converted, err := convertValue(*elem, fields[i], fmt.Sprintf("%s.%s", path, t.TupleRawNames[i]))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Field(i).Set(converted)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("%s : expected an object or array", path)
+	}
+	return v, nil
+}
+
+// parseInteger reads a JSON number, or a decimal or 0x hex string.
+func parseInteger(value interface{}) (*big.Int, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return nil, fmt.Errorf("expected an integer")
+	}
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "-0x") {
+		s, base = strings.Replace(s, "0x", "", 1), 16
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	return n, nil
+}
+
+func checkIntegerRange(n *big.Int, t abi.Type) error {
+	if t.T == abi.UintTy {
+		if n.Sign() < 0 || n.BitLen() > t.Size {
+			return fmt.Errorf("%s out of range for %s", n.String(), t.String())
+		}
+		return nil
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+	if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return fmt.Errorf("%s out of range for %s", n.String(), t.String())
+	}
+	return nil
+}
+
+// parseBytes reads bytes given as 0x hex or as base64, padded or not. A 0x
+// prefixed value that is not hex is read as base64, which may start with 0x
+// as well.
+func parseBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected 0x hex or base64 bytes")
+	}
+	hexPrefixed := strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")
+	if hexPrefixed {
+		if b, err := hexutil.Decode("0x" + s[2:]); err == nil {
+			return b, nil
+		}
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		if hexPrefixed {
+			return nil, fmt.Errorf("invalid hex or base64 bytes %q", s)
+		}
+		return nil, fmt.Errorf("invalid base64 bytes : %s", err.Error())
+	}
+	return b, nil
+}
+
+// parseList reads a JSON array, a JSON array in a string, or a comma
+// separated string.
+func parseList(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "[") {
+			decoded, err := decodeJSONString(v)
+			if err != nil {
+				return nil, err
+			}
+			if items, ok := decoded.([]interface{}); ok {
+				return items, nil
+			}
+		}
+		if strings.TrimSpace(v) == "" {
+			return []interface{}{}, nil
+		}
+		parts := strings.Split(v, ",")
+		items := make([]interface{}, len(parts))
+		for i, part := range parts {
+			items[i] = strings.TrimSpace(part)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("expected an array")
+}
+
+func decodeJSONString(s string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON value : %s", err.Error())
+	}
+	return value, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const paramsTestABI = `[
+{"type":"function","name":"order","inputs":[{"name":"order","type":"tuple","components":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}]}]},
+{"type":"function","name":"arrays","inputs":[{"name":"fixed","type":"uint8[3]"},{"name":"dynamic","type":"int16[]"}]},
+{"type":"function","name":"blob","inputs":[{"name":"selector","type":"bytes4"},{"name":"data","type":"bytes"}]},
+{"type":"function","name":"legacy","inputs":[{"name":"count","type":"uint32"},{"name":"deltas","type":"int64[]"}]}
+]`
+
+type testOrder struct {
+	To     common.Address
+	Amount *big.Int
+}
+
+func params(values ...string) []Param {
+	res := make([]Param, len(values))
+	for i, value := range values {
+		res[i] = Param{Value: json.RawMessage(value)}
+	}
+	return res
+}
+
+func typedParams(types []string, values ...string) []Param {
+	res := params(values...)
+	for i := range res {
+		res[i].Type = types[i]
+	}
+	return res
+}
+
+func mustBase64(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestConvertParams(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(paramsTestABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	tests := []struct {
+		name    string
+		method  string
+		params  []Param
+		want    []interface{}
+		wantErr string
+	}{
+		{
+			name:   "tuple as object",
+			method: "order",
+			params: params(`{"to":"0x00000000000000000000000000000000000000aa","amount":"0x10"}`),
+			want:   []interface{}{testOrder{To: to, Amount: big.NewInt(16)}},
+		},
+		{
+			name:   "tuple as array",
+			method: "order",
+			params: params(`["0x00000000000000000000000000000000000000aa",16]`),
+			want:   []interface{}{testOrder{To: to, Amount: big.NewInt(16)}},
+		},
+		{
+			name:   "tuple in a JSON string",
+			method: "order",
+			params: params(`"{\"to\":\"0x00000000000000000000000000000000000000aa\",\"amount\":\"16\"}"`),
+			want:   []interface{}{testOrder{To: to, Amount: big.NewInt(16)}},
+		},
+		{
+			name:   "fixed and dynamic arrays",
+			method: "arrays",
+			params: params(`[1,2,"0x03"]`, `"-1, 2"`),
+			want:   []interface{}{[3]uint8{1, 2, 3}, []int16{-1, 2}},
+		},
+		{
+			name:   "bytesN as hex and bytes as base64",
+			method: "blob",
+			params: params(`"0x12345678"`, `"AQI="`),
+			want:   []interface{}{[4]byte{0x12, 0x34, 0x56, 0x78}, []byte{1, 2}},
+		},
+		{
+			name:   "short bytesN are right padded",
+			method: "blob",
+			params: params(`"0x12"`, `"0x"`),
+			want:   []interface{}{[4]byte{0x12}, []byte{}},
+		},
+		{
+			name:   "base64 starting with 0x",
+			method: "blob",
+			params: params(`"0x000000"`, `"0xZm9vYg"`),
+			want:   []interface{}{[4]byte{}, mustBase64("0xZm9vYg")},
+		},
+		{
+			name:   "legacy uint and int types",
+			method: "legacy",
+			params: typedParams([]string{"uint", "[]int"}, `7`, `[-1,"2"]`),
+			want:   []interface{}{uint32(7), []int64{-1, 2}},
+		},
+		{
+			name:   "ABI types",
+			method: "legacy",
+			params: typedParams([]string{"uint32", "int64[]"}, `7`, `[]`),
+			want:   []interface{}{uint32(7), []int64{}},
+		},
+		{
+			name:    "unknown method",
+			method:  "missing",
+			wantErr: "method missing not found in ABI",
+		},
+		{
+			name:    "param count",
+			method:  "arrays",
+			params:  params(`[1,2,3]`),
+			wantErr: "expected 2 params, got 1",
+		},
+		{
+			name:    "legacy type of other signedness",
+			method:  "legacy",
+			params:  typedParams([]string{"int", "[]int"}, `7`, `[]`),
+			wantErr: "count : type int does not match ABI type uint32",
+		},
+		{
+			name:    "type mismatch",
+			method:  "legacy",
+			params:  typedParams([]string{"uint64", "int64[]"}, `7`, `[]`),
+			wantErr: "count : type uint64 does not match ABI type uint32",
+		},
+		{
+			name:    "legacy value out of range",
+			method:  "legacy",
+			params:  typedParams([]string{"uint", "[]int"}, `4294967296`, `[]`),
+			wantErr: "count : 4294967296 out of range for uint32",
+		},
+		{
+			name:    "fixed array length",
+			method:  "arrays",
+			params:  params(`[1,2]`, `[]`),
+			wantErr: "fixed : expected 3 items, got 2",
+		},
+		{
+			name:    "array item out of range",
+			method:  "arrays",
+			params:  params(`[1,2,256]`, `[]`),
+			wantErr: "fixed[2] : 256 out of range for uint8",
+		},
+		{
+			name:    "unknown tuple component",
+			method:  "order",
+			params:  params(`{"to":"0x00000000000000000000000000000000000000aa","amount":1,"fee":2}`),
+			wantErr: "order.fee : unknown component",
+		},
+		{
+			name:    "missing tuple component",
+			method:  "order",
+			params:  params(`{"to":"0x00000000000000000000000000000000000000aa"}`),
+			wantErr: "order.amount : missing value",
+		},
+		{
+			name:    "invalid address",
+			method:  "order",
+			params:  params(`["0x1234",1]`),
+			wantErr: "order.to : invalid address",
+		},
+		{
+			name:    "bytesN too long",
+			method:  "blob",
+			params:  params(`"0x1234567890"`, `"0x"`),
+			wantErr: "selector : 5 bytes do not fit bytes4",
+		},
+		{
+			name:    "invalid bytes",
+			method:  "blob",
+			params:  params(`"0x12345678"`, `"0x0g!"`),
+			wantErr: "data : invalid hex or base64 bytes",
+		},
+		{
+			name:    "missing value",
+			method:  "blob",
+			params:  []Param{{Value: json.RawMessage(`"0x12345678"`)}, {}},
+			wantErr: "data : missing value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertParams(contractABI, tt.method, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs := contractABI.Methods[tt.method].Inputs
+			packed, err := inputs.Pack(got...)
+			if err != nil {
+				t.Fatalf("packing converted params : %s", err.Error())
+			}
+			want, err := inputs.Pack(tt.want...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(packed, want) {
+				t.Errorf("packed %x, want %x", packed, want)
+			}
+		})
+	}
+}
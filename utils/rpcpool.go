@@ -0,0 +1,420 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "halfOpen"
+)
+
+// RpcPoolOptions tunes failover and health checking of the RPC pools.
+type RpcPoolOptions struct {
+	// MaxBlockLag is the number of blocks an endpoint may trail the best
+	// endpoint of its chain before it is considered unhealthy.
+	MaxBlockLag uint64
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit of an endpoint.
+	FailureThreshold int
+	// CircuitCooldown is how long an open circuit rejects requests before a
+	// single trial request is let through.
+	CircuitCooldown time.Duration
+	// Timeout bounds every health check request.
+	Timeout time.Duration
+}
+
+// RpcPoolOptionsFromEnv reads the pool options from RPC_MAX_BLOCK_LAG,
+// RPC_FAILURE_THRESHOLD and RPC_CIRCUIT_COOLDOWN.
+func RpcPoolOptionsFromEnv() RpcPoolOptions {
+	options := RpcPoolOptions{MaxBlockLag: 5, FailureThreshold: 3, CircuitCooldown: 30 * time.Second, Timeout: 5 * time.Second}
+	if lag, err := strconv.ParseUint(os.Getenv("RPC_MAX_BLOCK_LAG"), 10, 64); err == nil {
+		options.MaxBlockLag = lag
+	}
+	if threshold, err := strconv.Atoi(os.Getenv("RPC_FAILURE_THRESHOLD")); err == nil && threshold > 0 {
+		options.FailureThreshold = threshold
+	}
+	if cooldown, err := strconv.Atoi(os.Getenv("RPC_CIRCUIT_COOLDOWN")); err == nil && cooldown > 0 {
+		options.CircuitCooldown = time.Duration(cooldown) * time.Second
+	}
+	return options
+}
+
+// RpcEndpointStats is the state of one RPC endpoint of a chain. Only the host
+// of the endpoint is exposed since URLs often carry api keys.
+type RpcEndpointStats struct {
+	Index               int    `json:"index"`
+	Host                string `json:"host"`
+	Healthy             bool   `json:"healthy"`
+	Circuit             string `json:"circuit"`
+	Requests            uint64 `json:"requests"`
+	Failures            uint64 `json:"failures"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	LatencyMs           int64  `json:"latencyMs"`
+	BlockNumber         uint64 `json:"blockNumber"`
+	BlockLag            uint64 `json:"blockLag"`
+	CheckedAt           int64  `json:"checkedAt,omitempty"`
+}
+
+// RpcPoolStats is the state of the RPC endpoints of a chain.
+type RpcPoolStats struct {
+	ChainId   string             `json:"chainId"`
+	Endpoints []RpcEndpointStats `json:"endpoints"`
+}
+
+type rpcEndpoint struct {
+	url       *url.URL
+	stats     RpcEndpointStats
+	openUntil time.Time
+	// trialAt is when the last trial request went to a half open circuit
+	trialAt time.Time
+	// wrongChain is set when the endpoint reported another chain id than the
+	// one it is configured for
+	wrongChain bool
+}
+
+// RpcPool spreads the JSON-RPC requests of a chain over its configured RPC
+// URLs. Requests go to the best available endpoint and fail over to the next
+// one on network errors and server errors. An endpoint failing repeatedly is
+// taken out of rotation by a circuit breaker for a cooldown period.
+type RpcPool struct {
+	mu        sync.Mutex
+	chainId   string
+	options   RpcPoolOptions
+	header    http.Header
+	endpoints []*rpcEndpoint
+	transport http.RoundTripper
+	client    *ethclient.Client
+}
+
+// NewRpcPool creates the pool of a chain. header is sent with every request,
+// including health checks.
+func NewRpcPool(chain *ChainConfig, header http.Header, options RpcPoolOptions) (*RpcPool, error) {
+	pool := &RpcPool{chainId: chain.ChainId, options: options, header: header, transport: http.DefaultTransport}
+	for i, rawUrl := range chain.RpcUrls {
+		endpoint, err := url.Parse(rawUrl)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid rpc url %d of chain %s", i, chain.ChainId)
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, fmt.Errorf("rpc url %d of chain %s must be http or https", i, chain.ChainId)
+		}
+		pool.endpoints = append(pool.endpoints, &rpcEndpoint{
+			url:   endpoint,
+			stats: RpcEndpointStats{Index: i, Host: endpoint.Host, Healthy: true, Circuit: CircuitClosed},
+		})
+	}
+	// the rpc client talks to the pool, which forwards every request to a real
+	// endpoint, so the dial url only needs to be a valid http url
+	rpcClient, err := rpc.DialOptions(context.Background(), "http://rpc-pool/"+chain.ChainId,
+		rpc.WithHTTPClient(&http.Client{Transport: pool}), rpc.WithHeaders(header))
+	if err != nil {
+		return nil, err
+	}
+	pool.client = ethclient.NewClient(rpcClient)
+	return pool, nil
+}
+
+// Client returns the chain client backed by the pool. It is shared and must not
+// be closed.
+func (p *RpcPool) Client() *ethclient.Client {
+	return p.client
+}
+
+// RoundTrip implements http.RoundTripper by forwarding the request to the
+// endpoints of the pool in order of preference until one answers. Requests
+// sending a transaction only move on when the endpoint could not be reached.
+func (p *RpcPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	candidates := p.candidates()
+	send := isSendRequest(body)
+	var lastErr error
+	for _, endpoint := range candidates {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		forward := req.Clone(req.Context())
+		forward.URL = endpoint.url
+		forward.Host = endpoint.url.Host
+		forward.Body = io.NopCloser(bytes.NewReader(body))
+		forward.ContentLength = int64(len(body))
+		start := time.Now()
+		resp, err := p.transport.RoundTrip(forward)
+		if err == nil && (resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests) {
+			resp.Body.Close()
+			err = fmt.Errorf("%s returned %s", endpoint.url.Host, resp.Status)
+		}
+		if err != nil {
+			p.recordFailure(endpoint, err)
+			lastErr = err
+			// a send the endpoint may have broadcast is not resent, the retry
+			// would fail as already known or nonce too low
+			if send && !isDialError(err) {
+				break
+			}
+			continue
+		}
+		p.recordSuccess(endpoint, time.Since(start))
+		return resp, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no rpc endpoint available")
+	}
+	return nil, fmt.Errorf("all rpc endpoints of chain %s failed, last error : %s", p.chainId, lastErr.Error())
+}
+
+// sendMethods are the JSON-RPC methods that are not safe to repeat on
+// another endpoint.
+var sendMethods = map[string]bool{
+	"eth_sendRawTransaction": true,
+	"eth_sendTransaction":    true,
+}
+
+// isSendRequest reports whether a JSON-RPC request, or any request of a batch,
+// sends a transaction.
+func isSendRequest(body []byte) bool {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	if err := json.Unmarshal(body, &calls); err != nil {
+		var single call
+		if err := json.Unmarshal(body, &single); err != nil {
+			return false
+		}
+		calls = []call{single}
+	}
+	for _, c := range calls {
+		if sendMethods[c.Method] {
+			return true
+		}
+	}
+	return false
+}
+
+// isDialError reports whether a request failed before it reached the
+// endpoint.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// candidates orders the endpoints for a request: healthy endpoints with a
+// closed circuit first, by block lag and latency, then unhealthy ones. Open
+// circuits are skipped, except for a single trial request once their cooldown
+// is over. If every circuit is open all endpoints are tried anyway, except
+// those serving another chain.
+func (p *RpcPool) candidates() []*rpcEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var available, chainEndpoints []*rpcEndpoint
+	for _, endpoint := range p.endpoints {
+		if endpoint.wrongChain {
+			continue
+		}
+		chainEndpoints = append(chainEndpoints, endpoint)
+		switch endpoint.stats.Circuit {
+		case CircuitOpen:
+			if now.Before(endpoint.openUntil) {
+				continue
+			}
+			endpoint.stats.Circuit = CircuitHalfOpen
+			endpoint.trialAt = now
+		case CircuitHalfOpen:
+			// a trial that never got its turn is retried after another cooldown
+			if now.Sub(endpoint.trialAt) < p.options.CircuitCooldown {
+				continue
+			}
+			endpoint.trialAt = now
+		}
+		available = append(available, endpoint)
+	}
+	if len(available) == 0 {
+		available = append(available, chainEndpoints...)
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		a, b := available[i].stats, available[j].stats
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		if a.BlockLag != b.BlockLag {
+			return a.BlockLag < b.BlockLag
+		}
+		return a.LatencyMs < b.LatencyMs
+	})
+	return available
+}
+
+func (p *RpcPool) recordSuccess(endpoint *rpcEndpoint, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	endpoint.stats.Requests++
+	endpoint.stats.ConsecutiveFailures = 0
+	endpoint.stats.LatencyMs = latency.Milliseconds()
+	endpoint.stats.Circuit = CircuitClosed
+}
+
+func (p *RpcPool) recordFailure(endpoint *rpcEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	endpoint.stats.Requests++
+	endpoint.stats.Failures++
+	endpoint.stats.ConsecutiveFailures++
+	endpoint.stats.LastError = err.Error()
+	if endpoint.stats.Circuit == CircuitHalfOpen || endpoint.stats.ConsecutiveFailures >= p.options.FailureThreshold {
+		endpoint.stats.Circuit = CircuitOpen
+		endpoint.openUntil = time.Now().Add(p.options.CircuitCooldown)
+	}
+}
+
+// CheckHealth asks every endpoint for its latest block, latency and chain id.
+// Endpoints that fail or trail the best endpoint by more than MaxBlockLag
+// blocks are marked unhealthy and only used when no healthy endpoint is left.
+// Endpoints serving another chain than configured are not used at all.
+func (p *RpcPool) CheckHealth(ctx context.Context) {
+	type result struct {
+		block      uint64
+		latency    time.Duration
+		err        error
+		wrongChain bool
+	}
+	results := make([]result, len(p.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range p.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *rpcEndpoint) {
+			defer wg.Done()
+			start := time.Now()
+			block, err := p.blockNumber(ctx, endpoint)
+			results[i] = result{block: block, latency: time.Since(start), err: err}
+			if err != nil {
+				return
+			}
+			chainId, err := p.quantity(ctx, endpoint, "eth_chainId")
+			if err != nil {
+				results[i].err = err
+			} else if chainId.String() != p.chainId {
+				results[i].err = fmt.Errorf("%s serves chain %s instead of %s", endpoint.url.Host, chainId.String(), p.chainId)
+				results[i].wrongChain = true
+			}
+		}(i, endpoint)
+	}
+	wg.Wait()
+	var best uint64
+	for _, r := range results {
+		if r.err == nil && r.block > best {
+			best = r.block
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now().Unix()
+	for i, endpoint := range p.endpoints {
+		r := results[i]
+		endpoint.stats.CheckedAt = now
+		endpoint.wrongChain = r.wrongChain
+		if r.err != nil {
+			endpoint.stats.Healthy = false
+			endpoint.stats.LastError = r.err.Error()
+			continue
+		}
+		endpoint.stats.BlockNumber = r.block
+		endpoint.stats.BlockLag = best - r.block
+		endpoint.stats.LatencyMs = r.latency.Milliseconds()
+		endpoint.stats.Healthy = endpoint.stats.BlockLag <= p.options.MaxBlockLag
+	}
+}
+
+// blockNumber calls eth_blockNumber on a single endpoint, bypassing failover.
+func (p *RpcPool) blockNumber(ctx context.Context, endpoint *rpcEndpoint) (uint64, error) {
+	block, err := p.quantity(ctx, endpoint, "eth_blockNumber")
+	if err != nil {
+		return 0, err
+	}
+	if !block.IsUint64() {
+		return 0, fmt.Errorf("invalid eth_blockNumber result %s", block.String())
+	}
+	return block.Uint64(), nil
+}
+
+// quantity calls a parameterless method returning a quantity on a single
+// endpoint, bypassing failover.
+func (p *RpcPool) quantity(ctx context.Context, endpoint *rpcEndpoint, method string) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+	defer cancel()
+	payload := []byte(`{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.url.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = p.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", endpoint.url.Host, resp.Status)
+	}
+	var result struct {
+		Result *hexutil.Big `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, errors.New(result.Error.Message)
+	}
+	if result.Result == nil {
+		return nil, fmt.Errorf("empty %s result", method)
+	}
+	return result.Result.ToInt(), nil
+}
+
+// Stats returns a snapshot of the endpoint states.
+func (p *RpcPool) Stats() RpcPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := RpcPoolStats{ChainId: p.chainId}
+	for _, endpoint := range p.endpoints {
+		endpointStats := endpoint.stats
+		if endpointStats.Circuit == CircuitOpen && !time.Now().Before(endpoint.openUntil) {
+			endpointStats.Circuit = CircuitHalfOpen
+		}
+		stats.Endpoints = append(stats.Endpoints, endpointStats)
+	}
+	return stats
+}
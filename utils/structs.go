@@ -1,15 +1,48 @@
 package utils
 
+import "encoding/json"
+
+const (
+	NAMESPACE       = "wallet"
+	NONCE_NAMESPACE = "nonce"
+	TXN_NAMESPACE   = "txn"
+	// WALLET_TXN_NAMESPACE indexes tracked transactions by wallet
+	WALLET_TXN_NAMESPACE = "wallet-txn"
+	// OPEN_TXN_NAMESPACE indexes tracked transactions that are not final yet
+	OPEN_TXN_NAMESPACE = "open-txn"
+	// TOKEN_NAMESPACE caches token metadata by chain and token address
+	TOKEN_NAMESPACE = "token"
+	// NFT_NAMESPACE caches the detected standard of NFT contracts
+	NFT_NAMESPACE = "nft"
+	// SUBSCRIPTION_NAMESPACE stores contract event subscriptions by id
+	SUBSCRIPTION_NAMESPACE = "subscription"
+	// CONTRACT_NAMESPACE stores registered contracts by chain and address,
+	// CONTRACT_ALIAS_NAMESPACE their aliases by chain and name
+	CONTRACT_NAMESPACE       = "contract"
+	CONTRACT_ALIAS_NAMESPACE = "contract-alias"
+	// PENDING_CONTRACT_NAMESPACE stores contract registrations by the hash of
+	// the txn they wait for
+	PENDING_CONTRACT_NAMESPACE = "pending-contract"
+)
+
 const (
-	NAMESPACE = "wallet"
+	TxnStatusPending   = "pending"
+	TxnStatusMined     = "mined"
+	TxnStatusConfirmed = "confirmed"
+	TxnStatusFailed    = "failed"
+	TxnStatusDropped   = "dropped"
+	TxnStatusReplaced  = "replaced"
 )
 
 type Config struct {
-	AuthToken      string
-	ProxyUrl       string
-	Endpoint       string
-	InstanceId     string
-	SubscriptionId string
+	AuthToken            string
+	ProxyUrl             string
+	Endpoint             string
+	InstanceId           string
+	SubscriptionId       string
+	NonceSync            bool
+	BalanceProxyFallback bool
+	Chains               *ChainRegistry
 }
 
 type WalletRequest struct {
@@ -23,8 +56,12 @@ type WalletResponse struct {
 }
 
 type WalletBalanceRequest struct {
-	WalletId string `json:"walletId"`
-	ChainId  string `json:"chainId"`
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId" swaggertype:"string" example:"80001"`
+	// BlockNumber is a decimal or 0x prefixed block number, latest if empty
+	BlockNumber string `json:"blockNumber,omitempty" example:"latest"`
+	// Tokens are ERC-20 contracts whose balances are returned as well
+	Tokens []string `json:"tokens,omitempty"`
 }
 
 type TransactionResponse struct {
@@ -34,43 +71,88 @@ type TransactionResponse struct {
 	Transaction string
 }
 
+const (
+	DeployModeCreate  = "create"
+	DeployModeCreate2 = "create2"
+)
+
 type DeployContractRequest struct {
-	WalletId string  `json:"walletId"`
-	ByteCode string  `json:"byteCode"`
-	ABI      string  `json:"abi"`
-	Params   []Param `json:"params"`
+	WalletId       string  `json:"walletId"`
+	ChainId        ChainId `json:"chainId" swaggertype:"string" example:"80001"`
+	ByteCode       string  `json:"byteCode"`
+	ABI            string  `json:"abi"`
+	Params         []Param `json:"params"`
+	SkipSimulation bool    `json:"skipSimulation,omitempty"`
+	// ContractName registers the deployed contract under this alias
+	ContractName string `json:"contractName,omitempty"`
+	// DeployMode is create for a nonce based address, or create2 for an
+	// address derived from salt and init code through the CREATE2 factory of
+	// the chain
+	DeployMode string `json:"deployMode,omitempty" example:"create2"`
+	// Salt is 32 bytes as 0x prefixed hex, shorter values are left padded.
+	// Any other text is hashed with keccak256. Zero if empty.
+	Salt string `json:"salt,omitempty" example:"0x01"`
+	// Artifact is a Hardhat or Foundry artifact JSON, used instead of
+	// byteCode and abi. Its link references are resolved from Libraries,
+	// then from contracts registered under the library name, then by
+	// deploying LibraryArtifacts
+	Artifact json.RawMessage `json:"artifact,omitempty" swaggertype:"object"`
+	// Libraries maps library names, or source:name, to addresses
+	Libraries map[string]string `json:"libraries,omitempty"`
+	// LibraryArtifacts maps library names, or source:name, to the artifacts
+	// of libraries to deploy when they are not deployed yet
+	LibraryArtifacts map[string]json.RawMessage `json:"libraryArtifacts,omitempty" swaggertype:"object"`
 }
 
 type DeployContractResponse struct {
 	TxnHash         string `json:"txHash,omitempty"`
 	ContractAddress string `json:"contractAddress,omitempty"`
+	// Receipt is set when submitTransaction waited for it
+	Receipt *TransactionEventsResponse `json:"receipt,omitempty"`
+	// AlreadyDeployed is set when a create2 deploy found code at the
+	// predicted address and sent no txn
+	AlreadyDeployed bool `json:"alreadyDeployed,omitempty"`
+	// Libraries maps source:name of the linked libraries to their addresses
+	Libraries map[string]string `json:"libraries,omitempty"`
 }
 
 type SignAndSubmitTxn struct {
 	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId" swaggertype:"string" example:"80001"`
 	To       string  `json:"to"`
 	Gas      uint64  `json:"gas"`
 	GasLimit uint64  `json:"gasLimit"`
-	Value    int64   `json:"value"`
+	GasPrice Amount  `json:"gasPrice,omitempty" swaggertype:"string" example:"30 gwei"`
+	Value    Amount  `json:"value" swaggertype:"string" example:"1.5 ether"`
 	Method   string  `json:"method"`
 	Params   []Param `json:"params"`
 	// Params        []interface{} `json:"params"`
-	IsContractTxn bool   `json:"isContractTxn"`
-	ContractABI   string `json:"contractABI"`
-	Data          string `json:"data"`
+	IsContractTxn  bool   `json:"isContractTxn"`
+	ContractABI    string `json:"contractABI"`
+	Data           string `json:"data"`
+	SkipSimulation bool   `json:"skipSimulation,omitempty"`
+	// WaitForReceipt waits until the txn is mined and returns its decoded events
+	WaitForReceipt bool `json:"waitForReceipt,omitempty"`
+	// ContractName picks a registered contract for To and a missing ContractABI
+	ContractName    string `json:"contractName,omitempty"`
+	ContractVersion int    `json:"contractVersion,omitempty"`
 }
 
 type EstimateGasRequest struct {
 	WalletId      string  `json:"walletId"`
+	ChainId       ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
 	To            string  `json:"to,omitempty"`
 	Gas           uint64  `json:"gas,omitempty"`
-	Value         int64   `json:"value,omitempty"`
+	Value         Amount  `json:"value,omitempty" swaggertype:"string" example:"1.5 ether"`
 	Method        string  `json:"method,omitempty"`
 	Params        []Param `json:"params,omitempty" `
 	IsContractTxn bool    `json:"isContractTxn,omitempty"`
 	ContractABI   string  `json:"contractABI,omitempty"`
 	Data          string  `json:"data,omitempty"`
 	ByteCode      string  `json:"byteCode,omitempty"`
+	// ContractName picks a registered contract for To and a missing ContractABI
+	ContractName    string `json:"contractName,omitempty"`
+	ContractVersion int    `json:"contractVersion,omitempty"`
 }
 
 type EstimatedGasResponse struct {
@@ -78,9 +160,77 @@ type EstimatedGasResponse struct {
 	EstimatedGas uint64 `json:"estimatedGas"`
 }
 
+// Param is an argument of a contract method or constructor. Value is any
+// JSON value, converted to the type of the argument in the ABI. Type is
+// optional and only checked against the ABI.
 type Param struct {
-	Type  string `json:"type"`
-	Value string `json:"value"`
+	Type  string          `json:"type,omitempty" example:"uint256"`
+	Value json.RawMessage `json:"value" swaggertype:"string" example:"42"`
+}
+
+// TxnAttemptRequest reports an automatic fee replacement of a scheduled
+// transaction to the platform.
+type TxnAttemptRequest struct {
+	WalletId        string `json:"walletId"`
+	ChainId         string `json:"chainId"`
+	ReferenceId     string `json:"referenceId"`
+	Type            string `json:"type"`
+	Attempt         int    `json:"attempt"`
+	TxnHash         string `json:"txnHash,omitempty"`
+	ReplacedTxnHash string `json:"replacedTxnHash"`
+	Status          string `json:"status"`
+	Message         string `json:"message,omitempty"`
+}
+
+type GetTransactionRequest struct {
+	TxnHash string `json:"txHash" example:"0x2f1c5c2b44f771e942a8506148e256f94f1a464babc938ae0690c6e34cd79190"`
+}
+
+type ListTransactionsRequest struct {
+	WalletId string `json:"walletId"`
+	Status   string `json:"status,omitempty" example:"pending"`
+}
+
+// TransactionRecord is a transaction broadcast by the KMS along with its
+// lifecycle on chain.
+type TransactionRecord struct {
+	TxnHash           string `json:"txHash"`
+	WalletId          string `json:"walletId"`
+	ChainId           string `json:"chainId"`
+	From              string `json:"from"`
+	To                string `json:"to,omitempty"`
+	ContractAddress   string `json:"contractAddress,omitempty"`
+	Nonce             uint64 `json:"nonce"`
+	Type              string `json:"type"`
+	ReferenceId       string `json:"referenceId,omitempty"`
+	Status            string `json:"status"`
+	BlockNumber       uint64 `json:"blockNumber,omitempty"`
+	BlockHash         string `json:"blockHash,omitempty"`
+	Confirmations     uint64 `json:"confirmations"`
+	GasUsed           uint64 `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	Reorgs            int    `json:"reorgs,omitempty"`
+	Replaces          string `json:"replaces,omitempty"`
+	ReplacedBy        string `json:"replacedBy,omitempty"`
+	Attempts          int    `json:"attempts,omitempty"`
+	GasBumpHalted     string `json:"gasBumpHalted,omitempty"`
+	RawTxn            string `json:"rawTxn,omitempty"`
+	SubmittedAt       int64  `json:"submittedAt"`
+	UpdatedAt         int64  `json:"updatedAt"`
+}
+
+type ReplaceTransactionRequest struct {
+	WalletId string `json:"walletId"`
+	TxnHash  string `json:"txHash"`
+	// optional fees, at least 10% above the ones of the replaced txn
+	GasPrice             Amount `json:"gasPrice,omitempty" swaggertype:"string" example:"30 gwei"`
+	MaxFeePerGas         Amount `json:"maxFeePerGas,omitempty" swaggertype:"string"`
+	MaxPriorityFeePerGas Amount `json:"maxPriorityFeePerGas,omitempty" swaggertype:"string"`
+}
+
+type ReplaceTransactionResponse struct {
+	TxnHash         string `json:"txHash"`
+	ReplacedTxnHash string `json:"replacedTxHash"`
 }
 
 type SignAndSubmitTxnResponse struct {
@@ -110,6 +260,7 @@ type NonceRequest struct {
 
 type Deploy struct {
 	ReferenceId    string  `json:"referenceId"`
+	ChainId        ChainId `json:"chainId"`
 	SubscriptionId string  `json:"subscriptionId"`
 	WalletId       string  `json:"walletId"`
 	InstanceId     string  `json:"instanceId"`
@@ -120,12 +271,14 @@ type Deploy struct {
 
 type Transaction struct {
 	ReferenceId    string  `json:"referenceId"`
+	ChainId        ChainId `json:"chainId"`
 	SubscriptionId string  `json:"subscriptionId"`
 	WalletId       string  `json:"walletId"`
 	InstanceId     string  `json:"instanceId"`
 	To             string  `json:"to"`
 	Gas            uint64  `json:"gas"`
-	Value          int64   `json:"value"`
+	GasPrice       Amount  `json:"gasPrice,omitempty"`
+	Value          Amount  `json:"value"`
 	Method         string  `json:"method"`
 	Params         []Param `json:"params"`
 	IsContractTxn  bool    `json:"isContractTxn"`
@@ -135,16 +288,34 @@ type Transaction struct {
 
 type CallContractRequest struct {
 	WalletId    string  `json:"walletId"`
+	ChainId     ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
 	To          string  `json:"to,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
 	Gas         uint64  `json:"gas,omitempty"`
-	Value       int64   `json:"value,omitempty"`
+	Value       Amount  `json:"value,omitempty" swaggertype:"string" example:"0"`
 	Method      string  `json:"method,omitempty" example:"store"`
 	Params      []Param `json:"params,omitempty" `
 	ContractABI string  `json:"contractABI,omitempty" example:"hello"`
+	// ContractName picks a registered contract for To and a missing ContractABI
+	ContractName    string `json:"contractName,omitempty"`
+	ContractVersion int    `json:"contractVersion,omitempty"`
+	// From overrides the caller, by default the wallet. walletId is not
+	// needed with it
+	From string `json:"from,omitempty"`
+	// BlockNumber, decimal or 0x hex, or BlockTag (latest, pending, safe,
+	// finalized or earliest) selects the block state the call runs on.
+	// Latest by default
+	BlockNumber string `json:"blockNumber,omitempty" example:"17000000"`
+	BlockTag    string `json:"blockTag,omitempty" example:"latest"`
 }
 
+// CallContractResponse has the decoded outputs of a call: integers as
+// decimal strings, bytes as hex, addresses checksummed and tuples as objects
+// keyed by component name.
 type CallContractResponse struct {
-	Response *[]interface{} `json:"response"`
+	// Response has the outputs in order
+	Response []interface{} `json:"response"`
+	// Outputs has the named outputs by name
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
 }
 
 type EIP712SignRequest struct {
@@ -152,15 +323,39 @@ type EIP712SignRequest struct {
 	Data     string `json:"data"`
 }
 
+// message signing modes
+const (
+	// SignModePersonal signs the text of the message with the EIP-191
+	// "\x19Ethereum Signed Message:\n" prefix, like personal_sign
+	SignModePersonal = "personal_sign"
+	// SignModeBytes signs 0x hex bytes with the personal_sign prefix
+	SignModeBytes = "bytes"
+	// SignModeHash signs a 0x hex 32-byte hash as is
+	SignModeHash = "hash"
+	// SignModeValidator signs EIP-191 version 0x00 data for a validator
+	// contract
+	SignModeValidator = "validator"
+	// SignModeKeccak signs the keccak256 hash of the text without a prefix,
+	// as signMessage did before modes
+	SignModeKeccak = "keccak"
+)
+
 type SignMsgRequest struct {
 	WalletId string `json:"walletId"`
 	Message  string `json:"message"`
+	// Mode is personal_sign (default), bytes, hash, validator or keccak
+	Mode string `json:"mode,omitempty" example:"personal_sign"`
+	// Validator is the address of the validator contract of validator mode
+	Validator string `json:"validator,omitempty"`
 }
 
 type VerifyMsgRequest struct {
 	WalletId  string `json:"walletId"`
 	Message   string `json:"message"`
 	Signature string `json:"signature"`
+	// Mode and Validator are the ones the message was signed with
+	Mode      string `json:"mode,omitempty" example:"personal_sign"`
+	Validator string `json:"validator,omitempty"`
 }
 
 type VerifyMsgResponse struct {
@@ -174,7 +369,16 @@ type BalanceRequest struct {
 
 type BalanceResponse struct {
 	Address string `json:"address"`
-	Balance uint64 `json:"balance"`
+	ChainId string `json:"chainId,omitempty"`
+	// BlockNumber is the block the balances were read at, if read on chain
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	// Balance is in wei, Formatted in whole units of Symbol.
+	Balance   Amount                `json:"balance" swaggertype:"string" example:"1500000000000000000"`
+	Formatted string                `json:"formatted,omitempty" example:"1.5"`
+	Symbol    string                `json:"symbol,omitempty" example:"MATIC"`
+	Tokens    []TokenAmountResponse `json:"tokens,omitempty"`
+	// Source is "chain", or "proxy" if the balance came from the platform proxy
+	Source string `json:"source,omitempty" example:"chain"`
 }
 
 type PendingWalletResponse struct {
@@ -186,14 +390,17 @@ type PendingWalletResponse struct {
 
 type SignAndSubmitGSNTxnRequest struct {
 	WalletId    string        `json:"walletId,omitempty"`
-	ChainID     uint64        `json:"chainId,omitempty" example:"80001"`
+	ChainID     ChainId       `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
 	DAppId      string        `json:"dAppId,omitempty"`
 	To          string        `json:"to,omitempty" example:"store"`
 	Gas         uint64        `json:"gas,omitempty" `
-	Value       int64         `json:"value,omitempty" `
+	Value       Amount        `json:"value,omitempty" swaggertype:"string"`
 	Method      string        `json:"method,omitempty" example:"store"`
 	Params      []interface{} `json:"params,omitempty"`
 	ContractABI string        `json:"contractABI,omitempty" example:""`
+	// ContractName picks a registered contract for To and a missing ContractABI
+	ContractName    string `json:"contractName,omitempty"`
+	ContractVersion int    `json:"contractVersion,omitempty"`
 }
 
 type GSNTxnPayloadRequest struct {
@@ -217,3 +424,792 @@ type GSNSendTxnRequest struct {
 	DomainSeparator string      `json:"domainSeparator,omitempty"`
 	SignatureType   string      `json:"signatureType,omitempty"`
 }
+
+type TokenMetadataRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token   string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+}
+
+type TokenMetadata struct {
+	ChainId  string `json:"chainId"`
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+type TokenBalanceRequest struct {
+	WalletId string  `json:"walletId,omitempty"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token    string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	// Address defaults to the address of the wallet
+	Address string `json:"address,omitempty"`
+}
+
+type TokenAllowanceRequest struct {
+	WalletId string  `json:"walletId,omitempty"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token    string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	// Owner defaults to the address of the wallet
+	Owner   string `json:"owner,omitempty"`
+	Spender string `json:"spender"`
+}
+
+type TokenTransferRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token    string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	// From is only used by transferFrom, which moves tokens the wallet is
+	// allowed to spend
+	From           string `json:"from,omitempty"`
+	To             string `json:"to"`
+	Amount         Amount `json:"amount" swaggertype:"string" example:"1.5"`
+	SkipSimulation bool   `json:"skipSimulation,omitempty"`
+}
+
+type TokenApproveRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token    string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	Spender  string  `json:"spender"`
+	// Amount is in whole tokens, or "max" for an unlimited allowance
+	Amount         Amount `json:"amount" swaggertype:"string" example:"1.5"`
+	SkipSimulation bool   `json:"skipSimulation,omitempty"`
+}
+
+type TokenAmountResponse struct {
+	Token     string `json:"token"`
+	Symbol    string `json:"symbol"`
+	Decimals  uint8  `json:"decimals"`
+	Amount    string `json:"amount" example:"1500000"`
+	Formatted string `json:"formatted" example:"1.5"`
+	// Error is set instead of the amounts if the balance could not be read
+	Error string `json:"error,omitempty"`
+}
+
+type TokenTxnResponse struct {
+	TxnHash string `json:"txHash"`
+	Amount  string `json:"amount,omitempty" example:"1500000"`
+}
+
+const (
+	StandardERC721  = "ERC-721"
+	StandardERC1155 = "ERC-1155"
+)
+
+// NftContract is the detected standard of an NFT contract.
+type NftContract struct {
+	ChainId  string `json:"chainId"`
+	Contract string `json:"contract"`
+	Standard string `json:"standard" example:"ERC-721"`
+}
+
+type NftTokenRequest struct {
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	TokenId  string  `json:"tokenId" example:"1"`
+}
+
+type NftBalanceRequest struct {
+	WalletId string  `json:"walletId,omitempty"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	// Address defaults to the address of the wallet
+	Address string `json:"address,omitempty"`
+	// TokenId is mandatory for ERC-1155 and ignored for ERC-721, whose balance
+	// is the number of tokens owned
+	TokenId string `json:"tokenId,omitempty" example:"1"`
+}
+
+type NftBalanceBatchRequest struct {
+	ChainId  ChainId  `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string   `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	Owners   []string `json:"owners"`
+	TokenIds []string `json:"tokenIds"`
+}
+
+type NftTransferRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	// From defaults to the address of the wallet
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	TokenId string `json:"tokenId" example:"1"`
+	// Amount is only used by ERC-1155 and defaults to 1
+	Amount Amount `json:"amount,omitempty" swaggertype:"string" example:"1"`
+	// Data is passed to the receiver hook, hex encoded
+	Data           string `json:"data,omitempty" example:"0x"`
+	SkipSimulation bool   `json:"skipSimulation,omitempty"`
+}
+
+type NftBatchTransferRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	// From defaults to the address of the wallet
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to"`
+	TokenIds []string `json:"tokenIds"`
+	// Amounts are only used by ERC-1155 and default to 1 each
+	Amounts        []Amount `json:"amounts,omitempty" swaggertype:"array,string"`
+	Data           string   `json:"data,omitempty" example:"0x"`
+	SkipSimulation bool     `json:"skipSimulation,omitempty"`
+}
+
+type NftApprovalRequest struct {
+	WalletId       string  `json:"walletId"`
+	ChainId        ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract       string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	Operator       string  `json:"operator"`
+	Approved       bool    `json:"approved"`
+	SkipSimulation bool    `json:"skipSimulation,omitempty"`
+}
+
+type NftOwnerResponse struct {
+	Contract string `json:"contract"`
+	Standard string `json:"standard"`
+	TokenId  string `json:"tokenId"`
+	Owner    string `json:"owner"`
+}
+
+type NftBalanceResponse struct {
+	Contract string   `json:"contract"`
+	Standard string   `json:"standard"`
+	Balance  string   `json:"balance,omitempty"`
+	Balances []string `json:"balances,omitempty"`
+}
+
+type NftUriResponse struct {
+	Contract string `json:"contract"`
+	Standard string `json:"standard"`
+	TokenId  string `json:"tokenId"`
+	// Uri has the {id} placeholder of ERC-1155 replaced by the token id
+	Uri string `json:"uri"`
+}
+
+type NftTxnResponse struct {
+	Standard string `json:"standard"`
+	TxnHash  string `json:"txHash,omitempty"`
+	// TxnHashes holds one txn per token for ERC-721 batch transfers
+	TxnHashes []string `json:"txHashes,omitempty"`
+}
+
+// ContractCall is one call of a batch, given either as contractABI, method and
+// params or as raw hex encoded data.
+type ContractCall struct {
+	To          string  `json:"to" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractABI string  `json:"contractABI,omitempty"`
+	Method      string  `json:"method,omitempty" example:"retrieve"`
+	Params      []Param `json:"params,omitempty"`
+	Data        string  `json:"data,omitempty" example:"0x2e64cec1"`
+	// Value is only used by batched writes
+	Value Amount `json:"value,omitempty" swaggertype:"string" example:"0"`
+	// AllowFailure is only used by batched reads. A failing call without it
+	// fails the whole batch.
+	AllowFailure bool `json:"allowFailure,omitempty"`
+	// ContractName resolves the address, and without contractABI the ABI,
+	// from the contract registry
+	ContractName string `json:"contractName,omitempty"`
+}
+
+type BatchCallRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// BlockNumber is a decimal or 0x prefixed block number, latest if empty
+	BlockNumber string         `json:"blockNumber,omitempty" example:"latest"`
+	Calls       []ContractCall `json:"calls"`
+}
+
+type BatchCallResult struct {
+	Success bool `json:"success"`
+	// Response holds the decoded outputs of calls given with an ABI,
+	// ReturnData the raw hex encoded output of calls given as data
+	Response   []interface{} `json:"response,omitempty"`
+	ReturnData string        `json:"returnData,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	// Outputs has the named outputs by name, decoded like on callContract
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+}
+
+type BatchCallResponse struct {
+	BlockNumber uint64            `json:"blockNumber"`
+	Results     []BatchCallResult `json:"results"`
+}
+
+type BatchExecuteRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// Account is the smart account owned by the wallet that runs the calls
+	Account string `json:"account" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	// AccountType selects the executeBatch signature, simpleAccount for
+	// executeBatch(address[],uint256[],bytes[]) or simpleAccountV06 for
+	// executeBatch(address[],bytes[])
+	AccountType    string         `json:"accountType,omitempty" example:"simpleAccount"`
+	Calls          []ContractCall `json:"calls"`
+	SkipSimulation bool           `json:"skipSimulation,omitempty"`
+}
+
+const (
+	RevertError   = "error"
+	RevertPanic   = "panic"
+	RevertCustom  = "custom"
+	RevertUnknown = "unknown"
+)
+
+// SimulationFailure is the decoded reason of a transaction that would revert.
+// Transactions are simulated before signing unless skipSimulation is set.
+type SimulationFailure struct {
+	// Kind is error for Error(string), panic for Panic(uint256), custom for a
+	// custom error of the contract ABI, or unknown
+	Kind   string `json:"kind" example:"error"`
+	Reason string `json:"reason" example:"ERC20: transfer amount exceeds balance"`
+	// Error is the signature of a custom error and Args its decoded arguments
+	Error string                 `json:"error,omitempty" example:"InsufficientBalance(uint256,uint256)"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+	// Code is the panic code
+	Code string `json:"code,omitempty" example:"0x11"`
+	// Data is the raw revert data
+	Data string `json:"data,omitempty"`
+}
+
+type TransactionEventsRequest struct {
+	// ChainId defaults to the chain of a tracked transaction, or the default chain
+	ChainId     ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	TxnHash     string  `json:"txHash"`
+	ContractABI string  `json:"contractABI,omitempty"`
+}
+
+// DecodedEvent is a receipt log decoded into its event name and named
+// arguments, numbers as decimal strings. Logs no ABI matches keep their raw
+// topics and data instead.
+type DecodedEvent struct {
+	LogIndex  uint                   `json:"logIndex"`
+	Address   string                 `json:"address"`
+	Name      string                 `json:"name,omitempty" example:"Transfer"`
+	Signature string                 `json:"signature,omitempty" example:"Transfer(address,address,uint256)"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Topics    []string               `json:"topics,omitempty"`
+	Data      string                 `json:"data,omitempty"`
+	// the fields below are only set in webhook deliveries
+	TxnHash     string `json:"txHash,omitempty"`
+	BlockNumber uint64 `json:"blockNumber,omitempty"`
+	BlockHash   string `json:"blockHash,omitempty"`
+}
+
+type TransactionEventsResponse struct {
+	TxnHash         string         `json:"txHash"`
+	BlockNumber     uint64         `json:"blockNumber"`
+	Status          string         `json:"status" example:"mined"`
+	GasUsed         uint64         `json:"gasUsed"`
+	ContractAddress string         `json:"contractAddress,omitempty"`
+	Events          []DecodedEvent `json:"events"`
+}
+
+// EventSubscription delivers the events of a contract to a webhook.
+type EventSubscription struct {
+	Id          string   `json:"id"`
+	ChainId     string   `json:"chainId"`
+	Contract    string   `json:"contract"`
+	ContractABI string   `json:"contractABI"`
+	Events      []string `json:"events"`
+	WebhookUrl  string   `json:"webhookUrl"`
+	// Secret signs the webhook payloads, it is only returned on creation
+	Secret        string `json:"secret,omitempty"`
+	Confirmations uint64 `json:"confirmations"`
+	// LastBlock is the last block whose events were delivered and
+	// LastBlockHash its hash, used to detect reorgs
+	LastBlock     uint64 `json:"lastBlock"`
+	LastBlockHash string `json:"lastBlockHash,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	Reorgs        int    `json:"reorgs,omitempty"`
+	// ReorgUntil is the last block delivered before a reorg, blocks up to it
+	// are delivered again flagged as reorg
+	ReorgUntil uint64 `json:"reorgUntil,omitempty"`
+	CreatedAt  int64  `json:"createdAt"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+type CreateSubscriptionRequest struct {
+	ChainId     ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract    string  `json:"contract" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractABI string  `json:"contractABI"`
+	// Events are the event names to deliver, all events of the ABI if empty
+	Events     []string `json:"events,omitempty" example:"Transfer"`
+	WebhookUrl string   `json:"webhookUrl" example:"https://example.com/hooks/kms"`
+	// Secret signs the webhook payloads, a random one is generated if empty
+	Secret string `json:"secret,omitempty"`
+	// FromBlock is a decimal or 0x prefixed block to deliver events from,
+	// the next block if empty
+	FromBlock string `json:"fromBlock,omitempty" example:"latest"`
+	// Confirmations a block needs before its events are delivered, the
+	// confirmations of the chain if not set
+	Confirmations *uint64 `json:"confirmations,omitempty"`
+	// ContractName resolves the contract, and without contractABI its ABI,
+	// from the contract registry
+	ContractName string `json:"contractName,omitempty"`
+}
+
+type ListSubscriptionsRequest struct {
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Contract string  `json:"contract,omitempty"`
+}
+
+type DeleteSubscriptionRequest struct {
+	Id string `json:"id"`
+}
+
+// WebhookPayload is POSTed to the webhook of a subscription with the events
+// of a range of blocks. Delivery is at least once, events are identified by
+// txHash and logIndex. Reorg is set when the range is delivered again after
+// a reorg replaced blocks that were already delivered.
+type WebhookPayload struct {
+	SubscriptionId string         `json:"subscriptionId"`
+	ChainId        string         `json:"chainId"`
+	Contract       string         `json:"contract"`
+	FromBlock      uint64         `json:"fromBlock"`
+	ToBlock        uint64         `json:"toBlock"`
+	Reorg          bool           `json:"reorg,omitempty"`
+	Events         []DecodedEvent `json:"events"`
+}
+
+// ContractRecord is a contract of the registry. Every distinct ABI registered
+// for the address is kept as a new version, calls use the latest unless they
+// ask for another.
+type ContractRecord struct {
+	ChainId   string            `json:"chainId"`
+	Address   string            `json:"address"`
+	Aliases   []string          `json:"aliases,omitempty"`
+	Versions  []ContractVersion `json:"versions"`
+	CreatedAt int64             `json:"createdAt"`
+	UpdatedAt int64             `json:"updatedAt"`
+}
+
+type ContractVersion struct {
+	Version int    `json:"version"`
+	ABI     string `json:"abi"`
+	// Source is deploy for contracts deployed by the KMS, manual otherwise
+	Source       string `json:"source" example:"deploy"`
+	TxnHash      string `json:"txHash,omitempty"`
+	RegisteredAt int64  `json:"registeredAt"`
+}
+
+// PendingContract is a registration of a contract deployed or upgraded by a
+// txn that is not mined yet.
+type PendingContract struct {
+	ChainId string   `json:"chainId"`
+	Address string   `json:"address"`
+	ABI     string   `json:"abi"`
+	Aliases []string `json:"aliases,omitempty"`
+	Source  string   `json:"source"`
+}
+
+type RegisterContractRequest struct {
+	ChainId     ChainId  `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Address     string   `json:"address" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractABI string   `json:"contractABI"`
+	Aliases     []string `json:"aliases,omitempty" example:"storage"`
+}
+
+type GetContractRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// Address or ContractName identifies the contract
+	Address      string `json:"address,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractName string `json:"contractName,omitempty" example:"storage"`
+}
+
+type ListContractsRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+}
+
+const (
+	ProxyKindUUPS        = "uups"
+	ProxyKindTransparent = "transparent"
+)
+
+// ProxyImplementation is an implementation contract of a proxy, either an
+// existing one at Address or one deployed from ByteCode and Params.
+type ProxyImplementation struct {
+	Address  string  `json:"address,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ByteCode string  `json:"byteCode,omitempty"`
+	Params   []Param `json:"params,omitempty"`
+	// ABI is base64 encoded like on deployContract. It is taken from the
+	// contract registry for an existing implementation when empty
+	ABI string `json:"abi,omitempty"`
+	// Initializer is called through the proxy with InitializerParams when
+	// set, e.g. initialize
+	Initializer       string  `json:"initializer,omitempty" example:"initialize"`
+	InitializerParams []Param `json:"initializerParams,omitempty"`
+}
+
+type DeployProxyRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// ProxyKind is uups for an ERC1967Proxy, transparent for a
+	// TransparentUpgradeableProxy
+	ProxyKind string `json:"proxyKind" example:"uups"`
+	// ProxyByteCode is the creation bytecode of the proxy contract, taking
+	// (implementation, data) for uups and (implementation, admin, data) for
+	// transparent proxies
+	ProxyByteCode  string              `json:"proxyByteCode"`
+	Implementation ProxyImplementation `json:"implementation"`
+	// Admin of a transparent proxy, the wallet if empty. OpenZeppelin 5
+	// proxies deploy their own ProxyAdmin owned by it
+	Admin          string `json:"admin,omitempty"`
+	SkipSimulation bool   `json:"skipSimulation,omitempty"`
+	// ContractName registers the proxy under this alias
+	ContractName string `json:"contractName,omitempty"`
+}
+
+type DeployProxyResponse struct {
+	ProxyAddress          string `json:"proxyAddress"`
+	TxnHash               string `json:"txHash"`
+	ImplementationAddress string `json:"implementationAddress"`
+	// ImplementationTxnHash is set when the implementation was deployed
+	ImplementationTxnHash string `json:"implementationTxHash,omitempty"`
+}
+
+type UpgradeProxyRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// Proxy or ContractName identifies the proxy
+	Proxy        string `json:"proxy,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractName string `json:"contractName,omitempty"`
+	// ProxyKind is uups to upgrade through the implementation, transparent to
+	// upgrade through the admin of the proxy
+	ProxyKind      string              `json:"proxyKind" example:"uups"`
+	Implementation ProxyImplementation `json:"implementation"`
+	// UpgradeAndCall always uses upgradeToAndCall or upgradeAndCall instead of
+	// falling back to upgradeTo or upgrade when they revert without initializer
+	UpgradeAndCall bool `json:"upgradeAndCall,omitempty"`
+	SkipSimulation bool `json:"skipSimulation,omitempty"`
+}
+
+type UpgradeProxyResponse struct {
+	TxnHash               string `json:"txHash"`
+	ImplementationAddress string `json:"implementationAddress"`
+	ImplementationTxnHash string `json:"implementationTxHash,omitempty"`
+}
+
+type GetProxyRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// Proxy or ContractName identifies the proxy
+	Proxy        string `json:"proxy,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractName string `json:"contractName,omitempty"`
+}
+
+// ProxyInfo is read from the ERC-1967 storage slots of a proxy.
+type ProxyInfo struct {
+	Proxy          string `json:"proxy"`
+	Implementation string `json:"implementation,omitempty"`
+	Admin          string `json:"admin,omitempty"`
+	// AdminOwner is the owner of the admin when it is a ProxyAdmin contract
+	AdminOwner string `json:"adminOwner,omitempty"`
+	Beacon     string `json:"beacon,omitempty"`
+}
+
+type EncodeCalldataRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// ContractABI, or the registered ABI of the contract at To or named
+	// ContractName
+	ContractABI     string  `json:"contractABI,omitempty"`
+	To              string  `json:"to,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractName    string  `json:"contractName,omitempty"`
+	ContractVersion int     `json:"contractVersion,omitempty"`
+	Method          string  `json:"method" example:"store"`
+	Params          []Param `json:"params,omitempty"`
+}
+
+type EncodeCalldataResponse struct {
+	Data      string `json:"data" example:"0x6057361d0000000000000000000000000000000000000000000000000000000000000023"`
+	Selector  string `json:"selector" example:"0x6057361d"`
+	Signature string `json:"signature" example:"store(uint256)"`
+}
+
+type DecodeCalldataRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Data    string  `json:"data" example:"0x6057361d0000000000000000000000000000000000000000000000000000000000000023"`
+	// ContractABI, or the registered ABI of the contract at To or named
+	// ContractName. Without either the bundled selector database is used
+	ContractABI     string `json:"contractABI,omitempty"`
+	To              string `json:"to,omitempty" example:"0xc2de797fab7d2d2b26246e93fcf2cd5873a90b10"`
+	ContractName    string `json:"contractName,omitempty"`
+	ContractVersion int    `json:"contractVersion,omitempty"`
+}
+
+// DecodedCalldata is a decoded method call. Values are converted like the
+// outputs of callContract.
+type DecodedCalldata struct {
+	Method    string `json:"method" example:"store"`
+	Signature string `json:"signature" example:"store(uint256)"`
+	Selector  string `json:"selector" example:"0x6057361d"`
+	// Params has the arguments in order, Args the named ones by name.
+	// Arguments decoded from the selector database have no names
+	Params []interface{}          `json:"params"`
+	Args   map[string]interface{} `json:"args,omitempty"`
+	// Source is abi when decoded with an ABI, selector when decoded from
+	// the bundled selector database
+	Source string `json:"source" example:"abi"`
+}
+
+const (
+	EntryPointV06 = "v0.6"
+	EntryPointV07 = "v0.7"
+)
+
+type SmartAccountRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// EntryPointVersion is v0.6 or v0.7, the default
+	EntryPointVersion string `json:"entryPointVersion,omitempty" example:"v0.7"`
+	// EntryPoint and Factory override the canonical EntryPoint and
+	// SimpleAccountFactory of the version
+	EntryPoint string `json:"entryPoint,omitempty"`
+	Factory    string `json:"factory,omitempty"`
+	// FactoryData is the 0x hex call to the factory that deploys the
+	// account, createAccount(wallet, salt) by default
+	FactoryData string `json:"factoryData,omitempty"`
+	Salt        uint64 `json:"salt,omitempty"`
+}
+
+type SmartAccount struct {
+	Address           string `json:"address"`
+	Owner             string `json:"owner"`
+	EntryPoint        string `json:"entryPoint"`
+	EntryPointVersion string `json:"entryPointVersion"`
+	Factory           string `json:"factory"`
+	Deployed          bool   `json:"deployed"`
+	Nonce             string `json:"nonce"`
+}
+
+type SendUserOperationRequest struct {
+	WalletId          string  `json:"walletId"`
+	ChainId           ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	EntryPointVersion string  `json:"entryPointVersion,omitempty" example:"v0.7"`
+	EntryPoint        string  `json:"entryPoint,omitempty"`
+	Factory           string  `json:"factory,omitempty"`
+	FactoryData       string  `json:"factoryData,omitempty"`
+	Salt              uint64  `json:"salt,omitempty"`
+	// Calls run from the account, through execute for one call and
+	// executeBatch for more
+	Calls    []ContractCall `json:"calls"`
+	NonceKey uint64         `json:"nonceKey,omitempty"`
+	// gas limits and fees are estimated unless set
+	CallGasLimit         uint64 `json:"callGasLimit,omitempty"`
+	VerificationGasLimit uint64 `json:"verificationGasLimit,omitempty"`
+	PreVerificationGas   uint64 `json:"preVerificationGas,omitempty"`
+	MaxFeePerGas         Amount `json:"maxFeePerGas,omitempty" swaggertype:"string" example:"30 gwei"`
+	MaxPriorityFeePerGas Amount `json:"maxPriorityFeePerGas,omitempty" swaggertype:"string" example:"2 gwei"`
+	// Paymaster sponsors the operation with PaymasterData. For v0.6 both
+	// are joined into paymasterAndData
+	Paymaster                     string `json:"paymaster,omitempty"`
+	PaymasterData                 string `json:"paymasterData,omitempty"`
+	PaymasterVerificationGasLimit uint64 `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       uint64 `json:"paymasterPostOpGasLimit,omitempty"`
+	// SkipSubmit returns the signed operation without sending it
+	SkipSubmit bool `json:"skipSubmit,omitempty"`
+}
+
+// UserOperation is a user operation as sent to a bundler. v0.6 operations
+// use InitCode and PaymasterAndData, v0.7 operations the factory and
+// paymaster fields.
+type UserOperation struct {
+	Sender                        string `json:"sender"`
+	Nonce                         string `json:"nonce"`
+	InitCode                      string `json:"initCode,omitempty"`
+	Factory                       string `json:"factory,omitempty"`
+	FactoryData                   string `json:"factoryData,omitempty"`
+	CallData                      string `json:"callData"`
+	CallGasLimit                  string `json:"callGasLimit"`
+	VerificationGasLimit          string `json:"verificationGasLimit"`
+	PreVerificationGas            string `json:"preVerificationGas"`
+	MaxFeePerGas                  string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas          string `json:"maxPriorityFeePerGas"`
+	PaymasterAndData              string `json:"paymasterAndData,omitempty"`
+	Paymaster                     string `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit string `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       string `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 string `json:"paymasterData,omitempty"`
+	Signature                     string `json:"signature"`
+}
+
+type SendUserOperationResponse struct {
+	UserOpHash    string         `json:"userOpHash"`
+	Sender        string         `json:"sender"`
+	EntryPoint    string         `json:"entryPoint"`
+	UserOperation *UserOperation `json:"userOperation"`
+	// Submitted is false when skipSubmit was set
+	Submitted bool `json:"submitted"`
+}
+
+type UserOperationReceiptRequest struct {
+	ChainId    ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	UserOpHash string  `json:"userOpHash"`
+}
+
+type DeploySafeRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// Owners of the Safe, the wallet alone if empty
+	Owners    []string `json:"owners,omitempty"`
+	Threshold uint64   `json:"threshold,omitempty" example:"1"`
+	// SaltNonce picks one of the Safes with the same owners and threshold
+	SaltNonce uint64 `json:"saltNonce,omitempty"`
+	// Singleton, Factory and FallbackHandler override the Safe v1.3.0
+	// contracts
+	Singleton       string `json:"singleton,omitempty"`
+	Factory         string `json:"factory,omitempty"`
+	FallbackHandler string `json:"fallbackHandler,omitempty"`
+	SkipSimulation  bool   `json:"skipSimulation,omitempty"`
+}
+
+type DeploySafeResponse struct {
+	Safe            string `json:"safe"`
+	TxnHash         string `json:"txHash,omitempty"`
+	AlreadyDeployed bool   `json:"alreadyDeployed,omitempty"`
+}
+
+type GetSafeRequest struct {
+	ChainId ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Safe    string  `json:"safe"`
+}
+
+type SafeInfo struct {
+	Safe      string   `json:"safe"`
+	Version   string   `json:"version"`
+	Owners    []string `json:"owners"`
+	Threshold uint64   `json:"threshold"`
+	Nonce     string   `json:"nonce"`
+}
+
+const (
+	SafeOperationCall         = 0
+	SafeOperationDelegateCall = 1
+)
+
+// SafeTransaction is a transaction run by a Safe once enough owners signed
+// it. Nonce is the current nonce of the Safe if empty.
+type SafeTransaction struct {
+	To    string `json:"to"`
+	Value Amount `json:"value,omitempty" swaggertype:"string" example:"0"`
+	Data  string `json:"data,omitempty" example:"0x"`
+	// Operation is 0 for a call and 1 for a delegatecall
+	Operation      uint8  `json:"operation,omitempty"`
+	SafeTxGas      uint64 `json:"safeTxGas,omitempty"`
+	BaseGas        uint64 `json:"baseGas,omitempty"`
+	GasPrice       Amount `json:"gasPrice,omitempty" swaggertype:"string" example:"0"`
+	GasToken       string `json:"gasToken,omitempty"`
+	RefundReceiver string `json:"refundReceiver,omitempty"`
+	Nonce          string `json:"nonce,omitempty" example:"0"`
+}
+
+type SafeSignature struct {
+	Signer    string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+type SignSafeTransactionRequest struct {
+	WalletId    string          `json:"walletId"`
+	ChainId     ChainId         `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Safe        string          `json:"safe"`
+	Transaction SafeTransaction `json:"transaction"`
+}
+
+type SignSafeTransactionResponse struct {
+	SafeTxHash string `json:"safeTxHash"`
+	Nonce      string `json:"nonce"`
+	Signer     string `json:"signer"`
+	Signature  string `json:"signature"`
+}
+
+type ExecuteSafeTransactionRequest struct {
+	// WalletId sends the transaction and pays its gas, it need not be an
+	// owner
+	WalletId    string          `json:"walletId"`
+	ChainId     ChainId         `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Safe        string          `json:"safe"`
+	Transaction SafeTransaction `json:"transaction"`
+	// OwnerWalletIds sign the transaction with KMS owners, Signatures adds
+	// signatures collected elsewhere
+	OwnerWalletIds []string        `json:"ownerWalletIds,omitempty"`
+	Signatures     []SafeSignature `json:"signatures,omitempty"`
+	SkipSimulation bool            `json:"skipSimulation,omitempty"`
+}
+
+type ExecuteSafeTransactionResponse struct {
+	SafeTxHash string          `json:"safeTxHash"`
+	TxnHash    string          `json:"txHash"`
+	Signatures []SafeSignature `json:"signatures"`
+}
+
+type PermitRequest struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	Token    string  `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	Spender  string  `json:"spender"`
+	// Amount is in whole tokens, or "max" for an unlimited allowance
+	Amount Amount `json:"amount" swaggertype:"string" example:"1.5"`
+	// Deadline is a unix timestamp, an hour from now if empty
+	Deadline uint64 `json:"deadline,omitempty"`
+	// Version overrides the EIP-712 domain version read from the token
+	Version string `json:"version,omitempty"`
+}
+
+type PermitResponse struct {
+	Token     string `json:"token"`
+	Owner     string `json:"owner"`
+	Spender   string `json:"spender"`
+	Value     string `json:"value"`
+	Nonce     string `json:"nonce"`
+	Deadline  uint64 `json:"deadline"`
+	Signature string `json:"signature"`
+	V         uint8  `json:"v"`
+	R         string `json:"r"`
+	S         string `json:"s"`
+}
+
+const (
+	Permit2PermitSingle            = "PermitSingle"
+	Permit2PermitBatch             = "PermitBatch"
+	Permit2PermitTransferFrom      = "PermitTransferFrom"
+	Permit2PermitBatchTransferFrom = "PermitBatchTransferFrom"
+)
+
+type Permit2Token struct {
+	Token string `json:"token" example:"0x0FA8781a83E46826621b3BC094Ea2A0212e71B23"`
+	// Amount is in whole tokens, or "max"
+	Amount Amount `json:"amount" swaggertype:"string" example:"1.5"`
+}
+
+type Permit2Request struct {
+	WalletId string  `json:"walletId"`
+	ChainId  ChainId `json:"chainId,omitempty" swaggertype:"string" example:"80001"`
+	// PrimaryType is PermitSingle or PermitBatch for allowances, and
+	// PermitTransferFrom or PermitBatchTransferFrom for one-time transfers
+	PrimaryType string         `json:"primaryType" example:"PermitSingle"`
+	Spender     string         `json:"spender"`
+	Tokens      []Permit2Token `json:"tokens"`
+	// Expiration is the unix timestamp allowances end at, in 30 days if
+	// empty
+	Expiration uint64 `json:"expiration,omitempty"`
+	// Deadline is the unix timestamp the signature is valid until, an hour
+	// from now if empty
+	Deadline uint64 `json:"deadline,omitempty"`
+	// Nonce is the unordered nonce of a transfer, the first unused one if
+	// empty
+	Nonce string `json:"nonce,omitempty"`
+	// Permit2 overrides the canonical Permit2 address
+	Permit2 string `json:"permit2,omitempty"`
+}
+
+type Permit2Response struct {
+	Permit2     string `json:"permit2"`
+	Owner       string `json:"owner"`
+	PrimaryType string `json:"primaryType"`
+	// Permit is the signed message, in the shape the Permit2 methods take
+	Permit    map[string]interface{} `json:"permit"`
+	Deadline  uint64                 `json:"deadline"`
+	Signature string                 `json:"signature"`
+	V         uint8                  `json:"v"`
+	R         string                 `json:"r"`
+	S         string                 `json:"s"`
+}
@@ -4,16 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"log"
-	"math/big"
 	"net/http"
-	"strconv"
-	"strings"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/labstack/echo/v4"
@@ -28,6 +22,7 @@ const (
 	GetBalance               = "/utils/getBalance"
 	FetchPendingWallets      = "/ncWallet/fetchAllReferenceWallet"
 	UpdatePendingWallets     = "/ncWallet/updateNcWalletReference"
+	ReportTransactionAttempt = "/ncWallet/reportTransactionAttempt"
 
 	GetGaslessPayloadEndpoint      = "/gasless/getTransactionPayload"
 	SendGaslessTransactionEndpoint = "/gasless/sendTransaction"
@@ -88,150 +83,6 @@ func SendSuccessResponse(c echo.Context, message string, data interface{}) error
 	return c.JSON(http.StatusOK, RespBody)
 }
 
-func ConvertParamsAsPerTypes(params []Param) ([]interface{}, error) {
-	var response []interface{}
-	for _, param := range params {
-		switch param.Type {
-		case "string":
-			response = append(response, param.Value)
-		case "uint", "uint64":
-			value, err := strconv.ParseUint(param.Value, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, value)
-		case "uint8":
-			value, err := strconv.ParseUint(param.Value, 10, 8)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, uint8(value))
-		case "uint16":
-			value, err := strconv.ParseUint(param.Value, 10, 16)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, uint16(value))
-		case "uint32":
-			value, err := strconv.ParseUint(param.Value, 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, uint32(value))
-		case "uint128", "uint256":
-			value := new(big.Int)
-			value, ok := value.SetString(param.Value, 10)
-			if !ok {
-				return nil, fmt.Errorf("converting uint128, uint256 error")
-			}
-			response = append(response, value)
-		case "bool":
-			if param.Value == "true" {
-				response = append(response, true)
-			} else if param.Value == "false" {
-				response = append(response, false)
-			} else {
-				return nil, fmt.Errorf("invalid boolean value")
-			}
-		case "address":
-			response = append(response, common.HexToAddress(param.Value))
-
-		case "[]address":
-			addressesParam := []common.Address{}
-			addresses := strings.Split(param.Value, ",")
-			for _, address := range addresses {
-				addressesParam = append(addressesParam, common.HexToAddress(address))
-			}
-			response = append(response, addressesParam)
-		case "int", "int64":
-			value, err := strconv.ParseInt(param.Value, 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, value)
-		case "int8":
-			value, err := strconv.ParseInt(param.Value, 10, 8)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, int8(value))
-		case "int16":
-			value, err := strconv.ParseInt(param.Value, 10, 16)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, int16(value))
-		case "int32":
-			value, err := strconv.ParseInt(param.Value, 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, int32(value))
-		case "int128", "int256":
-			value := new(big.Int)
-			value, ok := value.SetString(param.Value, 10)
-			if !ok {
-				return nil, fmt.Errorf("converting int128, int256 error")
-			}
-			response = append(response, value)
-		case "bytes":
-			bytes, err := base64.RawStdEncoding.DecodeString(param.Value)
-			if err != nil {
-				return nil, err
-			}
-			response = append(response, bytes)
-		case "bytes32":
-			var value [32]byte
-			bytes, err := base64.RawStdEncoding.DecodeString(param.Value)
-			if err != nil {
-				return nil, err
-			}
-			copy(value[:], bytes)
-			response = append(response, value)
-		case "[]bytes32":
-			params := [][32]byte{}
-			paramsStr := strings.Split(param.Value, ",")
-			for _, param := range paramsStr {
-				var value [32]byte
-				bytes, err := base64.RawStdEncoding.DecodeString(param)
-				if err != nil {
-					return nil, err
-				}
-				copy(value[:], bytes)
-				params = append(params, value)
-			}
-			response = append(response, params)
-		case "bytes4":
-			var value [4]byte
-			bytes, err := base64.RawStdEncoding.DecodeString(param.Value)
-			if err != nil {
-				return nil, err
-			}
-			copy(value[:], bytes)
-			response = append(response, bytes[:4])
-		case "bytes8":
-			var value [8]byte
-			bytes, err := base64.RawStdEncoding.DecodeString(param.Value)
-			if err != nil {
-				return nil, err
-			}
-			copy(value[:], bytes)
-			response = append(response, bytes[:8])
-		case "bytes16":
-			var value [16]byte
-			bytes, err := base64.RawStdEncoding.DecodeString(param.Value)
-			if err != nil {
-				return nil, err
-			}
-			copy(value[:], bytes)
-			response = append(response, bytes[:16])
-		default:
-			response = append(response, param.Value)
-		}
-	}
-	return response, nil
-}
-
 func HttpCall(method string, url string, body interface{}, restype interface{}, header map[string]string) error {
 	var databytes []byte
 	var err error
@@ -267,13 +118,28 @@ func HttpCall(method string, url string, body interface{}, restype interface{},
 	return nil
 }
 
-func GetEthereumClient(ctx context.Context, config *Config) (*ethclient.Client, error) {
+// GetEthereumClient returns the pooled client of the chain with the given id,
+// or of the default chain if chainId is empty. The client is shared and must
+// not be closed.
+func GetEthereumClient(ctx context.Context, config *Config, chainId string) (*ethclient.Client, error) {
+	pool, err := config.Chains.Pool(chainId)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Client(), nil
+}
+
+func rpcHeader(config *Config) http.Header {
 	header := make(http.Header)
 	header.Set("Content-Type", "application/json")
 	header.Set("Authorization", config.AuthToken)
 	header.Set("instanceId", config.InstanceId)
-	options := rpc.WithHeaders(header)
-	rpcClient, err := rpc.DialOptions(ctx, config.Endpoint, options)
+	return header
+}
+
+func dialEthereumClient(ctx context.Context, config *Config, endpoint string) (*ethclient.Client, error) {
+	options := rpc.WithHeaders(rpcHeader(config))
+	rpcClient, err := rpc.DialOptions(ctx, endpoint, options)
 	if err != nil {
 		return nil, err
 	}
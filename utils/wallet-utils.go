@@ -59,6 +59,22 @@ func UpdatePlatformNonce(config *Config, request *NonceRequest) error {
 	return nil
 }
 
+func ReportTxnAttempt(config *Config, request *TxnAttemptRequest) error {
+	res := ResponseBody{}
+	header := make(map[string]string)
+	header["Content-Type"] = "application/json"
+	header["Authorization"] = config.AuthToken
+	header["InstanceId"] = config.InstanceId
+	header["SubscriptionId"] = config.SubscriptionId
+	if err := HttpCall("POST", config.ProxyUrl+ReportTransactionAttempt, request, &res, header); err != nil {
+		return err
+	}
+	if res.Status == "FAILURE" {
+		return fmt.Errorf(res.Message)
+	}
+	return nil
+}
+
 func GetAllDeployRecords(config *Config) ([]Deploy, error) {
 	req := Request{}
 	res := ResponseBody{}
@@ -114,7 +130,10 @@ func GetBalanceByChainId(config *Config, address, chainId string) (*BalanceRespo
 		Address: address,
 		ChainId: chainId,
 	}
-	res := ResponseBody{}
+	// decode the data straight into the response so large balances given as
+	// JSON numbers keep their precision
+	var balanceRes BalanceResponse
+	res := ResponseBody{Data: &balanceRes}
 	header := make(map[string]string)
 	header["Content-Type"] = "application/json"
 	header["Authorization"] = config.AuthToken
@@ -126,14 +145,6 @@ func GetBalanceByChainId(config *Config, address, chainId string) (*BalanceRespo
 	if res.Status == "FAILURE" {
 		return nil, fmt.Errorf(res.Message)
 	}
-	resBytes, err := json.Marshal(res.Data)
-	if err != nil {
-		return nil, err
-	}
-	var balanceRes BalanceResponse
-	if err := json.Unmarshal(resBytes, &balanceRes); err != nil {
-		return nil, err
-	}
 	return &balanceRes, nil
 }
 